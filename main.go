@@ -5,6 +5,7 @@ import (
 	"nysoure/server/api"
 	"nysoure/server/dao"
 	"nysoure/server/middleware"
+	"nysoure/server/search"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/adaptor"
@@ -14,6 +15,9 @@ import (
 
 func main() {
 	dao.InitDB()
+	if err := search.Init(); err != nil {
+		log.Fatal(err)
+	}
 
 	app := fiber.New(fiber.Config{
 		BodyLimit:   8 * 1024 * 1024,
@@ -47,6 +51,7 @@ func main() {
 	{
 		api.AddUserRoutes(apiG)
 		api.AddTagRoutes(apiG)
+		api.AddTagTypeRoutes(apiG)
 		api.AddImageRoutes(apiG)
 		api.AddResourceRoutes(apiG)
 		api.AddStorageRoutes(apiG)
@@ -55,6 +60,7 @@ func main() {
 		api.AddConfigRoutes(apiG)
 		api.AddActivityRoutes(apiG)
 		api.AddCollectionRoutes(apiG)
+		api.AddSubscriptionRoutes(apiG)
 		api.AddProxyRoutes(apiG)
 		api.AddDevAPI(apiG)
 	}
@@ -1,187 +1,70 @@
 package aireview
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log/slog"
-	"net/http"
-	"nysoure/server/config"
 	"strings"
-	"time"
-
-	"google.golang.org/genai"
-)
-
-// OpenAI types
-type OpenAIRequest struct {
-	Model    string          `json:"model"`
-	Messages []OpenAIMessage `json:"messages"`
-}
-
-type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type OpenAIResponse struct {
-	Choices []struct {
-		Message OpenAIMessage `json:"message"`
-	} `json:"choices"`
-	Error *struct {
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
-}
-
-// AIProvider represents the AI service provider
-type AIProvider int
-
-const (
-	ProviderOpenAI AIProvider = iota
-	ProviderGoogleAI
 )
 
-// detectProvider determines the AI provider based on the API URL
-func detectProvider(apiUrl string) AIProvider {
-	if strings.Contains(apiUrl, "generativelanguage.googleapis.com") {
-		return ProviderGoogleAI
-	}
-	return ProviderOpenAI
-}
-
-// Chat sends a message to AI API and returns the response
-// Automatically detects and adapts to OpenAI or Google AI based on API URL
+// Chat sends a message to the configured AI provider and returns the response.
+// It returns an empty string if AI is not configured or the request fails.
 func Chat(content string) string {
-	apiUrl := config.OpenAIUrl()
-	apiKey := config.OpenAIApiKey()
-	model := config.OpenAIModel()
-
-	// Check if AI is configured
-	if apiUrl == "" || apiKey == "" || model == "" {
-		slog.Warn("AI not configured, skipping AI check")
-		return ""
-	}
-
-	provider := detectProvider(apiUrl)
-
-	switch provider {
-	case ProviderGoogleAI:
-		return chatWithGoogleAI(apiUrl, apiKey, model, content)
-	default:
-		return chatWithOpenAI(apiUrl, apiKey, model, content)
-	}
-}
-
-// chatWithOpenAI sends a message to OpenAI API
-func chatWithOpenAI(apiUrl, apiKey, model, content string) string {
-	reqBody := OpenAIRequest{
-		Model: model,
-		Messages: []OpenAIMessage{
-			{
-				Role:    "user",
-				Content: content,
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		slog.Error("Failed to marshal OpenAI request", "error", err)
-		return ""
-	}
-
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(jsonData))
+	p, err := activeProvider()
 	if err != nil {
-		slog.Error("Failed to create OpenAI request", "error", err)
-		return ""
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		slog.Error("Failed to send OpenAI request", "error", err)
+		if errors.Is(err, ErrNotConfigured) {
+			slog.Warn("AI not configured, skipping AI check")
+		} else {
+			slog.Error("Failed to create AI provider", "error", err)
+		}
 		return ""
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	resp, err := p.Chat(context.Background(), Request{Prompt: content})
 	if err != nil {
-		slog.Error("Failed to read OpenAI response", "error", err)
-		return ""
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		slog.Error("OpenAI API returned error", "status", resp.StatusCode, "body", string(body))
-		return ""
-	}
-
-	var openAIResp OpenAIResponse
-	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		slog.Error("Failed to unmarshal OpenAI response", "error", err)
-		return ""
-	}
-
-	if openAIResp.Error != nil {
-		slog.Error("OpenAI API error", "message", openAIResp.Error.Message)
-		return ""
-	}
-
-	if len(openAIResp.Choices) == 0 {
-		slog.Error("No choices in OpenAI response")
+		slog.Error("AI request failed", "provider", p.Name(), "error", err)
 		return ""
 	}
-
-	return openAIResp.Choices[0].Message.Content
+	return resp
 }
 
-// chatWithGoogleAI sends a message to Google AI API using official SDK
-func chatWithGoogleAI(apiUrl, apiKey, model, content string) string {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Create client with API key
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
+// ChatJSON sends a prompt that must be answered with JSON matching schema
+// and decodes the response into out.
+func ChatJSON(ctx context.Context, prompt string, schemaName string, schema map[string]any, out any) error {
+	p, err := activeProvider()
 	if err != nil {
-		slog.Error("Failed to create Google AI client", "error", err)
-		return ""
+		return err
 	}
-
-	// Create content for the request
-	genaiContent := &genai.Content{
-		Parts: []*genai.Part{
-			{Text: content},
-		},
-	}
-
-	// Generate content using the model
-	resp, err := client.Models.GenerateContent(ctx, model, []*genai.Content{genaiContent}, nil)
+	resp, err := p.Chat(ctx, Request{
+		Prompt:     prompt,
+		SchemaName: schemaName,
+		Schema:     schema,
+	})
 	if err != nil {
-		slog.Error("Failed to generate content with Google AI", "error", err)
-		return ""
+		return err
 	}
-
-	// Extract text from response
-	if len(resp.Candidates) == 0 {
-		slog.Error("No candidates in Google AI response")
-		return ""
+	if err := json.Unmarshal([]byte(extractJSON(resp)), out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", p.Name(), err)
 	}
+	return nil
+}
 
-	var resultText strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if part.Text != "" {
-			resultText.WriteString(part.Text)
-		}
-	}
+// IsConfigured reports whether an AI provider is available.
+func IsConfigured() bool {
+	_, err := activeProvider()
+	return err == nil
+}
 
-	return resultText.String()
+// extractJSON strips a markdown code fence that some models wrap around JSON
+// even when structured output is requested.
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
 }
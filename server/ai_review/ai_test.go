@@ -0,0 +1,135 @@
+package aireview
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+func TestIsAdWithFakeProvider(t *testing.T) {
+	fake := &FakeProvider{Reply: `{"label":"ad","confidence":0.95,"reason":"sells products"}`}
+	SetProvider(fake)
+	defer SetProvider(nil)
+
+	assert.True(t, IsAd("Best deals on sneakers, visit our shop today"))
+	requests := fake.Requests()
+	assert.Len(t, requests, 1)
+	assert.NotNil(t, requests[0].Schema)
+
+	fake.Reply = `{"label":"ad","confidence":0.3,"reason":"unsure"}`
+	assert.False(t, IsAd("Best deals on sneakers, visit our shop today"))
+
+	fake.Reply = "```json\n{\"label\":\"not_ad\",\"confidence\":0.99,\"reason\":\"normal comment\"}\n```"
+	assert.False(t, IsAd("This game has a really nice soundtrack"))
+
+	// Pattern matching does not need the provider
+	assert.True(t, IsAd("请加微信了解更多详情"))
+	assert.Len(t, fake.Requests(), 3)
+}
+
+func TestClassifyRejectsUnknownLabel(t *testing.T) {
+	SetProvider(&FakeProvider{Reply: `{"label":"maybe","confidence":2,"reason":""}`})
+	defer SetProvider(nil)
+
+	_, err := Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	assert.Error(t, err)
+}
+
+func TestRetryAndCircuitBreaker(t *testing.T) {
+	calls := 0
+	fake := &FakeProvider{Respond: func(req Request) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", &StatusError{Provider: "fake", StatusCode: http.StatusServiceUnavailable}
+		}
+		return `{"label":"not_ad","confidence":1,"reason":""}`, nil
+	}}
+	SetProvider(fake)
+	defer SetProvider(nil)
+
+	v, err := Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	assert.NoError(t, err)
+	assert.Equal(t, LabelNotAd, v.Label)
+	assert.Equal(t, 3, calls)
+
+	// Client errors are not retried
+	calls = 0
+	fake.Respond = func(req Request) (string, error) {
+		calls++
+		return "", &StatusError{Provider: "fake", StatusCode: http.StatusBadRequest}
+	}
+	_, err = Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// Consecutive failures open the circuit
+	for i := 1; i < breakerThreshold; i++ {
+		_, _ = Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	}
+	calls = 0
+	_, err = Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, 0, calls)
+}
+
+func TestOpenAIProviderRequestsStructuredOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		var req OpenAIRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "model", req.Model)
+		if assert.NotNil(t, req.ResponseFormat) {
+			assert.Equal(t, "json_schema", req.ResponseFormat.Type)
+			assert.Equal(t, "verdict", req.ResponseFormat.JSONSchema.Name)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"label\":\"ad\",\"confidence\":0.9,\"reason\":\"spam\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	p, err := NewProvider(ProviderOpenAI, ProviderConfig{URL: server.URL, APIKey: "key", Model: "model"})
+	assert.NoError(t, err)
+	SetProvider(p)
+	defer SetProvider(nil)
+
+	v, err := Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	assert.NoError(t, err)
+	assert.Equal(t, Verdict{Label: LabelAd, Confidence: 0.9, Reason: "spam"}, v)
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		var req OllamaRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.False(t, req.Stream)
+		assert.NotNil(t, req.Format)
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"{\"label\":\"not_ad\",\"confidence\":0.7,\"reason\":\"\"}"}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewProvider(ProviderOllama, ProviderConfig{URL: server.URL, Model: "llama"})
+	assert.NoError(t, err)
+	SetProvider(p)
+	defer SetProvider(nil)
+
+	v, err := Classify(context.Background(), "prompt", []string{LabelAd, LabelNotAd})
+	assert.NoError(t, err)
+	assert.Equal(t, LabelNotAd, v.Label)
+}
+
+func TestDetectProvider(t *testing.T) {
+	assert.Equal(t, ProviderGemini, detectProvider("https://generativelanguage.googleapis.com/v1beta"))
+	assert.Equal(t, ProviderOllama, detectProvider("http://localhost:11434"))
+	assert.Equal(t, ProviderOllama, detectProvider("http://ai.local/api/chat"))
+	assert.Equal(t, ProviderOpenAI, detectProvider("https://api.openai.com/v1/chat/completions"))
+}
@@ -1,10 +1,21 @@
 package aireview
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"strings"
 )
 
+const (
+	LabelAd    = "ad"
+	LabelNotAd = "not_ad"
+
+	// adConfidenceThreshold is the minimum confidence required to treat content as an ad.
+	// Banning is irreversible from the user's point of view, so uncertain verdicts are ignored.
+	adConfidenceThreshold = 0.8
+)
+
 const adDetectionPrompt = `你是一个广告检测专家。请判断以下内容是否为广告、营销推广或垃圾信息。
 
 判断标准：
@@ -14,12 +25,12 @@ const adDetectionPrompt = `你是一个广告检测专家。请判断以下内
 4. 包含重复的推广性质内容
 5. 包含赌博、金融诈骗等违规内容
 
-请仅回答"是"或"否"，不要有其他内容。
+请以JSON格式回答：label 为 "ad"（是广告）或 "not_ad"（不是广告），confidence 为 0 到 1 之间的置信度，reason 为简短的判断理由。
 
 待检测内容：
 %s`
 
-// IsAd checks if the content is an advertisement using OpenAI API
+// IsAd checks if the content is an advertisement using the configured AI provider
 func IsAd(content string) bool {
 	// If content is too short, it's unlikely to be an ad
 	if len(content) < 10 {
@@ -32,26 +43,21 @@ func IsAd(content string) bool {
 		return true
 	}
 
-	// Use OpenAI for more sophisticated detection
+	// Use AI for more sophisticated detection
 	prompt := strings.Replace(adDetectionPrompt, "%s", content, 1)
-	response := Chat(prompt)
-
-	if response == "" {
-		// If OpenAI is not available or fails, default to false to avoid false positives
+	verdict, err := Classify(context.Background(), prompt, []string{LabelAd, LabelNotAd})
+	if err != nil {
+		// If AI is not available or fails, default to false to avoid false positives
+		if !errors.Is(err, ErrNotConfigured) {
+			slog.Error("AI ad detection failed", "error", err)
+		}
 		return false
 	}
 
-	// Parse the response
-	response = strings.TrimSpace(response)
-	response = strings.ToLower(response)
-
-	// Check if the response indicates it's an ad
-	isAd := strings.Contains(response, "是") ||
-		strings.Contains(response, "yes") ||
-		strings.Contains(response, "true")
+	isAd := verdict.Label == LabelAd && verdict.Confidence >= adConfidenceThreshold
 
 	if isAd {
-		slog.Info("Content detected as ad by AI", "content", content)
+		slog.Info("Content detected as ad by AI", "content", content, "confidence", verdict.Confidence, "reason", verdict.Reason)
 	}
 
 	return isAd
@@ -0,0 +1,40 @@
+package aireview
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeProvider is an offline Provider for tests.
+// Respond is called for every request; if it is nil, Reply is returned.
+type FakeProvider struct {
+	Reply   string
+	Respond func(req Request) (string, error)
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *FakeProvider) Chat(ctx context.Context, req Request) (string, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if f.Respond != nil {
+		return f.Respond(req)
+	}
+	return f.Reply, nil
+}
+
+// Requests returns the requests received so far.
+func (f *FakeProvider) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
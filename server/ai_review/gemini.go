@@ -0,0 +1,81 @@
+package aireview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+func init() {
+	RegisterProvider(ProviderGemini, func(cfg ProviderConfig) (Provider, error) {
+		if cfg.APIKey == "" {
+			return nil, ErrNotConfigured
+		}
+		return &geminiProvider{cfg: cfg}, nil
+	})
+}
+
+// geminiProvider talks to Google AI using the official SDK.
+type geminiProvider struct {
+	cfg    ProviderConfig
+	once   sync.Once
+	client *genai.Client
+	err    error
+}
+
+func (p *geminiProvider) Name() string {
+	return ProviderGemini
+}
+
+func (p *geminiProvider) getClient(ctx context.Context) (*genai.Client, error) {
+	p.once.Do(func() {
+		p.client, p.err = genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey:  p.cfg.APIKey,
+			Backend: genai.BackendGeminiAPI,
+		})
+	})
+	return p.client, p.err
+}
+
+func (p *geminiProvider) Chat(ctx context.Context, req Request) (string, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Google AI client: %w", err)
+	}
+
+	genaiContent := &genai.Content{
+		Parts: []*genai.Part{
+			{Text: req.Prompt},
+		},
+	}
+
+	var genConfig *genai.GenerateContentConfig
+	if req.Schema != nil {
+		genConfig = &genai.GenerateContentConfig{
+			ResponseMIMEType:   "application/json",
+			ResponseJsonSchema: req.Schema,
+		}
+	}
+
+	resp, err := client.Models.GenerateContent(ctx, p.cfg.Model, []*genai.Content{genaiContent}, genConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate content with Google AI: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", errors.New("no candidates in Google AI response")
+	}
+
+	var resultText strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.Text != "" {
+			resultText.WriteString(part.Text)
+		}
+	}
+
+	return resultText.String(), nil
+}
@@ -0,0 +1,102 @@
+package aireview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type OllamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []OpenAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   map[string]any  `json:"format,omitempty"`
+}
+
+type OllamaResponse struct {
+	Message OpenAIMessage `json:"message"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func init() {
+	RegisterProvider(ProviderOllama, func(cfg ProviderConfig) (Provider, error) {
+		return &ollamaProvider{cfg: cfg, client: &http.Client{}}, nil
+	})
+}
+
+// ollamaProvider talks to a local Ollama-style /api/chat endpoint.
+// An API key is optional and only sent when configured.
+type ollamaProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *ollamaProvider) Name() string {
+	return ProviderOllama
+}
+
+func (p *ollamaProvider) endpoint() string {
+	u := strings.TrimSuffix(p.cfg.URL, "/")
+	if strings.HasSuffix(u, "/api/chat") {
+		return u
+	}
+	return u + "/api/chat"
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req Request) (string, error) {
+	reqBody := OllamaRequest{
+		Model: p.cfg.Model,
+		Messages: []OpenAIMessage{
+			{
+				Role:    "user",
+				Content: req.Prompt,
+			},
+		},
+		Stream: false,
+		Format: req.Schema,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Ollama request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send Ollama request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Ollama response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: p.Name(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var ollamaResp OllamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal Ollama response: %w", err)
+	}
+	if ollamaResp.Error != "" {
+		return "", errors.New("Ollama API error: " + ollamaResp.Error)
+	}
+
+	return ollamaResp.Message.Content, nil
+}
@@ -0,0 +1,126 @@
+package aireview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OpenAI types
+type OpenAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []OpenAIMessage       `json:"messages"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict"`
+}
+
+type OpenAIResponse struct {
+	Choices []struct {
+		Message OpenAIMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func init() {
+	RegisterProvider(ProviderOpenAI, func(cfg ProviderConfig) (Provider, error) {
+		if cfg.APIKey == "" {
+			return nil, ErrNotConfigured
+		}
+		return &openAIProvider{cfg: cfg, client: &http.Client{}}, nil
+	})
+}
+
+// openAIProvider talks to any OpenAI-compatible chat completions endpoint.
+type openAIProvider struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func (p *openAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req Request) (string, error) {
+	reqBody := OpenAIRequest{
+		Model: p.cfg.Model,
+		Messages: []OpenAIMessage{
+			{
+				Role:    "user",
+				Content: req.Prompt,
+			},
+		},
+	}
+	if req.Schema != nil {
+		reqBody.ResponseFormat = &OpenAIResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &OpenAIJSONSchema{
+				Name:   req.SchemaName,
+				Schema: req.Schema,
+				Strict: true,
+			},
+		}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal OpenAI request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.cfg.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create OpenAI request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send OpenAI request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read OpenAI response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: p.Name(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var openAIResp OpenAIResponse
+	if err := json.Unmarshal(body, &openAIResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal OpenAI response: %w", err)
+	}
+
+	if openAIResp.Error != nil {
+		return "", errors.New("OpenAI API error: " + openAIResp.Error.Message)
+	}
+
+	if len(openAIResp.Choices) == 0 {
+		return "", errors.New("no choices in OpenAI response")
+	}
+
+	return openAIResp.Choices[0].Message.Content, nil
+}
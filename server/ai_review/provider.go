@@ -0,0 +1,138 @@
+package aireview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"nysoure/server/config"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNotConfigured = errors.New("AI provider is not configured")
+	ErrCircuitOpen   = errors.New("AI provider circuit breaker is open")
+)
+
+// Request is a single prompt sent to a provider.
+type Request struct {
+	Prompt string
+	// SchemaName identifies the schema for providers that require a name.
+	SchemaName string
+	// Schema is a JSON schema the response must conform to.
+	// If nil, the provider returns free text.
+	Schema map[string]any
+}
+
+// Provider is a chat completion backend.
+type Provider interface {
+	Name() string
+	Chat(ctx context.Context, req Request) (string, error)
+}
+
+// ProviderConfig holds the connection settings passed to a provider factory.
+type ProviderConfig struct {
+	URL    string
+	APIKey string
+	Model  string
+}
+
+// ProviderFactory creates a provider from its configuration.
+type ProviderFactory func(cfg ProviderConfig) (Provider, error)
+
+const (
+	ProviderOpenAI = "openai"
+	ProviderGemini = "gemini"
+	ProviderOllama = "ollama"
+)
+
+var (
+	factories   = map[string]ProviderFactory{}
+	factoriesMu sync.RWMutex
+
+	active     *resilientProvider
+	activeErr  error
+	activeOnce sync.Once
+	activeMu   sync.RWMutex
+)
+
+// RegisterProvider makes a provider available under the given name.
+// Registering the same name twice replaces the previous factory.
+func RegisterProvider(name string, factory ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewProvider creates a registered provider by name.
+func NewProvider(name string, cfg ProviderConfig) (Provider, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider: %s", name)
+	}
+	return factory(cfg)
+}
+
+// SetProvider replaces the active provider. The provider is wrapped with the
+// same timeout, retry and circuit breaker policy as configured providers.
+// It is mainly used by tests to install a FakeProvider.
+func SetProvider(p Provider) {
+	activeOnce.Do(func() {})
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if p == nil {
+		active = nil
+		activeErr = ErrNotConfigured
+		return
+	}
+	active = newResilientProvider(p)
+	activeErr = nil
+}
+
+func activeProvider() (*resilientProvider, error) {
+	activeOnce.Do(func() {
+		p, err := providerFromConfig()
+		activeMu.Lock()
+		defer activeMu.Unlock()
+		if err != nil {
+			activeErr = err
+			return
+		}
+		active = newResilientProvider(p)
+	})
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active, activeErr
+}
+
+func providerFromConfig() (Provider, error) {
+	cfg := ProviderConfig{
+		URL:    config.OpenAIUrl(),
+		APIKey: config.OpenAIApiKey(),
+		Model:  config.OpenAIModel(),
+	}
+	if cfg.URL == "" || cfg.Model == "" {
+		return nil, ErrNotConfigured
+	}
+	name := config.AIProvider()
+	if name == "" {
+		name = detectProvider(cfg.URL)
+	}
+	return NewProvider(name, cfg)
+}
+
+// detectProvider determines the AI provider based on the API URL
+func detectProvider(apiUrl string) string {
+	if strings.Contains(apiUrl, "generativelanguage.googleapis.com") {
+		return ProviderGemini
+	}
+	if u, err := url.Parse(apiUrl); err == nil {
+		if u.Port() == "11434" || strings.HasSuffix(u.Path, "/api/chat") {
+			return ProviderOllama
+		}
+	}
+	return ProviderOpenAI
+}
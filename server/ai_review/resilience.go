@@ -0,0 +1,132 @@
+package aireview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// requestTimeout bounds a single attempt.
+	requestTimeout = 30 * time.Second
+	// maxAttempts is the number of attempts made before giving up.
+	maxAttempts = 3
+	// retryBackoff is the delay before the first retry. It doubles on every retry.
+	retryBackoff = 500 * time.Millisecond
+	// breakerThreshold is the number of consecutive failures that opens the circuit.
+	breakerThreshold = 5
+	// breakerCooldown is how long the circuit stays open before a trial request is let through.
+	breakerCooldown = time.Minute
+)
+
+// StatusError is returned by HTTP based providers when the API replies with a non-200 status.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// retryable reports whether a failed attempt is worth repeating.
+// Client errors other than rate limiting will fail the same way again.
+func retryable(err error) bool {
+	if errors.Is(err, ErrNotConfigured) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// circuitBreaker stops calling a provider after repeated failures
+// and lets a single trial request through once the cooldown has passed.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.failures >= breakerThreshold {
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// resilientProvider wraps a provider with per-attempt timeouts, retries and a circuit breaker.
+type resilientProvider struct {
+	Provider
+	breaker circuitBreaker
+}
+
+func newResilientProvider(p Provider) *resilientProvider {
+	return &resilientProvider{Provider: p}
+}
+
+func (p *resilientProvider) Chat(ctx context.Context, req Request) (string, error) {
+	if !p.breaker.allow() {
+		return "", ErrCircuitOpen
+	}
+	var err error
+	backoff := retryBackoff
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var resp string
+		resp, err = p.chatOnce(ctx, req)
+		if err == nil {
+			p.breaker.success()
+			return resp, nil
+		}
+		if !retryable(err) || attempt == maxAttempts {
+			break
+		}
+		slog.Warn("AI request failed, retrying", "provider", p.Name(), "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			p.breaker.failure()
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	p.breaker.failure()
+	return "", err
+}
+
+func (p *resilientProvider) chatOnce(ctx context.Context, req Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	return p.Provider.Chat(ctx, req)
+}
@@ -0,0 +1,50 @@
+package aireview
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Verdict is the structured answer of a classification prompt.
+type Verdict struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+func verdictSchema(labels []string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"label": map[string]any{
+				"type": "string",
+				"enum": labels,
+			},
+			"confidence": map[string]any{
+				"type":    "number",
+				"minimum": 0,
+				"maximum": 1,
+			},
+			"reason": map[string]any{
+				"type": "string",
+			},
+		},
+		"required":             []string{"label", "confidence", "reason"},
+		"additionalProperties": false,
+	}
+}
+
+// Classify asks the AI provider to assign one of labels to the prompt.
+// The response is validated against the label set and the confidence is clamped to [0, 1].
+func Classify(ctx context.Context, prompt string, labels []string) (Verdict, error) {
+	var v Verdict
+	if err := ChatJSON(ctx, prompt, "verdict", verdictSchema(labels), &v); err != nil {
+		return Verdict{}, err
+	}
+	if !slices.Contains(labels, v.Label) {
+		return Verdict{}, fmt.Errorf("unexpected label in AI verdict: %q", v.Label)
+	}
+	v.Confidence = min(max(v.Confidence, 0), 1)
+	return v, nil
+}
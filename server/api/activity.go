@@ -1,6 +1,7 @@
 package api
 
 import (
+	"nysoure/server/ctx"
 	"nysoure/server/model"
 	"nysoure/server/service"
 	"strconv"
@@ -14,7 +15,7 @@ func handleGetActivity(c fiber.Ctx) error {
 	if err != nil {
 		return model.NewRequestError("Invalid page number")
 	}
-	activities, totalPages, err := service.GetActivityList(page)
+	activities, totalPages, err := service.GetActivityList(ctx.NewContext(c), page)
 	if err != nil {
 		return err
 	}
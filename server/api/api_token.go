@@ -0,0 +1,52 @@
+package api
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/model"
+	"nysoure/server/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func handleCreateAPIToken(c fiber.Ctx) error {
+	var params service.APITokenParams
+	if err := c.Bind().JSON(&params); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	token, err := service.CreateAPIToken(ctx.NewContext(c), params)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(model.Response[*model.APITokenWithSecret]{
+		Success: true,
+		Data:    token,
+		Message: "API token created, it will not be shown again",
+	})
+}
+
+func handleListAPITokens(c fiber.Ctx) error {
+	tokens, err := service.ListAPITokens(ctx.NewContext(c))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]model.APITokenView]{
+		Success: true,
+		Data:    tokens,
+		Message: "API tokens retrieved successfully",
+	})
+}
+
+func handleRevokeAPIToken(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return model.NewRequestError("Invalid API token ID")
+	}
+	if err := service.RevokeAPIToken(ctx.NewContext(c), uint(id)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Message: "API token revoked successfully",
+	})
+}
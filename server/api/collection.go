@@ -144,10 +144,7 @@ func handleListCollectionResources(c fiber.Ctx) error {
 		page = 1
 	}
 
-	// Get viewer UID (0 if not authenticated)
-	viewerUID, _ := c.Locals("uid").(uint)
-
-	res, total, err := service.ListCollectionResources(uint(id), page, viewerUID)
+	res, total, err := service.ListCollectionResources(ctx.NewContext(c), uint(id), page)
 	if err != nil {
 		return err
 	}
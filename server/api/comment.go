@@ -43,8 +43,7 @@ func GetCommentByID(c fiber.Ctx) error {
 }
 
 func createResourceComment(c fiber.Ctx) error {
-	userID, ok := c.Locals("uid").(uint)
-	if !ok {
+	if _, ok := c.Locals("uid").(uint); !ok {
 		return model.NewRequestError("You must be logged in to comment")
 	}
 	resourceIDStr := c.Params("resourceID")
@@ -62,7 +61,7 @@ func createResourceComment(c fiber.Ctx) error {
 		return model.NewRequestError("Content cannot be empty")
 	}
 
-	comment, err := service.CreateComment(req, userID, uint(resourceID), c.IP(), model.CommentTypeResource, c.Host())
+	comment, err := service.CreateComment(ctx.NewContext(c), req, uint(resourceID), c.IP(), model.CommentTypeResource, c.Host())
 	if err != nil {
 		return err
 	}
@@ -74,8 +73,7 @@ func createResourceComment(c fiber.Ctx) error {
 }
 
 func createReplyComment(c fiber.Ctx) error {
-	userID, ok := c.Locals("uid").(uint)
-	if !ok {
+	if _, ok := c.Locals("uid").(uint); !ok {
 		return model.NewRequestError("You must be logged in to reply")
 	}
 	commentIDStr := c.Params("commentID")
@@ -93,7 +91,7 @@ func createReplyComment(c fiber.Ctx) error {
 		return model.NewRequestError("Content cannot be empty")
 	}
 
-	comment, err := service.CreateComment(req, userID, uint(commentID), c.IP(), model.CommentTypeReply, c.Host())
+	comment, err := service.CreateComment(ctx.NewContext(c), req, uint(commentID), c.IP(), model.CommentTypeReply, c.Host())
 	if err != nil {
 		return err
 	}
@@ -205,8 +203,7 @@ func updateComment(c fiber.Ctx) error {
 }
 
 func deleteComment(c fiber.Ctx) error {
-	userID, ok := c.Locals("uid").(uint)
-	if !ok {
+	if _, ok := c.Locals("uid").(uint); !ok {
 		return model.NewRequestError("You must be logged in to delete comment")
 	}
 	commentIDStr := c.Params("commentID")
@@ -214,7 +211,7 @@ func deleteComment(c fiber.Ctx) error {
 	if err != nil {
 		return model.NewRequestError("Invalid comment ID")
 	}
-	err = service.DeleteComment(uint(commentID), userID)
+	err = service.DeleteComment(ctx.NewContext(c), uint(commentID))
 	if err != nil {
 		return err
 	}
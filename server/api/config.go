@@ -3,9 +3,9 @@ package api
 import (
 	"nysoure/server/config"
 	"nysoure/server/ctx"
+	"nysoure/server/middleware"
 	"nysoure/server/model"
 	"nysoure/server/service"
-	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/log"
@@ -66,14 +66,9 @@ func getFrontendConfig(c fiber.Ctx) error {
 		u, err := service.GetMe(ctx)
 		if err == nil {
 			user = &u.UserView
-			c.Cookie(&fiber.Cookie{
-				Name:     "token",
-				Value:    u.Token,
-				Expires:  time.Now().Add(7 * 24 * time.Hour),
-				HTTPOnly: true,
-				Secure:   true,
-				SameSite: fiber.CookieSameSiteLaxMode,
-			})
+			if u.Token != "" {
+				middleware.SetTokenCookies(c, u.Token, "")
+			}
 		}
 	}
 	random, err := service.RandomCover()
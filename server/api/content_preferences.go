@@ -0,0 +1,69 @@
+package api
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/model"
+	"nysoure/server/service"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// contentPreferencesCookieAge is how long anonymous users keep their content preferences.
+const contentPreferencesCookieAge = 365 * 24 * time.Hour
+
+func handleGetContentPreferences(c fiber.Ctx) error {
+	prefs := service.GetContentPreferences(ctx.NewContext(c))
+	return c.Status(fiber.StatusOK).JSON(model.Response[model.ContentPreferences]{
+		Success: true,
+		Data:    prefs,
+		Message: "Content preferences retrieved successfully",
+	})
+}
+
+func handleSetContentPreferences(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	var req model.ContentPreferences
+	if err := c.Bind().JSON(&req); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	prefs, err := service.SetContentPreferences(context, req)
+	if err != nil {
+		return err
+	}
+	if !context.LoggedIn() {
+		c.Cookie(&fiber.Cookie{
+			Name:     service.ContentPreferencesCookie,
+			Value:    service.EncodeContentPreferences(prefs),
+			Expires:  time.Now().Add(contentPreferencesCookieAge),
+			HTTPOnly: true,
+			Secure:   true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.ContentPreferences]{
+		Success: true,
+		Data:    prefs,
+		Message: "Content preferences updated successfully",
+	})
+}
+
+// handleResetContentPreferences restores the defaults of the site.
+func handleResetContentPreferences(c fiber.Ctx) error {
+	if err := service.ResetContentPreferences(ctx.NewContext(c)); err != nil {
+		return err
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     service.ContentPreferencesCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+		MaxAge:   -1,
+	})
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Message: "Content preferences reset successfully",
+	})
+}
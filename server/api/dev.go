@@ -14,12 +14,60 @@ import (
 func rebuildSearchIndex(c fiber.Ctx) error {
 	err := search.RebuildSearchIndex()
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"error": "Failed to rebuild search index: " + err.Error(),
 		})
 	}
 	return c.JSON(fiber.Map{
-		"message": "Search index rebuilt successfully",
+		"message": "Search index rebuild started",
+	})
+}
+
+func getSearchIndexStatus(c fiber.Ctx) error {
+	return c.JSON(search.GetRebuildStatus())
+}
+
+func reindexResource(c fiber.Ctx) error {
+	type Request struct {
+		ResourceID uint `json:"resource_id"`
+	}
+	var req Request
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+	if err := search.ReindexResource(req.ResourceID); err != nil {
+		slog.Error("Failed to reindex resource", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reindex resource",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message": "Resource reindexed successfully",
+	})
+}
+
+func reindexTag(c fiber.Ctx) error {
+	type Request struct {
+		TagID uint `json:"tag_id"`
+	}
+	var req Request
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+	count, err := search.ReindexTag(req.TagID)
+	if err != nil {
+		slog.Error("Failed to reindex tag", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reindex resources of the tag",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message":   "Resources of the tag reindexed successfully",
+		"resources": count,
 	})
 }
 
@@ -57,6 +105,9 @@ func AddDevAPI(router fiber.Router) {
 	devGroup.Use(middleware.DevMiddleware())
 	{
 		devGroup.Post("/rebuild_search_index", rebuildSearchIndex)
+		devGroup.Get("/search_index_status", getSearchIndexStatus)
+		devGroup.Post("/reindex_resource", reindexResource)
+		devGroup.Post("/reindex_tag", reindexTag)
 		devGroup.Post("/update_resource_release_date", updateResourceReleaseDate)
 	}
 }
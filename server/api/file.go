@@ -27,9 +27,11 @@ func AddFileRoutes(router fiber.Router) {
 		fileGroup.Post("/upload/cancel/:id", cancelUpload)
 		fileGroup.Post("/redirect", createRedirectFile, middleware.NewRequestLimiter(300, 24*time.Hour))
 		fileGroup.Post("/upload/url", createServerDownloadTask)
+		fileGroup.Get("/trash", listTrashedFiles)
 		fileGroup.Get("/:id", getFile)
 		fileGroup.Put("/:id", updateFile)
 		fileGroup.Delete("/:id", deleteFile)
+		fileGroup.Post("/:id/restore", restoreFile)
 		fileGroup.Get("/download/local", downloadLocalFile)
 		fileGroup.Get("/download/:id", downloadFile, middleware.NewDynamicRequestLimiter(config.MaxDownloadsPerDayForSingleIP, 24*time.Hour))
 		fileGroup.Get("/user/:username", listUserFiles)
@@ -217,6 +219,36 @@ func deleteFile(c fiber.Ctx) error {
 	})
 }
 
+func listTrashedFiles(c fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		return model.NewRequestError("Invalid page number")
+	}
+
+	files, totalPages, err := service.ListTrashedFiles(ctx.NewContext(c), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(model.PageResponse[model.TrashedFileView]{
+		Success:    true,
+		Data:       files,
+		TotalPages: totalPages,
+	})
+}
+
+func restoreFile(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	if err := service.RestoreFile(context, c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.JSON(model.Response[any]{
+		Success: true,
+		Message: "File restored successfully",
+	})
+}
+
 func downloadFile(c fiber.Ctx) error {
 	cfToken := c.Query("cf_token")
 	verified := false
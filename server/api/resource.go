@@ -2,10 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/url"
 	"nysoure/server/ctx"
 	"nysoure/server/dao"
 	"nysoure/server/model"
+	"nysoure/server/searchql"
 	"nysoure/server/service"
 	"nysoure/server/utils"
 	"strconv"
@@ -33,6 +35,14 @@ func handleCreateResource(c fiber.Ctx) error {
 	}
 	context := ctx.NewContext(c)
 	id, err := service.CreateResource(context, &params)
+	var duplicateErr *service.DuplicateResourceError
+	if errors.As(err, &duplicateErr) {
+		return c.Status(fiber.StatusConflict).JSON(model.Response[[]service.DuplicateCandidate]{
+			Success: false,
+			Data:    duplicateErr.Candidates,
+			Message: "Possible duplicate resources found, set force to create anyway",
+		})
+	}
 	if err != nil {
 		return err
 	}
@@ -107,7 +117,7 @@ func handleListResources(c fiber.Ctx) error {
 		return model.NewRequestError("Sort parameter out of range")
 	}
 	sort := model.RSort(sortInt)
-	resources, maxPage, err := service.GetResourceList(page, sort)
+	resources, maxPage, err := service.GetResourceList(ctx.NewContext(c), page, sort)
 	if err != nil {
 		return err
 	}
@@ -139,7 +149,7 @@ func handleListResourcesWithTag(c fiber.Ctx) error {
 	if err != nil {
 		return model.NewRequestError("Invalid page number")
 	}
-	resources, totalPages, err := service.GetResourcesWithTag(tag, page)
+	resources, totalPages, err := service.GetResourcesWithTag(ctx.NewContext(c), tag, page)
 	if err != nil {
 		return err
 	}
@@ -167,18 +177,52 @@ func handleSearchResources(c fiber.Ctx) error {
 	if err != nil {
 		return model.NewRequestError("Invalid page number")
 	}
-	resources, totalPages, err := service.SearchResource(query, page)
+	sort := model.RSortRelevance
+	if sortStr := c.Query("sort"); sortStr != "" {
+		sortInt, err := strconv.Atoi(sortStr)
+		if err != nil {
+			return model.NewRequestError("Invalid sort parameter")
+		}
+		if sortInt < 0 || sortInt > int(model.RSortRelevance) {
+			return model.NewRequestError("Sort parameter out of range")
+		}
+		sort = model.RSort(sortInt)
+	}
+	resources, totalPages, facets, err := service.SearchResource(ctx.NewContext(c), query, sort, page)
+	var parseErr *searchql.ParseError
+	if errors.As(err, &parseErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(model.Response[*searchql.ParseError]{
+			Success: false,
+			Data:    parseErr,
+			Message: "Invalid search query: " + parseErr.Error(),
+		})
+	}
 	if err != nil {
 		return err
 	}
 	if resources == nil {
 		resources = []model.ResourceView{}
 	}
-	return c.Status(fiber.StatusOK).JSON(model.PageResponse[model.ResourceView]{
-		Success:    true,
-		Data:       resources,
-		TotalPages: totalPages,
-		Message:    "Resources retrieved successfully",
+	return c.Status(fiber.StatusOK).JSON(model.SearchPageResponse{
+		PageResponse: model.PageResponse[model.ResourceView]{
+			Success:    true,
+			Data:       resources,
+			TotalPages: totalPages,
+			Message:    "Resources retrieved successfully",
+		},
+		Facets: facets,
+	})
+}
+
+func handleSuggestSearch(c fiber.Ctx) error {
+	suggestions, err := service.SuggestSearch(c.Query("keyword"))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.SearchSuggestions]{
+		Success: true,
+		Data:    suggestions,
+		Message: "Suggestions retrieved successfully",
 	})
 }
 
@@ -244,7 +288,7 @@ func handleUpdateResource(c fiber.Ctx) error {
 
 func handleGetRandomResource(c fiber.Ctx) error {
 	host := c.Hostname()
-	resource, err := service.RandomResource(host)
+	resource, err := service.RandomResource(ctx.NewContext(c), host)
 	if err != nil {
 		return err
 	}
@@ -297,6 +341,49 @@ func handleGetInfoFromVndb(c fiber.Ctx) error {
 	})
 }
 
+// handleImportFromVndb builds a resource draft from VNDB. It is a POST because the
+// images of the visual novel are downloaded and stored.
+func handleImportFromVndb(c fiber.Ctx) error {
+	var req struct {
+		VnID string `json:"vnid"`
+	}
+	if err := c.Bind().JSON(&req); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	if req.VnID == "" {
+		return model.NewRequestError("VNDB ID is required")
+	}
+	context := ctx.NewContext(c)
+	params, err := service.ImportFromVndb(context, req.VnID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*service.ResourceParams]{
+		Success: true,
+		Data:    params,
+		Message: "Resource imported from VNDB successfully",
+	})
+}
+
+func handleSuggestResourceDraft(c fiber.Ctx) error {
+	var params service.ResourceDraftParams
+	body := c.Body()
+	err := json.Unmarshal(body, &params)
+	if err != nil {
+		return model.NewRequestError("Invalid request body")
+	}
+	context := ctx.NewContext(c)
+	suggestion, err := service.SuggestResourceDraft(context, &params)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[service.ResourceDraftSuggestion]{
+		Success: true,
+		Data:    *suggestion,
+		Message: "Suggestions generated successfully",
+	})
+}
+
 func handleUpdateCharacterImage(c fiber.Ctx) error {
 	resourceIdStr := c.Params("resourceId")
 	characterIdStr := c.Params("characterId")
@@ -493,22 +580,361 @@ func handleUpdateResourceImage(c fiber.Ctx) error {
 	})
 }
 
+func handleListMetadataProposals(c fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return model.NewRequestError("Invalid page number")
+	}
+	proposals, totalPages, err := service.ListMetadataProposals(ctx.NewContext(c), page)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.PageResponse[model.MetadataProposalView]{
+		Success:    true,
+		Data:       proposals,
+		TotalPages: totalPages,
+		Message:    "Proposals retrieved successfully",
+	})
+}
+
+func handleGetMetadataProposal(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	proposal, err := service.GetPendingMetadataProposal(ctx.NewContext(c), uint(id))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.MetadataProposalView]{
+		Success: true,
+		Data:    proposal,
+		Message: "Proposal retrieved successfully",
+	})
+}
+
+func handleAcceptMetadataProposal(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid proposal ID")
+	}
+	if err := service.AcceptMetadataProposal(ctx.NewContext(c), uint(id)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Proposal accepted successfully",
+	})
+}
+
+func handleRejectMetadataProposal(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid proposal ID")
+	}
+	if err := service.RejectMetadataProposal(ctx.NewContext(c), uint(id)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Proposal rejected successfully",
+	})
+}
+
+func handleGetDuplicateReport(c fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return model.NewRequestError("Invalid page number")
+	}
+	pairs, totalPages, err := service.GetDuplicateReport(ctx.NewContext(c), page)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.PageResponse[service.DuplicatePair]{
+		Success:    true,
+		Data:       pairs,
+		TotalPages: totalPages,
+		Message:    "Duplicate report retrieved successfully",
+	})
+}
+
+func handleMergeResources(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	var params struct {
+		TargetID uint `json:"target_id"`
+	}
+	if err := json.Unmarshal(c.Body(), &params); err != nil || params.TargetID == 0 {
+		return model.NewRequestError("Invalid request body")
+	}
+	if err := service.MergeResources(ctx.NewContext(c), uint(id), params.TargetID); err != nil {
+		return err
+	}
+	updateSiteMapAndRss(c.BaseURL())
+	return c.Status(fiber.StatusOK).JSON(model.Response[uint]{
+		Success: true,
+		Data:    params.TargetID,
+		Message: "Resources merged successfully",
+	})
+}
+
+func handleListResourceRevisions(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return model.NewRequestError("Invalid page number")
+	}
+	revisions, totalPages, err := service.ListResourceRevisions(ctx.NewContext(c), uint(id), page)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.PageResponse[model.ResourceRevisionView]{
+		Success:    true,
+		Data:       revisions,
+		TotalPages: totalPages,
+		Message:    "Revisions retrieved successfully",
+	})
+}
+
+func handleGetResourceRevision(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	revisionID, err := strconv.Atoi(c.Params("revisionId"))
+	if err != nil {
+		return model.NewRequestError("Invalid revision ID")
+	}
+	revision, err := service.GetResourceRevision(ctx.NewContext(c), uint(id), uint(revisionID))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*service.ResourceRevisionDetail]{
+		Success: true,
+		Data:    revision,
+		Message: "Revision retrieved successfully",
+	})
+}
+
+func handleDiffResourceRevisions(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		return model.NewRequestError("Invalid revision ID")
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		return model.NewRequestError("Invalid revision ID")
+	}
+	diffs, err := service.DiffResourceRevisions(ctx.NewContext(c), uint(id), uint(from), uint(to))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]service.RevisionFieldDiff]{
+		Success: true,
+		Data:    diffs,
+		Message: "Revisions compared successfully",
+	})
+}
+
+func handleRollbackResource(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	revisionID, err := strconv.Atoi(c.Params("revisionId"))
+	if err != nil {
+		return model.NewRequestError("Invalid revision ID")
+	}
+	if err := service.RollbackResource(ctx.NewContext(c), uint(id), uint(revisionID)); err != nil {
+		return err
+	}
+	updateSiteMapAndRss(c.BaseURL())
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Resource rolled back successfully",
+	})
+}
+
+func handleListTrashedResources(c fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return model.NewRequestError("Invalid page number")
+	}
+	resources, totalPages, err := service.ListTrashedResources(ctx.NewContext(c), page)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.PageResponse[model.TrashedResourceView]{
+		Success:    true,
+		Data:       resources,
+		TotalPages: totalPages,
+		Message:    "Trash retrieved successfully",
+	})
+}
+
+func handleRestoreResource(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	if err := service.RestoreResource(ctx.NewContext(c), uint(id)); err != nil {
+		return err
+	}
+	updateSiteMapAndRss(c.BaseURL())
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Resource restored successfully",
+	})
+}
+
+func handleSubmitEditSuggestion(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid resource ID")
+	}
+	var params service.EditSuggestionParams
+	if err := json.Unmarshal(c.Body(), &params); err != nil {
+		return model.NewRequestError("Invalid request body")
+	}
+	suggestionID, err := service.SubmitEditSuggestion(ctx.NewContext(c), uint(id), &params)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[uint]{
+		Success: true,
+		Data:    suggestionID,
+		Message: "Suggestion submitted successfully",
+	})
+}
+
+func handleListEditSuggestions(c fiber.Ctx) error {
+	var resourceID int
+	if idStr := c.Params("id"); idStr != "" {
+		var err error
+		resourceID, err = strconv.Atoi(idStr)
+		if err != nil {
+			return model.NewRequestError("Invalid resource ID")
+		}
+	}
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return model.NewRequestError("Invalid page number")
+	}
+	suggestions, totalPages, err := service.ListEditSuggestions(ctx.NewContext(c), uint(resourceID), page)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.PageResponse[model.EditSuggestionView]{
+		Success:    true,
+		Data:       suggestions,
+		TotalPages: totalPages,
+		Message:    "Suggestions retrieved successfully",
+	})
+}
+
+func handleGetEditSuggestion(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid suggestion ID")
+	}
+	suggestion, err := service.GetEditSuggestion(ctx.NewContext(c), uint(id))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*service.EditSuggestionDetail]{
+		Success: true,
+		Data:    suggestion,
+		Message: "Suggestion retrieved successfully",
+	})
+}
+
+func handleAcceptEditSuggestion(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid suggestion ID")
+	}
+	if err := service.AcceptEditSuggestion(ctx.NewContext(c), uint(id)); err != nil {
+		return err
+	}
+	updateSiteMapAndRss(c.BaseURL())
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Suggestion accepted successfully",
+	})
+}
+
+func handleRejectEditSuggestion(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid suggestion ID")
+	}
+	var params struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(c.Body(), &params); err != nil {
+		return model.NewRequestError("Invalid request body")
+	}
+	if err := service.RejectEditSuggestion(ctx.NewContext(c), uint(id), params.Reason); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Suggestion rejected successfully",
+	})
+}
+
 func AddResourceRoutes(api fiber.Router) {
 	resource := api.Group("/resource")
 	{
 		resource.Post("/", handleCreateResource)
+		resource.Post("/draft/suggest", handleSuggestResourceDraft)
 		resource.Get("/search", handleSearchResources)
+		resource.Get("/search/suggest", handleSuggestSearch)
 		resource.Get("/", handleListResources)
 		resource.Get("/random", handleGetRandomResource)
 		resource.Get("/pinned", handleGetPinnedResources)
 		resource.Get("/vndb/info", handleGetInfoFromVndb)
+		resource.Post("/vndb/import", handleImportFromVndb)
+		resource.Get("/metadata/proposals", handleListMetadataProposals)
+		resource.Get("/duplicates/report", handleGetDuplicateReport)
+		resource.Get("/trash", handleListTrashedResources)
+		resource.Get("/suggestions", handleListEditSuggestions)
+		resource.Get("/suggestions/:id", handleGetEditSuggestion)
+		resource.Post("/suggestions/:id/accept", handleAcceptEditSuggestion)
+		resource.Post("/suggestions/:id/reject", handleRejectEditSuggestion)
+		resource.Post("/metadata/proposals/:id/accept", handleAcceptMetadataProposal)
+		resource.Post("/metadata/proposals/:id/reject", handleRejectMetadataProposal)
 		resource.Get("/characters/low-resolution", handleGetLowResolutionCharacters)
 		resource.Get("/images/low-resolution", handleGetLowResolutionResourceImages)
 		resource.Get("/:id", handleGetResource)
+		resource.Get("/:id/metadata/proposal", handleGetMetadataProposal)
+		resource.Get("/:id/revisions", handleListResourceRevisions)
+		resource.Get("/:id/revisions/diff", handleDiffResourceRevisions)
+		resource.Get("/:id/revisions/:revisionId", handleGetResourceRevision)
+		resource.Post("/:id/revisions/:revisionId/rollback", handleRollbackResource)
 		resource.Delete("/:id", handleDeleteResource)
 		resource.Get("/tag/:tag", handleListResourcesWithTag)
 		resource.Get("/user/:username", handleGetResourcesWithUser)
 		resource.Post("/:id", handleUpdateResource)
+		resource.Post("/:id/merge", handleMergeResources)
+		resource.Post("/:id/restore", handleRestoreResource)
+		resource.Get("/:id/suggestions", handleListEditSuggestions)
+		resource.Post("/:id/suggestions", handleSubmitEditSuggestion)
 		resource.Put("/:resourceId/character/:characterId/image", handleUpdateCharacterImage)
 		resource.Put("/:resourceId/image/:oldImageId", handleUpdateResourceImage)
 	}
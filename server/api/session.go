@@ -0,0 +1,76 @@
+package api
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/middleware"
+	"nysoure/server/model"
+	"nysoure/server/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// handleRefreshSession returns a new access token and refresh token for a refresh token,
+// given in the form or kept in a cookie by the browser.
+func handleRefreshSession(c fiber.Ctx) error {
+	refreshToken := c.FormValue("refresh_token")
+	fromCookie := false
+	if refreshToken == "" {
+		refreshToken = c.Cookies("refresh_token")
+		fromCookie = true
+	}
+	user, err := service.RefreshSession(refreshToken, c.IP())
+	if err != nil {
+		return err
+	}
+	if fromCookie {
+		middleware.SetTokenCookies(c, user.Token, user.RefreshToken)
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[model.UserViewWithToken]{
+		Success: true,
+		Data:    user,
+		Message: "Session refreshed successfully",
+	})
+}
+
+func handleListSessions(c fiber.Ctx) error {
+	sessions, err := service.ListSessions(ctx.NewContext(c))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]model.SessionView]{
+		Success: true,
+		Data:    sessions,
+		Message: "Sessions retrieved successfully",
+	})
+}
+
+func handleRevokeSession(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return model.NewRequestError("Invalid session ID")
+	}
+	context := ctx.NewContext(c)
+	if err := service.RevokeSession(context, uint(id)); err != nil {
+		return err
+	}
+	if sid, ok := context.SessionID(); ok && sid == uint(id) {
+		middleware.ClearTokenCookies(c)
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
+// handleLogoutEverywhere revokes all sessions of the user, including the current one.
+func handleLogoutEverywhere(c fiber.Ctx) error {
+	if err := service.LogoutEverywhere(ctx.NewContext(c)); err != nil {
+		return err
+	}
+	middleware.ClearTokenCookies(c)
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Message: "Logged out of all devices",
+	})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"nysoure/server/ctx"
+	"nysoure/server/model"
+	"nysoure/server/searchql"
+	"nysoure/server/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func handleCreateSubscription(c fiber.Ctx) error {
+	var params service.SubscriptionParams
+	if err := json.Unmarshal(c.Body(), &params); err != nil {
+		return model.NewRequestError("Invalid request body")
+	}
+	subscription, err := service.CreateSubscription(ctx.NewContext(c), &params)
+	var parseErr *searchql.ParseError
+	if errors.As(err, &parseErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(model.Response[*searchql.ParseError]{
+			Success: false,
+			Data:    parseErr,
+			Message: "Invalid search query: " + parseErr.Error(),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.SubscriptionView]{
+		Success: true,
+		Data:    subscription,
+		Message: "Subscription created successfully",
+	})
+}
+
+func handleListSubscriptions(c fiber.Ctx) error {
+	subscriptions, err := service.ListSubscriptions(ctx.NewContext(c))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]model.SubscriptionView]{
+		Success: true,
+		Data:    subscriptions,
+		Message: "Subscriptions retrieved successfully",
+	})
+}
+
+func handleDeleteSubscription(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid subscription ID")
+	}
+	if err := service.DeleteSubscription(ctx.NewContext(c), uint(id)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Subscription deleted successfully",
+	})
+}
+
+func handleGetSubscriptionFeed(c fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return model.NewRequestError("Invalid page number")
+	}
+	all := c.Query("all") == "true"
+	feed, totalPages, err := service.GetSubscriptionFeed(ctx.NewContext(c), all, page)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.PageResponse[model.ActivityView]{
+		Success:    true,
+		Data:       feed,
+		TotalPages: totalPages,
+		Message:    "Feed retrieved successfully",
+	})
+}
+
+func handleVisitSubscriptionFeed(c fiber.Ctx) error {
+	if err := service.VisitSubscriptionFeed(ctx.NewContext(c)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Feed marked as read",
+	})
+}
+
+func AddSubscriptionRoutes(router fiber.Router) {
+	subscription := router.Group("/subscription")
+	{
+		subscription.Get("/", handleListSubscriptions)
+		subscription.Post("/", handleCreateSubscription)
+		subscription.Get("/feed", handleGetSubscriptionFeed)
+		subscription.Post("/feed/visit", handleVisitSubscriptionFeed)
+		subscription.Delete("/:id", handleDeleteSubscription)
+	}
+}
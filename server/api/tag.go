@@ -211,6 +211,136 @@ func editTagAlias(c fiber.Ctx) error {
 	})
 }
 
+func handleGetTagRelations(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid tag ID")
+	}
+	relations, err := service.GetTagRelations(uint(id))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TagRelationsView]{
+		Success: true,
+		Data:    relations,
+		Message: "Tag relations retrieved successfully",
+	})
+}
+
+func handleSetTagParent(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid tag ID")
+	}
+	var req struct {
+		// ParentID is null to remove the parent.
+		ParentID *uint `json:"parent_id"`
+	}
+	if err := c.Bind().JSON(&req); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	relations, err := service.SetTagParent(context, uint(id), req.ParentID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TagRelationsView]{
+		Success: true,
+		Data:    relations,
+		Message: "Tag parent updated successfully",
+	})
+}
+
+func handleAddTagImplication(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid tag ID")
+	}
+	var req struct {
+		TagID uint `json:"tag_id"`
+	}
+	if err := c.Bind().JSON(&req); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	relations, err := service.AddTagImplication(context, uint(id), req.TagID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TagRelationsView]{
+		Success: true,
+		Data:    relations,
+		Message: "Tag implication added successfully",
+	})
+}
+
+func handleRemoveTagImplication(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid tag ID")
+	}
+	impliedID, err := strconv.Atoi(c.Params("implied"))
+	if err != nil {
+		return model.NewRequestError("Invalid implied tag ID")
+	}
+	relations, err := service.RemoveTagImplication(context, uint(id), uint(impliedID))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TagRelationsView]{
+		Success: true,
+		Data:    relations,
+		Message: "Tag implication removed successfully",
+	})
+}
+
+func handleMergeTag(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid tag ID")
+	}
+	var req struct {
+		TargetID uint `json:"target_id"`
+	}
+	if err := c.Bind().JSON(&req); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	t, err := service.MergeTags(context, uint(id), req.TargetID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[model.TagView]{
+		Success: true,
+		Data:    *t,
+		Message: "Tags merged successfully",
+	})
+}
+
+func handleRenameTag(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return model.NewRequestError("Invalid tag ID")
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.Bind().JSON(&req); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	t, err := service.RenameTag(context, uint(id), req.Name)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[model.TagView]{
+		Success: true,
+		Data:    *t,
+		Message: "Tag renamed successfully",
+	})
+}
+
 func AddTagRoutes(api fiber.Router) {
 	tag := api.Group("/tag")
 	{
@@ -219,6 +349,12 @@ func AddTagRoutes(api fiber.Router) {
 		tag.Delete("/:id", handleDeleteTag)
 		tag.Put("/:id/alias", editTagAlias)
 		tag.Put("/:id/info", handleSetTagInfo)
+		tag.Put("/:id/name", handleRenameTag)
+		tag.Post("/:id/merge", handleMergeTag)
+		tag.Get("/:id/relations", handleGetTagRelations)
+		tag.Put("/:id/parent", handleSetTagParent)
+		tag.Post("/:id/implications", handleAddTagImplication)
+		tag.Delete("/:id/implications/:implied", handleRemoveTagImplication)
 		tag.Get("/:name", handleGetTagByName)
 		tag.Get("/", getAllTags)
 		tag.Post("/batch", getOrCreateTags)
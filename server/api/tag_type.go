@@ -0,0 +1,77 @@
+package api
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/model"
+	"nysoure/server/service"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func handleListTagTypes(c fiber.Ctx) error {
+	types, err := service.ListTagTypes()
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]model.TagTypeView]{
+		Success: true,
+		Data:    types,
+		Message: "Tag types retrieved successfully",
+	})
+}
+
+func handleCreateTagType(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	var params service.TagTypeParams
+	if err := c.Bind().JSON(&params); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	t, err := service.CreateTagType(context, &params)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TagTypeView]{
+		Success: true,
+		Data:    t,
+		Message: "Tag type created successfully",
+	})
+}
+
+func handleUpdateTagType(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	var params service.TagTypeParams
+	if err := c.Bind().JSON(&params); err != nil {
+		return model.NewRequestError("Invalid request format")
+	}
+	t, err := service.UpdateTagType(context, c.Params("key"), &params)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TagTypeView]{
+		Success: true,
+		Data:    t,
+		Message: "Tag type updated successfully",
+	})
+}
+
+func handleDeleteTagType(c fiber.Ctx) error {
+	context := ctx.NewContext(c)
+	if err := service.DeleteTagType(context, c.Params("key")); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Data:    nil,
+		Message: "Tag type deleted successfully",
+	})
+}
+
+func AddTagTypeRoutes(api fiber.Router) {
+	tagType := api.Group("/tag_type")
+	{
+		tagType.Get("/", handleListTagTypes)
+		tagType.Post("/", handleCreateTagType)
+		tagType.Put("/:key", handleUpdateTagType)
+		tagType.Delete("/:key", handleDeleteTagType)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/middleware"
+	"nysoure/server/model"
+	"nysoure/server/service"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// handleUserLoginTwoFactor completes a login with the challenge token returned by
+// handleUserLogin and a code.
+func handleUserLoginTwoFactor(c fiber.Ctx) error {
+	challengeToken := c.FormValue("challenge_token")
+	code := c.FormValue("code")
+	if challengeToken == "" || code == "" {
+		return model.NewRequestError("Challenge token and code are required")
+	}
+	user, err := service.LoginTwoFactor(challengeToken, code, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return err
+	}
+	middleware.SetTokenCookies(c, user.Token, user.RefreshToken)
+	return c.Status(fiber.StatusOK).JSON(model.Response[model.UserViewWithToken]{
+		Success: true,
+		Data:    user,
+		Message: "Login successful",
+	})
+}
+
+func handleEnrollTwoFactor(c fiber.Ctx) error {
+	enrollment, err := service.EnrollTwoFactor(ctx.NewContext(c))
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[*model.TwoFactorEnrollment]{
+		Success: true,
+		Data:    enrollment,
+		Message: "Scan the code with your authenticator app, then verify a code to enable two-factor authentication",
+	})
+}
+
+func handleVerifyTwoFactor(c fiber.Ctx) error {
+	code := c.FormValue("code")
+	if code == "" {
+		return model.NewRequestError("Code is required")
+	}
+	codes, err := service.VerifyTwoFactor(ctx.NewContext(c), code)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]string]{
+		Success: true,
+		Data:    codes,
+		Message: "Two-factor authentication enabled, keep the recovery codes in a safe place",
+	})
+}
+
+func handleDisableTwoFactor(c fiber.Ctx) error {
+	password := c.FormValue("password")
+	code := c.FormValue("code")
+	if password == "" || code == "" {
+		return model.NewRequestError("Password and code are required")
+	}
+	if err := service.DisableTwoFactor(ctx.NewContext(c), password, code); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+func handleRegenerateRecoveryCodes(c fiber.Ctx) error {
+	code := c.FormValue("code")
+	if code == "" {
+		return model.NewRequestError("Code is required")
+	}
+	codes, err := service.RegenerateRecoveryCodes(ctx.NewContext(c), code)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(model.Response[[]string]{
+		Success: true,
+		Data:    codes,
+		Message: "Recovery codes generated, the previous ones no longer work",
+	})
+}
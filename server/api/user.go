@@ -22,19 +22,12 @@ func handleUserRegister(c fiber.Ctx) error {
 	if username == "" || password == "" {
 		return model.NewRequestError("Username and password are required")
 	}
-	user, err := service.CreateUser(username, password, cfToken)
+	user, err := service.CreateUser(username, password, cfToken, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		return err
 	}
 	stat.RecordRegister()
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    user.Token,
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-	})
+	middleware.SetTokenCookies(c, user.Token, user.RefreshToken)
 	return c.Status(fiber.StatusOK).JSON(model.Response[model.UserViewWithToken]{
 		Success: true,
 		Data:    user,
@@ -48,18 +41,18 @@ func handleUserLogin(c fiber.Ctx) error {
 	if username == "" || password == "" {
 		return model.NewRequestError("Username and password are required")
 	}
-	user, err := service.Login(username, password)
+	user, challenge, err := service.Login(username, password, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		return err
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    user.Token,
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-	})
+	if challenge != nil {
+		return c.Status(fiber.StatusOK).JSON(model.Response[*model.TwoFactorChallenge]{
+			Success: true,
+			Data:    challenge,
+			Message: "Two-factor authentication code required",
+		})
+	}
+	middleware.SetTokenCookies(c, user.Token, user.RefreshToken)
 	return c.Status(fiber.StatusOK).JSON(model.Response[model.UserViewWithToken]{
 		Success: true,
 		Data:    user,
@@ -68,15 +61,10 @@ func handleUserLogin(c fiber.Ctx) error {
 }
 
 func handleUserLogout(c fiber.Ctx) error {
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-		MaxAge:   -1,
-	})
+	if err := service.Logout(ctx.NewContext(c)); err != nil {
+		return err
+	}
+	middleware.ClearTokenCookies(c)
 	return c.Status(fiber.StatusOK).JSON(model.Response[any]{
 		Success: true,
 		Message: "Logout successful",
@@ -89,18 +77,11 @@ func handleUserChangePassword(c fiber.Ctx) error {
 	if oldPassword == "" || newPassword == "" {
 		return model.NewRequestError("Old and new passwords are required")
 	}
-	user, err := service.ChangePassword(ctx.NewContext(c), oldPassword, newPassword)
+	user, err := service.ChangePassword(ctx.NewContext(c), oldPassword, newPassword, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		return err
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    user.Token,
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-	})
+	middleware.SetTokenCookies(c, user.Token, user.RefreshToken)
 	return c.Status(fiber.StatusOK).JSON(model.Response[model.UserViewWithToken]{
 		Success: true,
 		Data:    user,
@@ -333,14 +314,9 @@ func handleGetMe(c fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    user.Token,
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-	})
+	if user.Token != "" {
+		middleware.SetTokenCookies(c, user.Token, "")
+	}
 	return c.Status(fiber.StatusOK).JSON(model.Response[model.UserViewWithToken]{
 		Success: true,
 		Data:    user,
@@ -395,7 +371,19 @@ func AddUserRoutes(r fiber.Router) {
 	u := r.Group("user")
 	u.Post("/register", handleUserRegister, middleware.NewRequestLimiter(5, time.Hour))
 	u.Post("/login", handleUserLogin)
+	u.Post("/login/2fa", handleUserLoginTwoFactor)
 	u.Post("/logout", handleUserLogout)
+	u.Post("/logout_all", handleLogoutEverywhere)
+	u.Post("/refresh", handleRefreshSession)
+	u.Get("/sessions", handleListSessions)
+	u.Delete("/sessions/:id", handleRevokeSession)
+	u.Get("/api_tokens", handleListAPITokens)
+	u.Post("/api_tokens", handleCreateAPIToken)
+	u.Delete("/api_tokens/:id", handleRevokeAPIToken)
+	u.Post("/2fa/enroll", handleEnrollTwoFactor)
+	u.Post("/2fa/verify", handleVerifyTwoFactor)
+	u.Post("/2fa/disable", handleDisableTwoFactor)
+	u.Post("/2fa/recovery_codes", handleRegenerateRecoveryCodes)
 	u.Put("/avatar", handleUserChangeAvatar)
 	u.Post("/password", handleUserChangePassword)
 	u.Get("/avatar/:id", handleGetUserAvatar)
@@ -407,6 +395,9 @@ func AddUserRoutes(r fiber.Router) {
 	u.Get("/info", handleGetUserInfo)
 	u.Post("/username", handleChangeUsername)
 	u.Post("/bio", handleSetUserBio)
+	u.Get("/content_preferences", handleGetContentPreferences)
+	u.Put("/content_preferences", handleSetContentPreferences)
+	u.Delete("/content_preferences", handleResetContentPreferences)
 	u.Get("/me", handleGetMe)
 	u.Get("/banned", handleListBannedUsers)
 	u.Post("/unban", handleUnbanUser)
@@ -6,10 +6,14 @@ import (
 	"nysoure/server/utils"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 var config *ServerConfig
 
+// MaxPreferenceTags is the maximum number of hidden or blurred tags in content preferences.
+const MaxPreferenceTags = 100
+
 type ServerConfig struct {
 	// MaxUploadingSizeInMB is the maximum size of files that are being uploaded at the same time.
 	MaxUploadingSizeInMB int `json:"max_uploading_size_in_mb"`
@@ -37,6 +41,19 @@ type ServerConfig struct {
 	UploadPrompt string `json:"upload_prompt"`
 	// PinnedResources is a list of resource IDs that are pinned to the top of the page.
 	PinnedResources []uint `json:"pinned_resources"`
+	// TrashRetentionDays is the number of days deleted resources and files are kept in the trash
+	// before they are purged. Zero means the default of 30 days.
+	TrashRetentionDays int `json:"trash_retention_days"`
+	// DefaultHiddenTags are hidden from users who have not chosen their own content preferences.
+	DefaultHiddenTags []uint `json:"default_hidden_tags"`
+	// DefaultBlurredTags are blurred for users who have not chosen their own content preferences.
+	DefaultBlurredTags []uint `json:"default_blurred_tags"`
+	// HideNsfwByDefault hides NSFW images from users who have not opted in to them.
+	HideNsfwByDefault bool `json:"hide_nsfw_by_default"`
+	// RequireTwoFactorForAdmins limits admins without two-factor authentication to the permissions of verified users.
+	RequireTwoFactorForAdmins bool `json:"require_two_factor_for_admins"`
+	// RequireTwoFactorForUploaders does the same for uploaders and admins.
+	RequireTwoFactorForUploaders bool `json:"require_two_factor_for_uploaders"`
 }
 
 func (c *ServerConfig) Validate() error {
@@ -58,6 +75,12 @@ func (c *ServerConfig) Validate() error {
 	if len(c.PinnedResources) > 8 {
 		return errors.New("PinnedResources must not exceed 8 items")
 	}
+	if c.TrashRetentionDays < 0 {
+		return errors.New("TrashRetentionDays must not be negative")
+	}
+	if len(c.DefaultHiddenTags) > MaxPreferenceTags || len(c.DefaultBlurredTags) > MaxPreferenceTags {
+		return errors.New("DefaultHiddenTags and DefaultBlurredTags must not exceed 100 items")
+	}
 	return nil
 }
 
@@ -77,6 +100,7 @@ func init() {
 			MaxNormalUserUploadSizeInMB:   16,
 			UploadPrompt:                  "You can upload your files here.",
 			PinnedResources:               []uint{},
+			TrashRetentionDays:            30,
 		}
 	} else {
 		data, err := os.ReadFile(p)
@@ -159,6 +183,34 @@ func PinnedResources() []uint {
 	return config.PinnedResources
 }
 
+func TrashRetention() time.Duration {
+	days := config.TrashRetentionDays
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func DefaultHiddenTags() []uint {
+	return config.DefaultHiddenTags
+}
+
+func DefaultBlurredTags() []uint {
+	return config.DefaultBlurredTags
+}
+
+func HideNsfwByDefault() bool {
+	return config.HideNsfwByDefault
+}
+
+func RequireTwoFactorForAdmins() bool {
+	return config.RequireTwoFactorForAdmins
+}
+
+func RequireTwoFactorForUploaders() bool {
+	return config.RequireTwoFactorForUploaders
+}
+
 func PrivateDeployment() bool {
 	return os.Getenv("PRIVATE_DEPLOYMENT") == "true"
 }
@@ -182,3 +234,15 @@ func OpenAIApiKey() string {
 func OpenAIModel() string {
 	return os.Getenv("OPENAI_MODEL")
 }
+
+// AIProvider returns the name of the AI provider to use.
+// If empty, the provider is detected from OpenAIUrl.
+func AIProvider() string {
+	return os.Getenv("AI_PROVIDER")
+}
+
+// SearchEngine returns the name of the search engine to use.
+// If empty, the embedded bleve index is used.
+func SearchEngine() string {
+	return os.Getenv("SEARCH_ENGINE")
+}
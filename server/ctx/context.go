@@ -17,10 +17,13 @@ type Context interface {
 	MustUserID() uint
 	MaybeUserID() uint
 	LoggedIn() bool
+	SessionID() (uint, bool)
+	HasScope(scope model.TokenScope) bool
 	IsRealUser() bool
 	IsDevAccess() bool
 	UserPermission() model.Permission
 	UserCreatedAt() time.Time
+	ContentPreferences() (*model.ContentPreferences, bool)
 	Host() string
 }
 
@@ -74,6 +77,22 @@ func (c *contextImpl) LoggedIn() bool {
 	return ok
 }
 
+// SessionID returns the session of the access token of the request.
+func (c *contextImpl) SessionID() (uint, bool) {
+	sid, ok := c.fiberCtx.Locals("session_id").(uint)
+	return sid, ok
+}
+
+// HasScope reports whether the request may perform an action. Only requests made
+// with an API token are limited, to the scopes of the token.
+func (c *contextImpl) HasScope(scope model.TokenScope) bool {
+	token, ok := c.fiberCtx.Locals("api_token").(*model.APIToken)
+	if !ok {
+		return true
+	}
+	return token.HasScope(scope)
+}
+
 func (c *contextImpl) IsRealUser() bool {
 	return c.fiberCtx.Locals("real_user").(bool)
 }
@@ -94,6 +113,13 @@ func (c *contextImpl) UserCreatedAt() time.Time {
 	return c.fiberCtx.Locals("created_at").(time.Time)
 }
 
+// ContentPreferences returns the preferences of the user loaded with the request, nil if
+// the user has kept the defaults of the site. It reports false if they were not loaded.
+func (c *contextImpl) ContentPreferences() (*model.ContentPreferences, bool) {
+	prefs, ok := c.fiberCtx.Locals("content_preferences").(*model.ContentPreferences)
+	return prefs, ok
+}
+
 func (c *contextImpl) Host() string {
 	return c.fiberCtx.Hostname()
 }
@@ -125,8 +151,14 @@ func (f *fakeContext) UserID() (uint, bool)             { return f.userID, true
 func (f *fakeContext) MustUserID() uint                 { return f.userID }
 func (f *fakeContext) MaybeUserID() uint                { return f.userID }
 func (f *fakeContext) LoggedIn() bool                   { return true }
+func (f *fakeContext) SessionID() (uint, bool)          { return 0, false }
+func (f *fakeContext) HasScope(model.TokenScope) bool   { return true }
 func (f *fakeContext) IsRealUser() bool                 { return false }
 func (f *fakeContext) IsDevAccess() bool                { return false }
 func (f *fakeContext) UserPermission() model.Permission { return f.permission }
 func (f *fakeContext) UserCreatedAt() time.Time         { return f.createdAt }
 func (f *fakeContext) Host() string                     { return "" }
+
+func (f *fakeContext) ContentPreferences() (*model.ContentPreferences, bool) {
+	return nil, false
+}
@@ -68,23 +68,47 @@ func AddNewFileActivity(userID, fileID uint) error {
 	return db.Create(activity).Error
 }
 
+// resourceActivityTypes refer to a resource, and are deleted and restored with it.
+var resourceActivityTypes = []model.ActivityType{
+	model.ActivityTypeNewResource,
+	model.ActivityTypeUpdateResource,
+	model.ActivityTypeSubscriptionResource,
+}
+
+// fileActivityTypes refer to a file, and are deleted and restored with it.
+var fileActivityTypes = []model.ActivityType{
+	model.ActivityTypeNewFile,
+	model.ActivityTypeSubscriptionFile,
+}
+
 func DeleteResourceActivity(resourceID uint) error {
-	return db.Where("ref_id = ? AND (type = ? OR type = ?)", resourceID, model.ActivityTypeNewResource, model.ActivityTypeUpdateResource).Delete(&model.Activity{}).Error
+	return db.Where("ref_id = ? AND type IN ?", resourceID, resourceActivityTypes).Delete(&model.Activity{}).Error
 }
 
 func DeleteCommentActivity(commentID uint) error {
 	return db.Where("ref_id = ? AND type = ?", commentID, model.ActivityTypeNewComment).Delete(&model.Activity{}).Error
 }
 
-func GetActivityList(offset, limit int) ([]model.Activity, int, error) {
+// privateActivityTypes are only shown to the users involved, not in the public activity list.
+var privateActivityTypes = []model.ActivityType{
+	model.ActivityTypeMetadataProposal,
+	model.ActivityTypeEditSuggestion,
+	model.ActivityTypeEditSuggestionAccepted,
+	model.ActivityTypeEditSuggestionRejected,
+	model.ActivityTypeSubscriptionResource,
+	model.ActivityTypeSubscriptionFile,
+}
+
+func GetActivityList(offset, limit int, cf *ContentFilter) ([]model.Activity, int, error) {
 	var activities []model.Activity
 	var total int64
 
-	if err := db.Model(&model.Activity{}).Count(&total).Error; err != nil {
+	query := db.Model(&model.Activity{}).Where("type NOT IN ?", privateActivityTypes).Scopes(cf.activities())
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	if err := db.Offset(offset).Limit(limit).Order("id DESC").Find(&activities).Error; err != nil {
+	if err := query.Offset(offset).Limit(limit).Order("id DESC").Find(&activities).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func CreateAPIToken(token *model.APIToken) error {
+	return db.Create(token).Error
+}
+
+// GetAPITokenByHash returns the token of a hash, if it has not expired.
+func GetAPITokenByHash(hash string) (model.APIToken, error) {
+	var token model.APIToken
+	if err := db.Where("token_hash = ? AND (expires_at IS NULL OR expires_at > ?)", hash, time.Now()).
+		First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return token, model.NewNotFoundError("API token not found")
+		}
+		return token, err
+	}
+	return token, nil
+}
+
+func ListUserAPITokens(userID uint) ([]model.APIToken, error) {
+	var tokens []model.APIToken
+	if err := db.Where("user_id = ?", userID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func CountUserAPITokens(userID uint) (int64, error) {
+	var count int64
+	if err := db.Model(&model.APIToken{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func SetAPITokenLastUsed(id uint) error {
+	return db.Model(&model.APIToken{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
+
+func DeleteUserAPIToken(userID, id uint) error {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.NewNotFoundError("API token not found")
+	}
+	return nil
+}
+
+func DeleteUserAPITokens(userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&model.APIToken{}).Error
+}
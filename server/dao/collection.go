@@ -172,7 +172,7 @@ func ListUserCollections(uid uint, page int, pageSize int, showPrivate bool) ([]
 	return collections, totalPages, nil
 }
 
-func ListCollectionResources(collectionID uint, page int, pageSize int) ([]*model.Resource, int64, error) {
+func ListCollectionResources(collectionID uint, page int, pageSize int, cf *ContentFilter) ([]*model.Resource, int64, error) {
 	var resources []*model.Resource
 	var total int64
 
@@ -180,6 +180,7 @@ func ListCollectionResources(collectionID uint, page int, pageSize int) ([]*mode
 		Model(&model.Resource{}).
 		Joins("JOIN collection_resources ON collection_resources.resource_id = resources.id").
 		Where("collection_resources.collection_id = ?", collectionID).
+		Scopes(cf.resources("resources.id")).
 		Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -191,6 +192,7 @@ func ListCollectionResources(collectionID uint, page int, pageSize int) ([]*mode
 		Preload("Tags").
 		Joins("JOIN collection_resources ON collection_resources.resource_id = resources.id").
 		Where("collection_resources.collection_id = ?", collectionID).
+		Scopes(cf.resources("resources.id")).
 		Order("collection_resources.created_at DESC").
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
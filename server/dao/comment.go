@@ -89,6 +89,19 @@ func GetCommentByResourceID(resourceID uint, page, pageSize int) ([]model.Commen
 	return comments, totalPages, nil
 }
 
+// ListRecentCommentContents returns the content of the latest comments on a resource.
+func ListRecentCommentContents(resourceID uint, limit int) ([]string, error) {
+	var contents []string
+	if err := db.Model(&model.Comment{}).
+		Where("ref_id = ? AND type = ?", resourceID, model.CommentTypeResource).
+		Order("created_at DESC").
+		Limit(limit).
+		Pluck("content", &contents).Error; err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
 func GetCommentsWithUser(username string, page, pageSize int) ([]model.Comment, int, error) {
 	var user model.User
 
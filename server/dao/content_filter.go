@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"fmt"
+	"nysoure/server/model"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContentFilter leaves resources out of listings according to the preferences of a user.
+// A nil filter leaves nothing out.
+type ContentFilter struct {
+	// HiddenTags leaves out the resources with any of the tags, their aliases or descendants.
+	HiddenTags []uint
+}
+
+// hiddenResources returns a subquery selecting the IDs of the resources left out,
+// or false if none are.
+func (f *ContentFilter) hiddenResources() (string, []any, bool) {
+	if f == nil || len(f.HiddenTags) == 0 {
+		return "", nil, false
+	}
+	return fmt.Sprintf(resourceIDsWithTagTree, "m.id IN ?"), []any{f.HiddenTags}, true
+}
+
+// restrict adds the filter to a condition on the resources table.
+func (f *ContentFilter) restrict(condition clause.Expr) clause.Expr {
+	hidden, vars, ok := f.hiddenResources()
+	if !ok {
+		return condition
+	}
+	return clause.Expr{
+		SQL:  "(" + condition.SQL + ") AND resources.id NOT IN (" + hidden + ")",
+		Vars: slices.Concat(condition.Vars, vars),
+	}
+}
+
+// resources returns a scope leaving out the hidden resources from a query on the column.
+func (f *ContentFilter) resources(column string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		hidden, vars, ok := f.hiddenResources()
+		if !ok {
+			return tx
+		}
+		return tx.Where(column+" NOT IN ("+hidden+")", vars...)
+	}
+}
+
+// activities returns a scope leaving out the activities about hidden resources,
+// their files and their comments.
+func (f *ContentFilter) activities() func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		hidden, vars, ok := f.hiddenResources()
+		if !ok {
+			return tx
+		}
+		return tx.
+			Where("NOT (type IN ? AND ref_id IN ("+hidden+"))", slices.Concat([]any{resourceActivityTypes}, vars)...).
+			Where("NOT (type IN ? AND ref_id IN (SELECT id FROM files WHERE resource_id IN ("+hidden+")))",
+				slices.Concat([]any{fileActivityTypes}, vars)...).
+			Where("NOT (type = ? AND ref_id IN (SELECT id FROM comments WHERE type = ? AND ref_id IN ("+hidden+")))",
+				slices.Concat([]any{model.ActivityTypeNewComment, model.CommentTypeResource}, vars)...)
+	}
+}
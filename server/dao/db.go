@@ -52,7 +52,17 @@ func InitDB() {
 		&model.Collection{},
 		&model.CollectionResource{},
 		&model.Character{},
+		&model.MetadataProposal{},
+		&model.ResourceRedirect{},
+		&model.ResourceRevision{},
+		&model.EditSuggestion{},
+		&model.Subscription{},
+		&model.TagImplication{},
+		&model.TagType{},
+		&model.Session{},
+		&model.APIToken{},
 	)
+	_ = initTagTypes()
 }
 
 func GetDB() *gorm.DB {
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"nysoure/server/model"
+	"regexp"
+)
+
+// ListResourceIdentities returns the titles and links of all resources,
+// which is all the duplicate detection needs.
+func ListResourceIdentities() ([]model.Resource, error) {
+	var resources []model.Resource
+	if err := db.Select("id", "title", "alternative_titles", "links").Find(&resources).Error; err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// FindResourcesWithLinkID returns the titles and links of resources with a link which
+// contains the ID as a whole URL segment. The same ID may belong to another site,
+// so the caller has to match the links again.
+func FindResourcesWithLinkID(id string) ([]model.Resource, error) {
+	var resources []model.Resource
+	if err := db.Select("id", "title", "alternative_titles", "links").
+		Where("links ~ ?", linkIDPattern(id)).
+		Find(&resources).Error; err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// linkIDPattern matches the ID in the stored links when it follows a slash or an equals sign
+// and ends the URL, a path segment or a query parameter. Links are stored as JSON, which
+// ends the URL with a quote and escapes an ampersand as \u0026.
+func linkIDPattern(id string) string {
+	return `[/=]` + regexp.QuoteMeta(id) + `([/?#.&"\\]|$)`
+}
+
+// FindResourceIDsByFileHashes returns the resources which have a file with one of the given hashes.
+func FindResourceIDsByFileHashes(hashes []string) ([]uint, error) {
+	var ids []uint
+	if len(hashes) == 0 {
+		return ids, nil
+	}
+	if err := db.Model(&model.File{}).
+		Distinct("resource_id").
+		Where("hash IN ?", hashes).
+		Pluck("resource_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+type ResourceFileHash struct {
+	ResourceID uint
+	Hash       string
+}
+
+// ListSharedFileHashes returns the file hashes which appear in more than one resource.
+func ListSharedFileHashes() ([]ResourceFileHash, error) {
+	var result []ResourceFileHash
+	shared := db.Model(&model.File{}).
+		Select("hash").
+		Where("hash IS NOT NULL AND hash <> ''").
+		Group("hash").
+		Having("COUNT(DISTINCT resource_id) > 1")
+	if err := db.Model(&model.File{}).
+		Distinct("resource_id", "hash").
+		Where("hash IN (?)", shared).
+		Order("hash").
+		Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package dao
+
+import (
+	"encoding/json"
+	"nysoure/server/model"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkIDPattern(t *testing.T) {
+	stored := func(url string) string {
+		data, err := json.Marshal([]model.Link{{URL: url, Label: "Link"}})
+		assert.NoError(t, err)
+		return string(data)
+	}
+	matches := func(id, url string) bool {
+		return regexp.MustCompile(linkIDPattern(id)).MatchString(stored(url))
+	}
+
+	assert.True(t, matches("v17", "https://vndb.org/v17"))
+	assert.True(t, matches("123", "https://store.steampowered.com/app/123/Game/"))
+	assert.True(t, matches("RJ123456", "https://www.dlsite.com/maniax/work/=/product_id/RJ123456.html"))
+	assert.True(t, matches("42", "https://erogamescape.dyndns.org/~ap2/ero/toukei_kaiseki/game.php?game=42&tab=1"))
+	assert.True(t, matches("game", "https://author.itch.io/game"))
+
+	// Short IDs do not match inside other IDs or other parts of the link
+	assert.False(t, matches("1", "https://vndb.org/v17"))
+	assert.False(t, matches("1", "https://store.steampowered.com/app/123/"))
+	assert.False(t, matches("v1", "https://vndb.org/v17"))
+	assert.False(t, matches("Link", "https://vndb.org/v17"))
+
+	// Wildcards and regex characters in the ID are literal
+	assert.False(t, matches("v_7", "https://vndb.org/v17"))
+	assert.False(t, matches("v.7", "https://vndb.org/v17"))
+}
@@ -0,0 +1,128 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+
+	"gorm.io/gorm"
+)
+
+var editSuggestionActivityTypes = []model.ActivityType{
+	model.ActivityTypeEditSuggestion,
+	model.ActivityTypeEditSuggestionAccepted,
+	model.ActivityTypeEditSuggestionRejected,
+}
+
+// CreateEditSuggestion stores a suggestion and notifies the owner of the resource.
+func CreateEditSuggestion(s *model.EditSuggestion, notifyTo uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		s.Status = model.EditSuggestionPending
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		activity := &model.Activity{
+			UserID:   s.UserID,
+			Type:     model.ActivityTypeEditSuggestion,
+			RefID:    s.ID,
+			NotifyTo: notifyTo,
+		}
+		if err := tx.Create(activity).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ?", notifyTo).UpdateColumn("unread_notifications_count", gorm.Expr("unread_notifications_count + ?", 1)).Error
+	})
+}
+
+func HasPendingEditSuggestion(resourceID, userID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&model.EditSuggestion{}).
+		Where("resource_id = ? AND user_id = ? AND status = ?", resourceID, userID, model.EditSuggestionPending).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func GetEditSuggestionByID(id uint) (*model.EditSuggestion, error) {
+	var s model.EditSuggestion
+	if err := db.Preload("Resource").Preload("Resource.User").Preload("Resource.Images").Preload("User").
+		First(&s, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("Suggestion not found")
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListEditSuggestions lists suggestions with the given status, newest first.
+// If resourceID is not zero, only suggestions for the resource are listed.
+// If ownerID is not zero, only suggestions for resources of the user are listed.
+func ListEditSuggestions(resourceID, ownerID uint, status model.EditSuggestionStatus, page, pageSize int) ([]model.EditSuggestion, int, error) {
+	query := db.Model(&model.EditSuggestion{}).Where("edit_suggestions.status = ?", status)
+	if resourceID != 0 {
+		query = query.Where("edit_suggestions.resource_id = ?", resourceID)
+	}
+	if ownerID != 0 {
+		query = query.Joins("JOIN resources ON resources.id = edit_suggestions.resource_id").
+			Where("resources.user_id = ?", ownerID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var suggestions []model.EditSuggestion
+	if err := query.Preload("Resource").Preload("Resource.User").Preload("Resource.Images").Preload("User").
+		Order("edit_suggestions.id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&suggestions).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := (int(total) + pageSize - 1) / pageSize
+	return suggestions, totalPages, nil
+}
+
+// ReviewEditSuggestion records the decision on a pending suggestion and notifies its author.
+func ReviewEditSuggestion(s *model.EditSuggestion, reviewerID uint, status model.EditSuggestionStatus, reason string) error {
+	activityType := model.ActivityTypeEditSuggestionAccepted
+	if status == model.EditSuggestionRejected {
+		activityType = model.ActivityTypeEditSuggestionRejected
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(s).Updates(map[string]any{
+			"status":        status,
+			"reviewer_id":   reviewerID,
+			"reject_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+		activity := &model.Activity{
+			UserID:   reviewerID,
+			Type:     activityType,
+			RefID:    s.ID,
+			NotifyTo: s.UserID,
+		}
+		if err := tx.Create(activity).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ?", s.UserID).UpdateColumn("unread_notifications_count", gorm.Expr("unread_notifications_count + ?", 1)).Error
+	})
+}
+
+// deleteEditSuggestionsOfResource removes the suggestions for a resource and their activities.
+func deleteEditSuggestionsOfResource(tx *gorm.DB, resourceID uint) error {
+	var ids []uint
+	if err := tx.Unscoped().Model(&model.EditSuggestion{}).Where("resource_id = ?", resourceID).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Unscoped().Where("ref_id IN ? AND type IN ?", ids, editSuggestionActivityTypes).
+		Delete(&model.Activity{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&model.EditSuggestion{}, ids).Error
+}
@@ -164,6 +164,7 @@ func GetFileByID(id uint) (*model.File, error) {
 	return f, nil
 }
 
+// DeleteFile moves a file to the trash. The storage object is kept until the file is purged.
 func DeleteFile(id string) error {
 	f := &model.File{}
 	if err := db.Where("uuid = ?", id).First(f).Error; err != nil {
@@ -171,6 +172,9 @@ func DeleteFile(id string) error {
 	}
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(f).Update("in_trash", true).Error; err != nil {
+			return err
+		}
 		if err := tx.Delete(f).Error; err != nil {
 			return err
 		}
@@ -183,7 +187,7 @@ func DeleteFile(id string) error {
 		}
 		if err := tx.
 			Model(&model.Activity{}).
-			Where("type = ? AND ref_id = ?", model.ActivityTypeNewFile, f.ID).
+			Where("type IN ? AND ref_id = ?", fileActivityTypes, f.ID).
 			Delete(&model.Activity{}).
 			Error; err != nil {
 			return err
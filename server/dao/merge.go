@@ -0,0 +1,195 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MergeResources moves everything attached to the source resource into the target,
+// deletes the source and leaves a redirect from the source ID to the target.
+func MergeResources(sourceID, targetID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var source, target model.Resource
+		if err := tx.Preload("Characters").First(&source, sourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.NewNotFoundError("Source resource not found")
+			}
+			return err
+		}
+		if err := tx.Preload("Characters").First(&target, targetID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.NewNotFoundError("Target resource not found")
+			}
+			return err
+		}
+
+		// Files and comments
+		if err := tx.Model(&model.File{}).Where("resource_id = ?", sourceID).
+			Update("resource_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.UploadingFile{}).Where("target_resource_id = ?", sourceID).
+			Update("target_resource_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Comment{}).Where("ref_id = ? AND type = ?", sourceID, model.CommentTypeResource).
+			Update("ref_id", targetID).Error; err != nil {
+			return err
+		}
+
+		// Images and tags
+		for table, column := range map[string]string{"resource_images": "image_id", "resource_tags": "tag_id"} {
+			if err := tx.Exec("INSERT INTO "+table+" (resource_id, "+column+") SELECT ?, "+column+" FROM "+table+
+				" WHERE resource_id = ? ON CONFLICT DO NOTHING", targetID, sourceID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM "+table+" WHERE resource_id = ?", sourceID).Error; err != nil {
+				return err
+			}
+		}
+
+		// Characters which the target does not have yet
+		for _, c := range source.Characters {
+			exists := slices.ContainsFunc(target.Characters, func(tc model.Character) bool {
+				return tc.Name == c.Name
+			})
+			if exists {
+				if err := tx.Delete(&c).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Model(&c).Update("resource_id", targetID).Error; err != nil {
+				return err
+			}
+		}
+
+		// Collections: drop the source where the collection already contains the target
+		var both []uint
+		if err := tx.Model(&model.CollectionResource{}).
+			Where("resource_id = ? AND collection_id IN (?)", sourceID,
+				tx.Model(&model.CollectionResource{}).Select("collection_id").Where("resource_id = ?", targetID)).
+			Pluck("collection_id", &both).Error; err != nil {
+			return err
+		}
+		if len(both) > 0 {
+			if err := tx.Where("resource_id = ? AND collection_id IN ?", sourceID, both).
+				Delete(&model.CollectionResource{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Collection{}).Where("id IN ?", both).
+				UpdateColumn("resources_count", gorm.Expr("resources_count - 1")).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.CollectionResource{}).Where("resource_id = ?", sourceID).
+			Update("resource_id", targetID).Error; err != nil {
+			return err
+		}
+
+		// Fields of the target itself
+		for _, t := range append([]string{source.Title}, source.AlternativeTitles...) {
+			if t != target.Title && !slices.Contains(target.AlternativeTitles, t) {
+				target.AlternativeTitles = append(target.AlternativeTitles, t)
+			}
+		}
+		for _, l := range source.Links {
+			if !slices.ContainsFunc(target.Links, func(tl model.Link) bool { return tl.URL == l.URL }) {
+				target.Links = append(target.Links, l)
+			}
+		}
+		for _, id := range source.Gallery {
+			if !slices.Contains(target.Gallery, id) {
+				target.Gallery = append(target.Gallery, id)
+			}
+		}
+		for _, id := range source.GalleryNsfw {
+			if !slices.Contains(target.GalleryNsfw, id) {
+				target.GalleryNsfw = append(target.GalleryNsfw, id)
+			}
+		}
+		if target.CoverID == nil {
+			target.CoverID = source.CoverID
+		}
+		if target.ReleaseDate == nil {
+			target.ReleaseDate = source.ReleaseDate
+		}
+		if err := tx.Model(&target).
+			Select("alternative_titles", "links", "gallery", "gallery_nsfw", "cover_id", "release_date", "modified_time").
+			Updates(&model.Resource{
+				AlternativeTitles: target.AlternativeTitles,
+				Links:             target.Links,
+				Gallery:           target.Gallery,
+				GalleryNsfw:       target.GalleryNsfw,
+				CoverID:           target.CoverID,
+				ReleaseDate:       target.ReleaseDate,
+				ModifiedTime:      time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&target).UpdateColumns(map[string]any{
+			"views":     gorm.Expr("views + ?", source.Views),
+			"downloads": gorm.Expr("downloads + ?", source.Downloads),
+			"comments":  gorm.Expr("comments + ?", source.Comments),
+		}).Error; err != nil {
+			return err
+		}
+
+		// Activities and proposals of the source
+		if err := tx.Where("ref_id = ? AND type IN ?", sourceID, resourceActivityTypes).
+			Delete(&model.Activity{}).Error; err != nil {
+			return err
+		}
+		var proposals []uint
+		if err := tx.Model(&model.MetadataProposal{}).Where("resource_id = ?", sourceID).
+			Pluck("id", &proposals).Error; err != nil {
+			return err
+		}
+		if len(proposals) > 0 {
+			if err := tx.Where("ref_id IN ? AND type = ?", proposals, model.ActivityTypeMetadataProposal).
+				Delete(&model.Activity{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.MetadataProposal{}, proposals).Error; err != nil {
+				return err
+			}
+		}
+
+		// Suggestions were made against the source, so they cannot be applied to the target
+		if err := deleteEditSuggestionsOfResource(tx, sourceID); err != nil {
+			return err
+		}
+
+		// Delete the source and redirect its ID, including IDs merged into it before
+		if err := tx.Model(&model.User{}).Where("id = ?", source.UserID).
+			Update("resources_count", gorm.Expr("resources_count - ?", 1)).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&source).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ResourceRedirect{}).Where("target_id = ?", sourceID).
+			Update("target_id", targetID).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&model.ResourceRedirect{
+			ID:       sourceID,
+			TargetID: targetID,
+		}).Error
+	})
+}
+
+// GetResourceRedirect returns the resource a merged resource ID points to.
+func GetResourceRedirect(id uint) (uint, error) {
+	var r model.ResourceRedirect
+	if err := db.First(&r, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, model.NewNotFoundError("Resource not found")
+		}
+		return 0, err
+	}
+	return r.TargetID, nil
+}
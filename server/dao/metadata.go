@@ -0,0 +1,179 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListResourcesForMetadataSync returns resources with links which have not been
+// synced since the given time. Resources never synced come first.
+func ListResourcesForMetadataSync(syncedBefore time.Time, limit int) ([]model.Resource, error) {
+	var resources []model.Resource
+	err := db.Preload("Characters").
+		Where("links LIKE ?", "%://%").
+		Where("metadata_synced_at IS NULL OR metadata_synced_at < ?", syncedBefore).
+		Order("metadata_synced_at ASC NULLS FIRST").
+		Limit(limit).
+		Find(&resources).Error
+	return resources, err
+}
+
+// UpdateResourceRatings stores the ratings and marks the resource as synced.
+// It does not touch modified_time, since ratings are not edited by users.
+func UpdateResourceRatings(resourceID uint, ratings []model.ExternalRating) error {
+	byLabel := make(map[string]int, len(ratings))
+	for _, r := range ratings {
+		byLabel[r.Label] = r.Score
+	}
+	now := time.Now()
+	return db.Model(&model.Resource{}).Where("id = ?", resourceID).Updates(&model.Resource{
+		Ratings:          byLabel,
+		RatingDetails:    ratings,
+		MetadataSyncedAt: &now,
+	}).Error
+}
+
+// ExistsMetadataProposal reports whether the same changes were already proposed for the resource.
+func ExistsMetadataProposal(resourceID uint, digest string) (bool, error) {
+	var count int64
+	if err := db.Model(&model.MetadataProposal{}).
+		Where("resource_id = ? AND digest = ?", resourceID, digest).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateMetadataProposal replaces the pending proposal of the resource and notifies the owner.
+func CreateMetadataProposal(p *model.MetadataProposal, notifyTo uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var replaced []uint
+		if err := tx.Model(&model.MetadataProposal{}).
+			Where("resource_id = ? AND status = ?", p.ResourceID, model.MetadataProposalPending).
+			Pluck("id", &replaced).Error; err != nil {
+			return err
+		}
+		if len(replaced) > 0 {
+			if err := tx.Where("ref_id IN ? AND type = ?", replaced, model.ActivityTypeMetadataProposal).
+				Delete(&model.Activity{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.MetadataProposal{}, replaced).Error; err != nil {
+				return err
+			}
+		}
+		p.Status = model.MetadataProposalPending
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		activity := &model.Activity{
+			UserID:   notifyTo,
+			Type:     model.ActivityTypeMetadataProposal,
+			RefID:    p.ID,
+			NotifyTo: notifyTo,
+		}
+		if err := tx.Create(activity).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ?", notifyTo).UpdateColumn("unread_notifications_count", gorm.Expr("unread_notifications_count + ?", 1)).Error
+	})
+}
+
+func GetMetadataProposalByID(id uint) (*model.MetadataProposal, error) {
+	var p model.MetadataProposal
+	if err := db.Preload("Resource").Preload("Resource.User").Preload("Resource.Images").First(&p, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("Proposal not found")
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPendingMetadataProposal returns the pending proposal of a resource, or nil if there is none.
+func GetPendingMetadataProposal(resourceID uint) (*model.MetadataProposal, error) {
+	var p model.MetadataProposal
+	err := db.Preload("Resource").Preload("Resource.User").Preload("Resource.Images").
+		Where("resource_id = ? AND status = ?", resourceID, model.MetadataProposalPending).
+		First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPendingMetadataProposals lists pending proposals for resources owned by the user.
+// If userID is 0, proposals of all users are listed.
+func ListPendingMetadataProposals(userID uint, page, pageSize int) ([]model.MetadataProposal, int, error) {
+	query := db.Model(&model.MetadataProposal{}).Where("metadata_proposals.status = ?", model.MetadataProposalPending)
+	if userID != 0 {
+		query = query.Joins("JOIN resources ON resources.id = metadata_proposals.resource_id").
+			Where("resources.user_id = ?", userID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var proposals []model.MetadataProposal
+	if err := query.Preload("Resource").Preload("Resource.User").Preload("Resource.Images").
+		Order("metadata_proposals.id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&proposals).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := (int(total) + pageSize - 1) / pageSize
+	return proposals, totalPages, nil
+}
+
+// RejectMetadataProposal marks a proposal as rejected. The record is kept so that
+// the same changes are not proposed again.
+func RejectMetadataProposal(id uint) error {
+	return db.Model(&model.MetadataProposal{}).Where("id = ?", id).Update("status", model.MetadataProposalRejected).Error
+}
+
+// AcceptMetadataProposal applies the proposal to its resource.
+// characters must already have their images downloaded.
+func AcceptMetadataProposal(p *model.MetadataProposal, characters []model.Character) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var r model.Resource
+		if err := tx.Select("id", "alternative_titles").First(&r, p.ResourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.NewNotFoundError("Resource not found")
+			}
+			return err
+		}
+		updates := map[string]any{
+			"modified_time": time.Now(),
+		}
+		if p.ReleaseDate != nil {
+			updates["release_date"] = p.ReleaseDate
+		}
+		if len(p.AlternativeTitles) > 0 {
+			r.AlternativeTitles = append(r.AlternativeTitles, p.AlternativeTitles...)
+			if err := tx.Model(&r).Select("alternative_titles").Updates(&r).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.Resource{}).Where("id = ?", p.ResourceID).Updates(updates).Error; err != nil {
+			return err
+		}
+		for _, c := range characters {
+			c.ID = 0
+			c.ResourceID = p.ResourceID
+			if c.ImageID != nil && *c.ImageID == 0 {
+				c.ImageID = nil
+			}
+			if err := tx.Create(&c).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.MetadataProposal{}).Where("id = ?", p.ID).Update("status", model.MetadataProposalAccepted).Error
+	})
+}
@@ -78,17 +78,9 @@ func GetResourceByID(id uint) (model.Resource, error) {
 	return r, nil
 }
 
-func GetResourceList(page, pageSize int, sort model.RSort) ([]model.Resource, int, error) {
-	// Retrieve a list of resources with pagination
-	var resources []model.Resource
-	var total int64
-
-	if err := db.Model(&model.Resource{}).Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
-
-	order := ""
-	where := ""
+// resourceOrder returns the order of a resource list, and the condition
+// resources must meet to be sorted that way.
+func resourceOrder(sort model.RSort) (order, where string) {
 	switch sort {
 	case model.RSortTimeAsc:
 		order = "modified_time ASC"
@@ -111,8 +103,21 @@ func GetResourceList(page, pageSize int, sort model.RSort) ([]model.Resource, in
 	default:
 		order = "modified_time DESC" // Default sort order
 	}
+	return order, where
+}
 
-	query := db.Offset((page - 1) * pageSize).Limit(pageSize).Preload("User").Preload("Images").Preload("Tags").Order(order)
+func GetResourceList(page, pageSize int, sort model.RSort, cf *ContentFilter) ([]model.Resource, int, error) {
+	// Retrieve a list of resources with pagination
+	var resources []model.Resource
+	var total int64
+
+	if err := db.Model(&model.Resource{}).Scopes(cf.resources("resources.id")).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order, where := resourceOrder(sort)
+
+	query := db.Scopes(cf.resources("resources.id")).Offset((page - 1) * pageSize).Limit(pageSize).Preload("User").Preload("Images").Preload("Tags").Order(order)
 	if where != "" {
 		query = query.Where(where)
 	}
@@ -206,33 +211,21 @@ func DeleteResource(id uint) error {
 		if err := tx.Delete(&r).Error; err != nil {
 			return err
 		}
-		if err := tx.Model(&model.Activity{}).Where("type = ? AND ref_id = ?", model.ActivityTypeNewResource, id).Delete(&model.Activity{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Model(&model.Activity{}).Where("type = ? AND ref_id = ?", model.ActivityTypeUpdateResource, id).Delete(&model.Activity{}).Error; err != nil {
+		if err := tx.Model(&model.Activity{}).Where("type IN ? AND ref_id = ?", resourceActivityTypes, id).Delete(&model.Activity{}).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 }
 
-func GetResourceByTag(tagID uint, page int, pageSize int) ([]model.Resource, int, error) {
-	tag, err := GetTagByID(tagID)
+func GetResourceByTag(tagID uint, page int, pageSize int, cf *ContentFilter) ([]model.Resource, int, error) {
+	// Resources with a child of the tag are listed as well
+	tagIds, err := GetTagTreeIDs([]uint{tagID})
 	if err != nil {
 		return nil, 0, err
 	}
-
-	if tag.AliasOf != nil {
-		tag, err = GetTagByID(*tag.AliasOf)
-		if err != nil {
-			return nil, 0, err
-		}
-	}
-
-	var tagIds []uint
-	tagIds = append(tagIds, tag.ID)
-	for _, alias := range tag.Aliases {
-		tagIds = append(tagIds, alias.ID)
+	if len(tagIds) == 0 {
+		return nil, 0, model.NewNotFoundError("Tag not found")
 	}
 
 	var resources []model.Resource
@@ -245,11 +238,13 @@ func GetResourceByTag(tagID uint, page int, pageSize int) ([]model.Resource, int
 
 	if err := db.Model(&model.Resource{}).
 		Where("id IN (?)", subQuery).
+		Scopes(cf.resources("resources.id")).
 		Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	if err := db.Where("id IN (?)", subQuery).
+		Scopes(cf.resources("resources.id")).
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
 		Preload("User").
@@ -266,34 +261,28 @@ func GetResourceByTag(tagID uint, page int, pageSize int) ([]model.Resource, int
 	return resources, int(totalPages), nil
 }
 
-// CountResourcesByTag counts the number of resources associated with a specific tag.
-func CountResourcesByTag(tagID uint) (int64, error) {
-	tag, err := GetTagByID(tagID)
-	if err != nil {
-		return 0, err
-	}
-	if tag.AliasOf != nil {
-		tag, err = GetTagByID(*tag.AliasOf)
-		if err != nil {
-			return 0, err
-		}
-	}
-	var tagIds []uint
-	tagIds = append(tagIds, tag.ID)
-	for _, alias := range tag.Aliases {
-		tagIds = append(tagIds, alias.ID)
+// CountResourcesOfTags counts the resources of every tag which has any, including the
+// resources tagged with its aliases. Aliases are not in the result. Resources in the trash
+// are not counted.
+func CountResourcesOfTags() (map[uint]int, error) {
+	var rows []struct {
+		TagID uint
+		Count int
+	}
+	if err := db.Raw(`
+		SELECT COALESCE(t.alias_of, t.id) AS tag_id, COUNT(DISTINCT rt.resource_id) AS count
+		FROM resource_tags rt
+		JOIN tags t ON t.id = rt.tag_id AND t.deleted_at IS NULL
+		JOIN resources r ON r.id = rt.resource_id AND r.deleted_at IS NULL
+		GROUP BY COALESCE(t.alias_of, t.id)
+	`).Scan(&rows).Error; err != nil {
+		return nil, err
 	}
-	var count int64
-	subQuery := db.Table("resource_tags").
-		Select("resource_id").
-		Where("tag_id IN ?", tagIds).
-		Group("resource_id")
-	if err := db.Model(&model.Resource{}).
-		Where("id IN (?)", subQuery).
-		Count(&count).Error; err != nil {
-		return 0, err
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
 	}
-	return count, nil
+	return counts, nil
 }
 
 func ExistsResource(id uint) (bool, error) {
@@ -438,12 +427,17 @@ func AddResourceDownloadCount(id uint) error {
 	return nil
 }
 
-func RandomResource() (model.Resource, error) {
+// maxRandomResourceAttempts bounds the random IDs tried, in case a filter leaves out
+// most resources.
+const maxRandomResourceAttempts = 100
+
+// RandomResource returns a random resource which the filter does not leave out.
+func RandomResource(cf *ContentFilter) (model.Resource, error) {
 	var maxID int64
 	if err := db.Model(&model.Resource{}).Select("MAX(id)").Scan(&maxID).Error; err != nil {
 		return model.Resource{}, err
 	}
-	for {
+	for range maxRandomResourceAttempts {
 		randomID := uint(1)
 		if maxID > 1 {
 			randomID = uint(1 + rand.Int63n(maxID-1))
@@ -454,6 +448,7 @@ func RandomResource() (model.Resource, error) {
 			Preload("Images").
 			Preload("Tags").
 			Preload("Files").
+			Scopes(cf.resources("resources.id")).
 			Where("id = ?", randomID).
 			First(&resource).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -463,6 +458,7 @@ func RandomResource() (model.Resource, error) {
 		}
 		return resource, nil // Return the found resource
 	}
+	return model.Resource{}, model.NewNotFoundError("No resource found")
 }
 
 func GetResourcesIdWithTag(tagID uint) ([]uint, error) {
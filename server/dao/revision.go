@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+
+	"gorm.io/gorm"
+)
+
+func CreateResourceRevision(revision *model.ResourceRevision) error {
+	return db.Create(revision).Error
+}
+
+func HasResourceRevisions(resourceID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&model.ResourceRevision{}).Where("resource_id = ?", resourceID).
+		Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListResourceRevisions returns the revisions of a resource, newest first, without their snapshots.
+func ListResourceRevisions(resourceID uint, page, pageSize int) ([]model.ResourceRevision, int, error) {
+	query := db.Model(&model.ResourceRevision{}).Where("resource_id = ?", resourceID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var revisions []model.ResourceRevision
+	if err := query.Omit("snapshot").Preload("User").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&revisions).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := (int(total) + pageSize - 1) / pageSize
+	return revisions, totalPages, nil
+}
+
+func GetResourceRevision(id uint) (*model.ResourceRevision, error) {
+	var revision model.ResourceRevision
+	if err := db.Preload("User").First(&revision, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("Revision not found")
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// FilterExistingImageIDs returns the IDs of the given images which still exist.
+func FilterExistingImageIDs(ids []uint) ([]uint, error) {
+	result := make([]uint, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	if err := db.Model(&model.Image{}).Where("id IN ?", ids).Pluck("id", &result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FilterExistingTagIDs returns the IDs of the given tags which still exist.
+func FilterExistingTagIDs(ids []uint) ([]uint, error) {
+	result := make([]uint, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	if err := db.Model(&model.Tag{}).Where("id IN ?", ids).Pluck("id", &result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,354 @@
+package dao
+
+import (
+	"fmt"
+	"nysoure/server/model"
+	"nysoure/server/searchql"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// resourcesWithTagGroup selects the resources tagged with any tag of the groups
+// (a tag and its aliases) matched by the condition on the root tag "m".
+const resourcesWithTagGroup = "resources.id IN (SELECT rt.resource_id FROM resource_tags rt " +
+	"JOIN tags t ON t.id = rt.tag_id " +
+	"WHERE COALESCE(t.alias_of, t.id) IN (SELECT COALESCE(m.alias_of, m.id) FROM tags m WHERE m.deleted_at IS NULL AND %s))"
+
+// resourceIDsWithTagTree selects the IDs of the resources tagged with any tag of the groups
+// matched by the condition on "m", or with any descendant of them.
+const resourceIDsWithTagTree = "SELECT rt.resource_id FROM resource_tags rt " +
+	"JOIN tags t ON t.id = rt.tag_id " +
+	"WHERE COALESCE(t.alias_of, t.id) IN (WITH RECURSIVE tree(id) AS (" +
+	"SELECT COALESCE(m.alias_of, m.id) FROM tags m WHERE m.deleted_at IS NULL AND %s " +
+	"UNION SELECT e.source FROM (" + tagParentEdges + ") e JOIN tree ON e.target = tree.id" +
+	") SELECT id FROM tree)"
+
+// resourcesWithTagTree is like resourcesWithTagGroup, but also selects the resources
+// tagged with any descendant of the matched tags.
+const resourcesWithTagTree = "resources.id IN (" + resourceIDsWithTagTree + ")"
+
+// SearchResources lists the resources matching a structured query.
+// textHits contains the resources matched by the search index for each text and title term.
+// When sorted by relevance, resources are listed in the order of ranking, followed by the
+// resources which are not in it.
+func SearchResources(node searchql.Node, textHits map[*searchql.Term][]uint, ranking []uint, sort model.RSort, page, pageSize int, cf *ContentFilter) ([]model.Resource, int, error) {
+	condition, err := searchCondition(node, textHits)
+	if err != nil {
+		return nil, 0, err
+	}
+	condition = cf.restrict(condition)
+	where := ""
+	orders := make([]clause.OrderByColumn, 0, 2)
+	if sort == model.RSortRelevance {
+		if len(ranking) > 0 {
+			ids := make([]string, 0, len(ranking))
+			for _, id := range ranking {
+				ids = append(ids, strconv.FormatUint(uint64(id), 10))
+			}
+			orders = append(orders, clause.OrderByColumn{Column: clause.Column{
+				Name: "array_position('{" + strings.Join(ids, ",") + "}'::bigint[], resources.id)",
+				Raw:  true,
+			}})
+		}
+		orders = append(orders, clause.OrderByColumn{Column: clause.Column{Name: "modified_time"}, Desc: true})
+	} else {
+		var order string
+		order, where = resourceOrder(sort)
+		orders = append(orders, clause.OrderByColumn{Column: clause.Column{Name: order, Raw: true}})
+	}
+	filter := func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Where(condition)
+		if where != "" {
+			tx = tx.Where(where)
+		}
+		return tx
+	}
+
+	var total int64
+	if err := db.Model(&model.Resource{}).Scopes(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var resources []model.Resource
+	if err := db.Scopes(filter).
+		Clauses(clause.OrderBy{Columns: orders}).
+		Preload("User").
+		Preload("Images").
+		Preload("Tags").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&resources).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := (int(total) + pageSize - 1) / pageSize
+	return resources, totalPages, nil
+}
+
+// SearchMatchesResource reports whether a resource matches a structured query.
+func SearchMatchesResource(node searchql.Node, textHits map[*searchql.Term][]uint, resourceID uint) (bool, error) {
+	condition, err := searchCondition(node, textHits)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	if err := db.Model(&model.Resource{}).
+		Where(condition).
+		Where("resources.id = ?", resourceID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetSearchFacets counts the resources matching a structured query by tag, tag type,
+// uploader and release year. At most limit values are returned for each of them.
+func GetSearchFacets(node searchql.Node, textHits map[*searchql.Term][]uint, limit int, cf *ContentFilter) (*model.SearchFacets, error) {
+	condition, err := searchCondition(node, textHits)
+	if err != nil {
+		return nil, err
+	}
+	condition = cf.restrict(condition)
+	matched := db.Model(&model.Resource{}).Select("resources.id").Where(condition)
+	facets := model.NewSearchFacets()
+
+	// Aliases are counted as the tag they belong to
+	tagFacet := func(column string, result *[]model.FacetCount, conditions ...string) error {
+		tx := db.Table("resource_tags").
+			Select(column+" AS value, COUNT(DISTINCT resource_tags.resource_id) AS count").
+			Joins("JOIN tags member ON member.id = resource_tags.tag_id").
+			Joins("JOIN tags root ON root.id = COALESCE(member.alias_of, member.id)").
+			Where("resource_tags.resource_id IN (?) AND root.deleted_at IS NULL", matched)
+		for _, c := range conditions {
+			tx = tx.Where(c)
+		}
+		return tx.Group(column).
+			Order("count DESC, value").
+			Limit(limit).
+			Scan(result).Error
+	}
+	if err := tagFacet("root.name", &facets.Tags); err != nil {
+		return nil, err
+	}
+	if err := tagFacet("root.type", &facets.TagTypes, "root.type <> ''"); err != nil {
+		return nil, err
+	}
+	if err := db.Model(&model.Resource{}).
+		Select("users.username AS value, COUNT(*) AS count").
+		Joins("JOIN users ON users.id = resources.user_id").
+		Where(condition).
+		Group("users.username").
+		Order("count DESC, value").
+		Limit(limit).
+		Scan(&facets.Uploaders).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&model.Resource{}).
+		Select("to_char(release_date, 'YYYY') AS value, COUNT(*) AS count").
+		Where(condition).
+		Where("release_date IS NOT NULL").
+		Group("value").
+		Order("value DESC").
+		Limit(limit).
+		Scan(&facets.ReleaseYears).Error; err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// searchCondition compiles a query to a condition on the resources table.
+func searchCondition(node searchql.Node, textHits map[*searchql.Term][]uint) (clause.Expr, error) {
+	join := func(children []searchql.Node, sep string) (clause.Expr, error) {
+		parts := make([]string, 0, len(children))
+		var vars []any
+		for _, c := range children {
+			e, err := searchCondition(c, textHits)
+			if err != nil {
+				return clause.Expr{}, err
+			}
+			parts = append(parts, e.SQL)
+			vars = append(vars, e.Vars...)
+		}
+		return clause.Expr{SQL: "(" + strings.Join(parts, sep) + ")", Vars: vars}, nil
+	}
+	switch n := node.(type) {
+	case *searchql.And:
+		return join(n.Children, " AND ")
+	case *searchql.Or:
+		return join(n.Children, " OR ")
+	case *searchql.Not:
+		e, err := searchCondition(n.Child, textHits)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		e.SQL = "NOT " + e.SQL
+		return e, nil
+	case *searchql.Term:
+		return termCondition(n, textHits[n])
+	}
+	return clause.Expr{}, fmt.Errorf("unknown query node %T", node)
+}
+
+func termCondition(t *searchql.Term, hits []uint) (clause.Expr, error) {
+	var conditions []string
+	var vars []any
+	add := func(sql string, v ...any) {
+		conditions = append(conditions, sql)
+		vars = append(vars, v...)
+	}
+	addRange := func(column string) {
+		if t.Min != nil {
+			add(column+" >= ?", *t.Min)
+		}
+		if t.Max != nil {
+			add(column+" <= ?", *t.Max)
+		}
+	}
+	addPeriod := func(column string) {
+		add(column + " IS NOT NULL")
+		if t.Since != nil {
+			add(column+" >= ?", *t.Since)
+		}
+		if t.Until != nil {
+			add(column+" < ?", *t.Until)
+		}
+	}
+
+	switch t.Field {
+	case searchql.FieldText:
+		// Words may also be the exact name of a tag
+		hitsSQL, hitsVars := hitsCondition(hits)
+		add("("+hitsSQL+" OR "+fmt.Sprintf(resourcesWithTagTree, "lower(m.name) = lower(?)")+")", append(hitsVars, t.Text)...)
+	case searchql.FieldTitle:
+		hitsSQL, hitsVars := hitsCondition(hits)
+		add(hitsSQL, hitsVars...)
+	case searchql.FieldTag:
+		add(fmt.Sprintf(resourcesWithTagTree, "lower(m.name) = lower(?)"), t.Text)
+	case searchql.FieldTagType:
+		add(fmt.Sprintf(resourcesWithTagGroup, "m.alias_of IS NULL AND lower(m.type) = lower(?)"), t.Text)
+	case searchql.FieldUploader:
+		add("resources.user_id IN (SELECT id FROM users WHERE deleted_at IS NULL AND lower(username) = lower(?))", t.Text)
+	case searchql.FieldReleased:
+		addPeriod("resources.release_date")
+	case searchql.FieldCreated:
+		addPeriod("resources.created_at")
+	case searchql.FieldViews:
+		addRange("resources.views")
+	case searchql.FieldDownloads:
+		addRange("resources.downloads")
+	case searchql.FieldSize:
+		sizeCondition := "files.deleted_at IS NULL"
+		var sizeVars []any
+		if t.Min != nil {
+			sizeCondition += " AND files.size >= ?"
+			sizeVars = append(sizeVars, *t.Min)
+		}
+		if t.Max != nil {
+			sizeCondition += " AND files.size <= ?"
+			sizeVars = append(sizeVars, *t.Max)
+		}
+		add("EXISTS (SELECT 1 FROM files WHERE files.resource_id = resources.id AND "+sizeCondition+")", sizeVars...)
+	case searchql.FieldHas:
+		switch t.Text {
+		case searchql.HasFile:
+			add("EXISTS (SELECT 1 FROM files WHERE files.resource_id = resources.id AND files.deleted_at IS NULL)")
+		case searchql.HasImage:
+			add("EXISTS (SELECT 1 FROM resource_images WHERE resource_images.resource_id = resources.id)")
+		case searchql.HasCover:
+			add("resources.cover_id IS NOT NULL")
+		case searchql.HasCharacter:
+			add("EXISTS (SELECT 1 FROM characters WHERE characters.resource_id = resources.id)")
+		case searchql.HasLink:
+			add("resources.links IS NOT NULL AND resources.links NOT IN ('', 'null', '[]')")
+		case searchql.HasRelease:
+			add("resources.release_date IS NOT NULL")
+		default:
+			return clause.Expr{}, fmt.Errorf("unknown has value %q", t.Text)
+		}
+	default:
+		return clause.Expr{}, fmt.Errorf("unknown query field %q", t.Field)
+	}
+	if len(conditions) == 0 {
+		return clause.Expr{SQL: "TRUE"}, nil
+	}
+	return clause.Expr{SQL: "(" + strings.Join(conditions, " AND ") + ")", Vars: vars}, nil
+}
+
+// hitsCondition matches the resources found by the search engine. Without hits it is
+// FALSE rather than a NULL comparison, which would also make a negated term match nothing.
+func hitsCondition(hits []uint) (string, []any) {
+	if len(hits) == 0 {
+		return "FALSE", nil
+	}
+	// A negated term has all of its hits, which would exceed the limit of bind parameters
+	// if each one had its own
+	ids := make([]string, 0, len(hits))
+	for _, id := range hits {
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	return "resources.id = ANY(?::bigint[])", []any{"{" + strings.Join(ids, ",") + "}"}
+}
+
+// containsPattern returns a LIKE pattern matching text containing s.
+func containsPattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+// SuggestTags returns the tags whose name or alias contains the text, as their main tag,
+// ordered by the number of resources.
+func SuggestTags(text string, limit int) ([]model.TagSuggestion, error) {
+	var result []model.TagSuggestion
+	err := db.Raw(`
+		SELECT * FROM (
+			SELECT
+				root.name AS name,
+				root.type AS type,
+				CASE WHEN bool_or(t.id = root.id) THEN '' ELSE MIN(t.name) END AS alias,
+				(SELECT COUNT(DISTINCT rt.resource_id) FROM resource_tags rt
+					JOIN tags m ON m.id = rt.tag_id
+					JOIN resources r ON r.id = rt.resource_id AND r.deleted_at IS NULL
+					WHERE COALESCE(m.alias_of, m.id) = root.id) AS resources_count
+			FROM tags t
+			JOIN tags root ON root.id = COALESCE(t.alias_of, t.id)
+			WHERE t.deleted_at IS NULL AND root.deleted_at IS NULL AND lower(t.name) LIKE lower(?)
+			GROUP BY root.id, root.name, root.type
+		) s
+		WHERE s.resources_count > 0
+		ORDER BY s.resources_count DESC, s.name
+		LIMIT ?
+	`, containsPattern(text), limit).Scan(&result).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SuggestUploaders returns the users who have uploaded resources and whose username
+// starts with the text, ordered by the number of resources.
+func SuggestUploaders(text string, limit int) ([]model.UploaderSuggestion, error) {
+	var result []model.UploaderSuggestion
+	if err := db.Model(&model.User{}).
+		Select("username", "resources_count").
+		Where("resources_count > 0 AND lower(username) LIKE lower(?)", strings.TrimPrefix(containsPattern(text), "%")).
+		Order("resources_count DESC, username").
+		Limit(limit).
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListResourceTitles returns the titles and views of the given resources.
+func ListResourceTitles(ids []uint) ([]model.Resource, error) {
+	var resources []model.Resource
+	if len(ids) == 0 {
+		return resources, nil
+	}
+	if err := db.Select("id", "title", "alternative_titles", "views").
+		Where("id IN ?", ids).
+		Find(&resources).Error; err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
@@ -0,0 +1,189 @@
+package dao
+
+import (
+	"nysoure/server/model"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// searchDocumentColumns are generated from the text columns of the search documents,
+// so that every replica computes them the same way. Weights rank title matches first,
+// then tags and characters, then links and files, then the article and comments.
+var searchDocumentColumns = []string{
+	`ALTER TABLE search_documents ADD COLUMN IF NOT EXISTS document tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', title), 'A') ||
+		setweight(to_tsvector('simple', tags || ' ' || characters), 'B') ||
+		setweight(to_tsvector('simple', other), 'C') ||
+		setweight(to_tsvector('simple', body), 'D')
+	) STORED`,
+	`ALTER TABLE search_documents ADD COLUMN IF NOT EXISTS content text GENERATED ALWAYS AS (
+		title || ' ' || tags || ' ' || characters || ' ' || other || ' ' || body
+	) STORED`,
+	"CREATE INDEX IF NOT EXISTS idx_search_documents_document ON search_documents USING GIN (document)",
+	"CREATE INDEX IF NOT EXISTS idx_search_documents_content ON search_documents USING GIN (content gin_trgm_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_search_documents_title ON search_documents USING GIN (title gin_trgm_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_search_documents_characters ON search_documents USING GIN (characters gin_trgm_ops)",
+}
+
+// InitSearchDocuments creates the table and indexes used by the Postgres search engine.
+func InitSearchDocuments() error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&model.SearchDocument{}); err != nil {
+		return err
+	}
+	for _, stmt := range searchDocumentColumns {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func SaveSearchDocument(doc *model.SearchDocument) error {
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(doc).Error
+}
+
+func DeleteSearchDocument(resourceID uint) error {
+	return db.Where("resource_id = ?", resourceID).Delete(&model.SearchDocument{}).Error
+}
+
+// DeleteSearchDocumentsBefore deletes the documents which were not saved since a time,
+// which are those of deleted resources after a rebuild.
+func DeleteSearchDocumentsBefore(t time.Time) error {
+	return db.Where("updated_at < ?", t).Delete(&model.SearchDocument{}).Error
+}
+
+func CountSearchDocuments() (int64, error) {
+	var count int64
+	if err := db.Model(&model.SearchDocument{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SearchDocumentQuery is a text match on the search documents. The text must be folded
+// the same way as the documents.
+type SearchDocumentQuery struct {
+	// Words are matched as whole words.
+	Words []string
+	// Substrings are matched anywhere in the text. They are used for scripts which do not
+	// separate words with spaces.
+	Substrings []string
+	// Phrase is matched as consecutive words.
+	Phrase string
+	// Fuzzy lets words also match with typos and as the beginning of longer words.
+	// Fuzzy words must only contain Latin letters and digits.
+	Fuzzy bool
+	// TitleOnly matches the titles instead of the whole document.
+	TitleOnly bool
+	// Any matches the documents containing any of the words instead of all of them.
+	Any bool
+	// ResourceID restricts the match to the document of a resource when it is not zero.
+	ResourceID uint
+}
+
+type SearchDocumentHit struct {
+	ResourceID uint
+	Score      float64
+}
+
+// MatchSearchDocuments returns the resources matching a query, the most relevant first.
+// All of them are returned if limit is 0.
+func MatchSearchDocuments(q SearchDocumentQuery, limit int) ([]SearchDocumentHit, error) {
+	if limit == 0 {
+		limit = -1
+	}
+	vector, text := "document", "content"
+	if q.TitleOnly {
+		vector, text = "ts_filter(document, '{a}')", "title"
+	}
+	var conditions []string
+	var vars []any
+	for _, w := range q.Words {
+		if !q.Fuzzy {
+			conditions = append(conditions, vector+" @@ plainto_tsquery('simple', ?)")
+			vars = append(vars, w)
+			continue
+		}
+		conditions = append(conditions, "("+vector+" @@ plainto_tsquery('simple', ?) OR "+
+			vector+" @@ to_tsquery('simple', ? || ':*') OR ? <% "+text+")")
+		vars = append(vars, w, w, w)
+	}
+	for _, s := range q.Substrings {
+		conditions = append(conditions, text+" LIKE ?")
+		vars = append(vars, containsPattern(s))
+	}
+	if q.Phrase != "" {
+		conditions = append(conditions, "("+vector+" @@ phraseto_tsquery('simple', ?) OR "+text+" LIKE ?)")
+		vars = append(vars, q.Phrase, containsPattern(q.Phrase))
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	sep := " AND "
+	if q.Any {
+		sep = " OR "
+	}
+
+	// Documents are ranked by how many words they contain in which fields,
+	// with a bonus when the title contains the whole text.
+	all := strings.TrimSpace(strings.Join(slices.Concat(q.Words, q.Substrings, []string{q.Phrase}), " "))
+	score := "ts_rank('{0.1, 0.2, 0.4, 1.0}', document, " +
+		"to_tsquery('simple', replace(plainto_tsquery('simple', ?)::text, ' & ', ' | '))) + " +
+		"CASE WHEN title LIKE ? THEN 1 ELSE 0 END"
+	scoreVars := []any{all, containsPattern(all)}
+	if q.Fuzzy {
+		score += " + word_similarity(?, " + text + ")"
+		scoreVars = append(scoreVars, all)
+	}
+
+	where := "(" + strings.Join(conditions, sep) + ")"
+	if q.ResourceID != 0 {
+		where += " AND resource_id = ?"
+		vars = append(vars, q.ResourceID)
+	}
+
+	var hits []SearchDocumentHit
+	if err := db.Model(&model.SearchDocument{}).
+		Select("resource_id, "+score+" AS score", scoreVars...).
+		Where(where, vars...).
+		Order("score DESC, resource_id").
+		Limit(limit).
+		Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// SuggestSearchDocumentTitles returns the resources whose title contains the text,
+// the most similar first.
+func SuggestSearchDocumentTitles(text string, limit int) ([]uint, error) {
+	var ids []uint
+	if err := db.Model(&model.SearchDocument{}).
+		Where("title LIKE ?", containsPattern(text)).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "similarity(title, ?) DESC, resource_id", Vars: []any{text}}}).
+		Limit(limit).
+		Pluck("resource_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// SuggestSearchDocumentCharacters returns the documents with a character whose name,
+// alias or voice actor contains the text.
+func SuggestSearchDocumentCharacters(text string, limit int) ([]model.SearchDocument, error) {
+	var docs []model.SearchDocument
+	if err := db.Select("resource_id", "character_names", "character_cvs").
+		Where("characters LIKE ?", containsPattern(text)).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "similarity(characters, ?) DESC, resource_id", Vars: []any{text}}}).
+		Limit(limit).
+		Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"nysoure/server/searchql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchConditionNegatedTermWithoutHits(t *testing.T) {
+	node, err := searchql.Parse("title:someword -title:otherword")
+	assert.NoError(t, err)
+	terms := searchql.Terms(node)
+	assert.Len(t, terms, 2)
+	hits := map[*searchql.Term][]uint{terms[0]: {1, 2}}
+
+	e, err := searchCondition(node, hits)
+	assert.NoError(t, err)
+	assert.Equal(t, "((resources.id = ANY(?::bigint[])) AND NOT (FALSE))", e.SQL)
+	assert.Equal(t, []any{"{1,2}"}, e.Vars)
+}
+
+func TestSearchConditionTextWithoutHits(t *testing.T) {
+	node, err := searchql.Parse("-someword")
+	assert.NoError(t, err)
+
+	e, err := searchCondition(node, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, e.SQL, "NOT ((FALSE OR ")
+	assert.NotContains(t, e.SQL, "ANY(")
+	assert.Equal(t, []any{"someword"}, e.Vars)
+}
+
+func TestSearchConditionNegatedTermWithManyHits(t *testing.T) {
+	node, err := searchql.Parse("-english")
+	assert.NoError(t, err)
+	terms := searchql.Terms(node)
+	assert.Len(t, terms, 1)
+
+	// A negated term excludes all of its hits with a single bind parameter
+	hits := make([]uint, 0, 70000)
+	for i := 1; i <= 70000; i++ {
+		hits = append(hits, uint(i))
+	}
+	e, err := searchCondition(node, map[*searchql.Term][]uint{terms[0]: hits})
+	assert.NoError(t, err)
+	assert.Contains(t, e.SQL, "NOT ((resources.id = ANY(?::bigint[]) OR ")
+	if assert.Len(t, e.Vars, 2) {
+		ids := e.Vars[0].(string)
+		assert.True(t, strings.HasPrefix(ids, "{1,2,"))
+		assert.True(t, strings.HasSuffix(ids, ",69999,70000}"))
+		assert.Equal(t, "english", e.Vars[1])
+	}
+}
@@ -0,0 +1,113 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func CreateSession(session *model.Session) error {
+	return db.Create(session).Error
+}
+
+// GetSession returns a session which has not expired.
+func GetSession(id uint) (model.Session, error) {
+	var session model.Session
+	if err := db.Where("id = ? AND expires_at > ?", id, time.Now()).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, model.NewNotFoundError("Session not found")
+		}
+		return session, err
+	}
+	return session, nil
+}
+
+// GetSessionByRefreshToken returns the session of a refresh token hash, if it has not expired.
+func GetSessionByRefreshToken(hash string) (model.Session, error) {
+	var session model.Session
+	if err := db.Where("refresh_token_hash = ? AND expires_at > ?", hash, time.Now()).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, model.NewNotFoundError("Session not found")
+		}
+		return session, err
+	}
+	return session, nil
+}
+
+// GetSessionByPreviousRefreshToken returns the session whose refresh token replaced
+// the one of the hash, if it has not expired.
+func GetSessionByPreviousRefreshToken(hash string) (model.Session, error) {
+	var session model.Session
+	if err := db.Where("previous_refresh_token_hash = ? AND expires_at > ?", hash, time.Now()).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, model.NewNotFoundError("Session not found")
+		}
+		return session, err
+	}
+	return session, nil
+}
+
+// RotateRefreshToken replaces the refresh token of a session and extends it.
+// It reports false if the token has already been replaced by a concurrent refresh.
+func RotateRefreshToken(id uint, oldHash, newHash, ip string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+	result := db.Model(&model.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", id, oldHash).
+		Updates(map[string]any{
+			"refresh_token_hash":          newHash,
+			"previous_refresh_token_hash": oldHash,
+			"rotated_at":                  now,
+			"last_seen_at":                now,
+			"ip":                          ip,
+			"expires_at":                  expiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TouchSession records that a session was used from an IP. Its expiry is set when given.
+func TouchSession(id uint, ip string, expiresAt *time.Time) error {
+	updates := map[string]any{
+		"last_seen_at": time.Now(),
+		"ip":           ip,
+	}
+	if expiresAt != nil {
+		updates["expires_at"] = *expiresAt
+	}
+	return db.Model(&model.Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ListUserSessions returns the sessions of a user which have not expired, most recently used first.
+func ListUserSessions(userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func DeleteUserSession(userID, id uint) error {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.NewNotFoundError("Session not found")
+	}
+	return nil
+}
+
+// DeleteUserSessions signs a user out of all devices.
+func DeleteUserSessions(userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&model.Session{}).Error
+}
+
+// DeleteExpiredSessions removes the expired sessions of a user.
+func DeleteExpiredSessions(userID uint) error {
+	return db.Where("user_id = ? AND expires_at <= ?", userID, time.Now()).Delete(&model.Session{}).Error
+}
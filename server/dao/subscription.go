@@ -0,0 +1,142 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func CreateSubscription(s *model.Subscription) error {
+	return db.Create(s).Error
+}
+
+func GetSubscriptionByID(id uint) (*model.Subscription, error) {
+	var s model.Subscription
+	if err := db.First(&s, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("Subscription not found")
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListUserSubscriptions lists the subscriptions of a user, newest first.
+func ListUserSubscriptions(userID uint) ([]model.Subscription, error) {
+	var subscriptions []model.Subscription
+	if err := db.Where("user_id = ?", userID).Order("id DESC").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func CountUserSubscriptions(userID uint) (int64, error) {
+	var count int64
+	if err := db.Model(&model.Subscription{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ExistsSubscription reports whether a user already has a subscription of the kind
+// to the target or the query.
+func ExistsSubscription(userID uint, kind model.SubscriptionKind, targetID uint, query string) (bool, error) {
+	var count int64
+	if err := db.Model(&model.Subscription{}).
+		Where("user_id = ? AND kind = ? AND target_id = ? AND query = ?", userID, kind, targetID, query).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func DeleteSubscription(id uint) error {
+	return db.Delete(&model.Subscription{}, id).Error
+}
+
+// DeleteSubscriptionsOfUser deletes the subscriptions of a user and those following the user.
+func DeleteSubscriptionsOfUser(userID uint) error {
+	return db.Where("user_id = ? OR (kind = ? AND target_id = ?)", userID, model.SubscriptionUploader, userID).
+		Delete(&model.Subscription{}).Error
+}
+
+// ListSavedSearches lists the saved searches of all users.
+func ListSavedSearches() ([]model.Subscription, error) {
+	var subscriptions []model.Subscription
+	if err := db.Where("kind = ?", model.SubscriptionSearch).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// ListResourceFollowers returns the users following a tag of a resource, or one of its aliases,
+// or following its uploader.
+func ListResourceFollowers(resourceID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`
+		SELECT DISTINCT s.user_id FROM subscriptions s
+		JOIN tags st ON st.id = s.target_id
+		WHERE s.deleted_at IS NULL AND s.kind = ? AND COALESCE(st.alias_of, st.id) IN (
+			SELECT COALESCE(t.alias_of, t.id) FROM resource_tags rt
+			JOIN tags t ON t.id = rt.tag_id
+			WHERE rt.resource_id = ?
+		)
+		UNION
+		SELECT s.user_id FROM subscriptions s
+		JOIN resources r ON r.user_id = s.target_id
+		WHERE s.deleted_at IS NULL AND s.kind = ? AND r.id = ?
+	`, model.SubscriptionTag, resourceID, model.SubscriptionUploader, resourceID).Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// AddSubscriptionActivities notifies subscribers of a new resource or file created by a user.
+func AddSubscriptionActivities(userID uint, activityType model.ActivityType, refID uint, notifyTo []uint) error {
+	if len(notifyTo) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		activities := make([]model.Activity, 0, len(notifyTo))
+		for _, id := range notifyTo {
+			activities = append(activities, model.Activity{
+				UserID:   userID,
+				Type:     activityType,
+				RefID:    refID,
+				NotifyTo: id,
+			})
+		}
+		if err := tx.Create(&activities).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id IN ?", notifyTo).UpdateColumn("unread_notifications_count", gorm.Expr("unread_notifications_count + ?", 1)).Error
+	})
+}
+
+// GetSubscriptionFeed lists the notifications of a user about their subscriptions, newest first.
+// If since is not nil, only those created after it are listed.
+func GetSubscriptionFeed(userID uint, since *time.Time, offset, limit int, cf *ContentFilter) ([]model.Activity, int, error) {
+	var activities []model.Activity
+	var total int64
+
+	query := db.Model(&model.Activity{}).Where("notify_to = ? AND type IN ?", userID,
+		[]model.ActivityType{model.ActivityTypeSubscriptionResource, model.ActivityTypeSubscriptionFile}).
+		Scopes(cf.activities())
+	if since != nil {
+		query = query.Where("created_at > ?", *since)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Offset(offset).Limit(limit).Order("id DESC").Find(&activities).Error; err != nil {
+		return nil, 0, err
+	}
+	return activities, int(total), nil
+}
+
+func SetFeedVisitedAt(userID uint, t time.Time) error {
+	return db.Model(&model.User{}).Where("id = ?", userID).Update("feed_visited_at", t).Error
+}
@@ -55,7 +55,7 @@ func DeleteTag(id uint) error {
 	if err := db.Delete(&t).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return err
 	}
-	return nil
+	return RemoveTagRelations(id)
 }
 
 func GetTagByID(id uint) (model.Tag, error) {
@@ -204,15 +204,20 @@ func ClearUnusedTags() error {
 		if tag.CreatedAt.After(now.Add(-time.Hour * 24 * 7)) {
 			continue
 		}
-		resources, _, err := GetResourceByTag(tag.ID, 1, 1)
-		if err != nil {
+		// Resources in the trash still use their tags, so that they can be restored
+		var count int64
+		if err := db.Table("resource_tags").Where("tag_id = ?", tag.ID).Count(&count).Error; err != nil {
 			return err
 		}
-		if len(resources) == 0 {
-			// The tag maybe associated with a deleted resource, so we need to remove it from the resource_tags table first
-			if err := db.Exec("DELETE FROM resource_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+		if count == 0 {
+			// Tags used for the hierarchy or implication rules are kept
+			related, err := HasTagRelations(tag.ID)
+			if err != nil {
 				return err
 			}
+			if related {
+				continue
+			}
 			// Remove all aliases of the tag
 			if err := db.Model(model.Tag{}).Where("alias_of = ?", tag.ID).Update("alias_of", nil).Error; err != nil {
 				return err
@@ -221,6 +226,9 @@ func ClearUnusedTags() error {
 			if err := db.Unscoped().Delete(&tag).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 				return err
 			}
+			if err := db.Where("kind = ? AND target_id = ?", model.SubscriptionTag, tag.ID).Delete(&model.Subscription{}).Error; err != nil {
+				return err
+			}
 			log.Infof("Removed unused tag: %s", tag.Name)
 		}
 	}
@@ -242,3 +250,139 @@ func ExistsTagByID(id uint) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// ListTagAliases retrieves all tags which are aliases of another tag.
+// Only returns the ID, name, and alias_of of each tag.
+func ListTagAliases() ([]model.Tag, error) {
+	var tags []model.Tag
+	if err := db.Select("id", "name", "alias_of").Where("alias_of is not null").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetTagGroupNames returns the names of the given tags, the tags they are aliases of
+// and all aliases of those.
+func GetTagGroupNames(ids []uint) ([]string, error) {
+	var names []string
+	if len(ids) == 0 {
+		return names, nil
+	}
+	roots := db.Model(&model.Tag{}).Select("COALESCE(alias_of, id)").Where("id IN ?", ids)
+	if err := db.Model(&model.Tag{}).
+		Distinct("name").
+		Where("COALESCE(alias_of, id) IN (?)", roots).
+		Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// MergeTags merges the source tag into the target tag. The resources of the source and
+// its aliases get the target instead, and the source becomes an alias of the target,
+// so that its name keeps finding them. Both tags must not be aliases.
+// Nothing is changed if the relations of the source would make the target imply itself.
+func MergeTags(sourceID, targetID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Resources already having the target or one of its aliases only lose the source
+		group := tx.Model(&model.Tag{}).Select("id").Where("id = ? OR alias_of = ?", targetID, targetID)
+		if err := tx.Exec(`
+			INSERT INTO resource_tags (resource_id, tag_id)
+			SELECT DISTINCT resource_id, ? FROM resource_tags
+			WHERE tag_id = ? AND resource_id NOT IN (SELECT resource_id FROM resource_tags WHERE tag_id IN (?))
+			ON CONFLICT DO NOTHING
+		`, targetID, sourceID, group).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM resource_tags WHERE tag_id = ?", sourceID).Error; err != nil {
+			return err
+		}
+
+		// Aliases
+		if err := tx.Model(&model.Tag{}).Where("alias_of = ?", sourceID).Update("alias_of", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Tag{}).Where("id = ?", sourceID).
+			Updates(map[string]any{"alias_of": targetID, "parent_id": nil}).Error; err != nil {
+			return err
+		}
+
+		// Hierarchy and implication rules
+		if err := tx.Model(&model.Tag{}).Where("id = ? AND parent_id = ?", targetID, sourceID).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Tag{}).Where("parent_id = ?", sourceID).Update("parent_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			UPDATE tag_implications i SET tag_id = ? WHERE tag_id = ? AND NOT EXISTS (
+				SELECT 1 FROM tag_implications o WHERE o.tag_id = ? AND o.implied_tag_id = i.implied_tag_id
+			)
+		`, targetID, sourceID, targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			UPDATE tag_implications i SET implied_tag_id = ? WHERE implied_tag_id = ? AND NOT EXISTS (
+				SELECT 1 FROM tag_implications o WHERE o.implied_tag_id = ? AND o.tag_id = i.tag_id
+			)
+		`, targetID, sourceID, targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ? OR implied_tag_id = ? OR tag_id = implied_tag_id", sourceID, sourceID).
+			Delete(&model.TagImplication{}).Error; err != nil {
+			return err
+		}
+		// The edges of the source may close a cycle through the target, such as the source
+		// implying a tag which implies the target
+		var cycle int64
+		if err := tx.Raw(`
+			WITH RECURSIVE reach(id) AS (
+				SELECT e.target FROM (`+tagEdges+`) e WHERE e.source = ?
+				UNION
+				SELECT e.target FROM (`+tagEdges+`) e JOIN reach ON e.source = reach.id
+			)
+			SELECT COUNT(*) FROM reach WHERE id = ?
+		`, targetID, targetID).Scan(&cycle).Error; err != nil {
+			return err
+		}
+		if cycle > 0 {
+			return model.NewRequestError("Merging would create a cycle of implications, remove the parent or implication linking the tags first")
+		}
+
+		// Followers of the source follow the target, unless they already do
+		if err := tx.Model(&model.Subscription{}).
+			Where("kind = ? AND target_id = ? AND user_id NOT IN (?)", model.SubscriptionTag, sourceID,
+				tx.Model(&model.Subscription{}).Select("user_id").Where("kind = ? AND target_id = ?", model.SubscriptionTag, targetID)).
+			Update("target_id", targetID).Error; err != nil {
+			return err
+		}
+		return tx.Where("kind = ? AND target_id = ?", model.SubscriptionTag, sourceID).Delete(&model.Subscription{}).Error
+	})
+}
+
+// RenameTag changes the name of a tag.
+func RenameTag(id uint, name string) error {
+	if strings.Contains(name, "%") {
+		return model.NewRequestError("Tag name cannot contain '%' character")
+	}
+	result := db.Model(&model.Tag{}).Where("id = ?", id).Update("name", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.NewNotFoundError("Tag not found")
+	}
+	return nil
+}
+
+// GetTagByNameInsensitive retrieves a tag by its name ignoring the case.
+func GetTagByNameInsensitive(name string) (model.Tag, error) {
+	var t model.Tag
+	if err := db.Where("lower(name) = lower(?)", name).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Tag{}, model.NewNotFoundError("Tag not found")
+		}
+		return model.Tag{}, err
+	}
+	return t, nil
+}
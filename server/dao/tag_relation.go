@@ -0,0 +1,182 @@
+package dao
+
+import (
+	"nysoure/server/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// tagParentEdges selects the edges from each tag to its parent. Both ends are resolved
+// to the tags they are aliases of, so relations survive a tag becoming an alias.
+const tagParentEdges = "SELECT COALESCE(c.alias_of, c.id) AS source, COALESCE(p.alias_of, p.id) AS target " +
+	"FROM tags c JOIN tags p ON p.id = c.parent_id " +
+	"WHERE c.deleted_at IS NULL AND p.deleted_at IS NULL"
+
+// tagEdges selects the edges of the tag graph: from each tag to its parent and to the
+// tags it implies. A resource with the source of an edge also gets its target.
+const tagEdges = "SELECT source, target FROM (" + tagParentEdges + " UNION ALL " +
+	"SELECT COALESCE(a.alias_of, a.id), COALESCE(b.alias_of, b.id) FROM tag_implications i " +
+	"JOIN tags a ON a.id = i.tag_id JOIN tags b ON b.id = i.implied_tag_id " +
+	"WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL" +
+	") e WHERE source <> target"
+
+// SetTagParent sets the parent of a tag, or removes it if parentID is nil.
+func SetTagParent(tagID uint, parentID *uint) error {
+	return db.Model(&model.Tag{}).Where("id = ?", tagID).Update("parent_id", parentID).Error
+}
+
+func AddTagImplication(tagID, impliedTagID uint) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.TagImplication{
+		TagID:        tagID,
+		ImpliedTagID: impliedTagID,
+	}).Error
+}
+
+func RemoveTagImplication(tagID, impliedTagID uint) error {
+	result := db.Where("tag_id = ? AND implied_tag_id = ?", tagID, impliedTagID).Delete(&model.TagImplication{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.NewNotFoundError("Implication not found")
+	}
+	return nil
+}
+
+// RemoveTagRelations removes a tag from the tag graph. Its children no longer have a parent.
+func RemoveTagRelations(tagID uint) error {
+	if err := db.Model(&model.Tag{}).Where("parent_id = ?", tagID).Update("parent_id", nil).Error; err != nil {
+		return err
+	}
+	return db.Where("tag_id = ? OR implied_tag_id = ?", tagID, tagID).Delete(&model.TagImplication{}).Error
+}
+
+// HasTagRelations reports whether a tag has children or is part of an implication rule.
+func HasTagRelations(tagID uint) (bool, error) {
+	var count int64
+	if err := db.Raw(`
+		SELECT (SELECT COUNT(*) FROM tags WHERE parent_id = ? AND deleted_at IS NULL) +
+			(SELECT COUNT(*) FROM tag_implications WHERE tag_id = ? OR implied_tag_id = ?)
+	`, tagID, tagID, tagID).Scan(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// TagReaches reports whether a resource with the tag from would get the tag to
+// through parents and implications. It is used to reject edges creating cycles.
+func TagReaches(from, to uint) (bool, error) {
+	var count int64
+	if err := db.Raw(`
+		WITH RECURSIVE reach(id) AS (
+			SELECT COALESCE(alias_of, id) FROM tags WHERE id = ?
+			UNION
+			SELECT e.target FROM (`+tagEdges+`) e JOIN reach ON e.source = reach.id
+		)
+		SELECT COUNT(*) FROM reach WHERE id = (SELECT COALESCE(alias_of, id) FROM tags WHERE id = ?)
+	`, from, to).Scan(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetImpliedTagIDs returns the tags which the given tags imply through parents and
+// implications, leaving out those which are already given or whose alias is given.
+func GetImpliedTagIDs(tagIDs []uint) ([]uint, error) {
+	ids := make([]uint, 0)
+	if len(tagIDs) == 0 {
+		return ids, nil
+	}
+	if err := db.Raw(`
+		WITH RECURSIVE given(id) AS (
+			SELECT COALESCE(alias_of, id) FROM tags WHERE id IN ?
+		), reach(id) AS (
+			SELECT e.target FROM (`+tagEdges+`) e WHERE e.source IN (SELECT id FROM given)
+			UNION
+			SELECT e.target FROM (`+tagEdges+`) e JOIN reach ON e.source = reach.id
+		)
+		SELECT id FROM reach WHERE id NOT IN (SELECT id FROM given) ORDER BY id
+	`, tagIDs).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetResourcesIdImplyingTag returns the resources with a tag which implies the given one,
+// which are those which need it when an implication is added.
+func GetResourcesIdImplyingTag(tagID uint) ([]uint, error) {
+	var ids []uint
+	if err := db.Raw(`
+		WITH RECURSIVE reach(id) AS (
+			SELECT COALESCE(alias_of, id) FROM tags WHERE id = ?
+			UNION
+			SELECT e.source FROM (`+tagEdges+`) e JOIN reach ON e.target = reach.id
+		)
+		SELECT DISTINCT rt.resource_id FROM resource_tags rt
+		JOIN tags t ON t.id = rt.tag_id
+		WHERE COALESCE(t.alias_of, t.id) IN (SELECT id FROM reach)
+		ORDER BY rt.resource_id
+	`, tagID).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetTagTreeIDs returns the IDs of the tags, their descendants and all of their aliases.
+func GetTagTreeIDs(tagIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(tagIDs) == 0 {
+		return ids, nil
+	}
+	if err := db.Raw(`
+		WITH RECURSIVE tree(id) AS (
+			SELECT COALESCE(alias_of, id) FROM tags WHERE id IN ?
+			UNION
+			SELECT e.source FROM (`+tagParentEdges+`) e JOIN tree ON e.target = tree.id
+		)
+		SELECT id FROM tags WHERE deleted_at IS NULL AND COALESCE(alias_of, id) IN (SELECT id FROM tree)
+	`, tagIDs).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// AddResourceTags adds tags to a resource, keeping its other tags.
+func AddResourceTags(resourceID uint, tagIDs []uint) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	rows := make([]map[string]any, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		rows = append(rows, map[string]any{"resource_id": resourceID, "tag_id": id})
+	}
+	return db.Table("resource_tags").Clauses(clause.OnConflict{DoNothing: true}).Create(rows).Error
+}
+
+// GetTagChildren returns the tags whose parent is the given tag or one of its aliases.
+func GetTagChildren(tagID uint) ([]model.Tag, error) {
+	var tags []model.Tag
+	if err := db.Where("alias_of IS NULL AND parent_id IN (?)",
+		db.Model(&model.Tag{}).Select("id").Where("id = ? OR alias_of = ?", tagID, tagID)).
+		Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetImplicationsOfTag returns the tags implied by a tag or one of its aliases,
+// and the tags implying them.
+func GetImplicationsOfTag(tagID uint) (implies []model.Tag, impliedBy []model.Tag, err error) {
+	group := db.Model(&model.Tag{}).Select("id").Where("id = ? OR alias_of = ?", tagID, tagID)
+	err = db.Where("id IN (?)", db.Model(&model.TagImplication{}).Select("implied_tag_id").Where("tag_id IN (?)", group)).
+		Order("name").Find(&implies).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	err = db.Where("id IN (?)", db.Model(&model.TagImplication{}).Select("tag_id").Where("implied_tag_id IN (?)", group)).
+		Order("name").Find(&impliedBy).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return implies, impliedBy, nil
+}
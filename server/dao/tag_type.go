@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// initTagTypes registers the types used by tags before types were registered,
+// using their keys as display names.
+func initTagTypes() error {
+	return db.Exec(`
+		INSERT INTO tag_types (key, display_name, "order", color, created_at, updated_at)
+		SELECT DISTINCT type, type, 0, '', NOW(), NOW() FROM tags
+		WHERE type <> '' AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING
+	`).Error
+}
+
+// ListTagTypes lists the tag types in their order.
+func ListTagTypes() ([]model.TagType, error) {
+	var types []model.TagType
+	if err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: "order"}}).Order("key").Find(&types).Error; err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+func GetTagType(key string) (*model.TagType, error) {
+	var t model.TagType
+	if err := db.Where("key = ?", key).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("Tag type not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func CreateTagType(t *model.TagType) error {
+	return db.Create(t).Error
+}
+
+func UpdateTagType(t *model.TagType) error {
+	return db.Model(t).Select("display_name", "order", "color").Updates(t).Error
+}
+
+func DeleteTagType(key string) error {
+	return db.Where("key = ?", key).Delete(&model.TagType{}).Error
+}
+
+// CountTagsOfType counts the tags of a type, including aliases.
+func CountTagsOfType(key string) (int64, error) {
+	var count int64
+	if err := db.Model(&model.Tag{}).Where("type = ?", key).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
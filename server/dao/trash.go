@@ -0,0 +1,252 @@
+package dao
+
+import (
+	"errors"
+	"nysoure/server/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// trashedResources selects deleted resources, except the ones merged into another resource.
+func trashedResources(tx *gorm.DB) *gorm.DB {
+	return tx.Unscoped().Model(&model.Resource{}).
+		Where("resources.deleted_at IS NOT NULL").
+		Where("resources.id NOT IN (SELECT id FROM resource_redirects)")
+}
+
+// ListTrashedResources lists deleted resources, most recently deleted first.
+// If userID is not zero, only resources of the user are listed.
+func ListTrashedResources(userID uint, page, pageSize int) ([]model.Resource, int, error) {
+	query := trashedResources(db)
+	if userID != 0 {
+		query = query.Where("resources.user_id = ?", userID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var resources []model.Resource
+	if err := query.Preload("User").Preload("Images").Preload("Tags").
+		Order("resources.deleted_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&resources).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := (int(total) + pageSize - 1) / pageSize
+	return resources, totalPages, nil
+}
+
+func GetTrashedResource(id uint) (*model.Resource, error) {
+	var r model.Resource
+	if err := trashedResources(db).Where("resources.id = ?", id).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("Resource not found in trash")
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// RestoreResource moves a resource out of the trash, together with the activities deleted with it.
+func RestoreResource(id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var r model.Resource
+		if err := trashedResources(tx).Where("resources.id = ?", id).First(&r).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.NewNotFoundError("Resource not found in trash")
+			}
+			return err
+		}
+		if err := tx.Unscoped().Model(&r).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", r.UserID).
+			Update("resources_count", gorm.Expr("resources_count + ?", 1)).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&model.Activity{}).
+			Where("ref_id = ? AND type IN ?", id, resourceActivityTypes).
+			Where("deleted_at >= ?", r.DeletedAt.Time).
+			Update("deleted_at", nil).Error
+	})
+}
+
+// ListExpiredTrashedResources returns the IDs of resources deleted before the given time.
+func ListExpiredTrashedResources(before time.Time) ([]uint, error) {
+	var ids []uint
+	if err := trashedResources(db).Where("resources.deleted_at < ?", before).
+		Pluck("resources.id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// PurgeResource permanently deletes a resource in the trash and the data which belongs to it.
+// Files are purged separately, as their storage objects have to be deleted.
+func PurgeResource(id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var r model.Resource
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&r).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.NewNotFoundError("Resource not found in trash")
+			}
+			return err
+		}
+		for _, table := range []string{"resource_images", "resource_tags"} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE resource_id = ?", id).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("resource_id = ?", id).Delete(&model.Character{}).Error; err != nil {
+			return err
+		}
+		var collections []uint
+		if err := tx.Model(&model.CollectionResource{}).Where("resource_id = ?", id).
+			Pluck("collection_id", &collections).Error; err != nil {
+			return err
+		}
+		if len(collections) > 0 {
+			if err := tx.Where("resource_id = ?", id).Delete(&model.CollectionResource{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Collection{}).Where("id IN ?", collections).
+				UpdateColumn("resources_count", gorm.Expr("resources_count - 1")).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("resource_id = ?", id).Delete(&model.ResourceRevision{}).Error; err != nil {
+			return err
+		}
+		var proposals []uint
+		if err := tx.Unscoped().Model(&model.MetadataProposal{}).Where("resource_id = ?", id).
+			Pluck("id", &proposals).Error; err != nil {
+			return err
+		}
+		if len(proposals) > 0 {
+			if err := tx.Unscoped().Where("ref_id IN ? AND type = ?", proposals, model.ActivityTypeMetadataProposal).
+				Delete(&model.Activity{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&model.MetadataProposal{}, proposals).Error; err != nil {
+				return err
+			}
+		}
+		if err := deleteEditSuggestionsOfResource(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("ref_id = ? AND type IN ?", id, resourceActivityTypes).
+			Delete(&model.Activity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("target_id = ?", id).Delete(&model.ResourceRedirect{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&r).Error
+	})
+}
+
+// ListTrashedFiles lists files in the trash, most recently deleted first.
+// If userID is not zero, only files of the user are listed.
+func ListTrashedFiles(userID uint, page, pageSize int) ([]model.File, int, error) {
+	query := db.Unscoped().Model(&model.File{}).Where("in_trash = ? AND deleted_at IS NOT NULL", true)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var files []model.File
+	if err := query.Preload("User").Preload("Storage").
+		Preload("Resource", func(tx *gorm.DB) *gorm.DB {
+			return tx.Unscoped()
+		}).
+		Order("deleted_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&files).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := (int(total) + pageSize - 1) / pageSize
+	return files, totalPages, nil
+}
+
+func GetTrashedFile(id string) (*model.File, error) {
+	f := &model.File{}
+	if err := db.Unscoped().Preload("Storage").
+		Where("uuid = ? AND in_trash = ? AND deleted_at IS NOT NULL", id, true).
+		First(f).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.NewNotFoundError("file not found in trash")
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// RestoreFile moves a file out of the trash, together with the activity deleted with it.
+func RestoreFile(id string) error {
+	f, err := GetTrashedFile(id)
+	if err != nil {
+		return err
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(f).Updates(map[string]any{
+			"deleted_at": nil,
+			"in_trash":   false,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", f.UserID).
+			UpdateColumn("files_count", gorm.Expr("files_count + ?", 1)).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&model.Activity{}).
+			Where("type IN ? AND ref_id = ?", fileActivityTypes, f.ID).
+			Where("deleted_at >= ?", f.DeletedAt.Time).
+			Update("deleted_at", nil).Error
+	}); err != nil {
+		return err
+	}
+	invalidateFileSizeCache()
+	return nil
+}
+
+// ListExpiredTrashedFiles returns the files deleted before the given time.
+func ListExpiredTrashedFiles(before time.Time) ([]model.File, error) {
+	var files []model.File
+	if err := db.Unscoped().Preload("Storage").
+		Where("in_trash = ? AND deleted_at < ?", true, before).
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// PurgeFile permanently deletes the record of a file. Files which are not in the trash yet,
+// such as failed uploads, are removed from the counters first.
+func PurgeFile(id string) error {
+	f := &model.File{}
+	if err := db.Unscoped().Where("uuid = ?", id).First(f).Error; err != nil {
+		return err
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if !f.DeletedAt.Valid {
+			if err := tx.Model(&model.User{}).Where("id = ?", f.UserID).
+				UpdateColumn("files_count", gorm.Expr("files_count - ?", 1)).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("type IN ? AND ref_id = ?", fileActivityTypes, f.ID).
+			Delete(&model.Activity{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(f).Error
+	}); err != nil {
+		return err
+	}
+	invalidateFileSizeCache()
+	return nil
+}
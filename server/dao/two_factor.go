@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"nysoure/server/model"
+
+	"gorm.io/gorm"
+)
+
+// SetTotpSecret stores the secret of a user enrolling in two-factor authentication.
+func SetTotpSecret(userID uint, secret string) error {
+	return db.Model(&model.User{}).Where("id = ? AND NOT two_factor_enabled", userID).
+		Update("totp_secret", secret).Error
+}
+
+// EnableTwoFactor enables two-factor authentication with the secret the user enrolled with.
+// It reports false if the user enabled it meanwhile or enrolled again with another secret.
+func EnableTwoFactor(userID uint, secret string, step int64, recoveryCodes []string) (bool, error) {
+	result := db.Model(&model.User{}).
+		Where("id = ? AND NOT two_factor_enabled AND totp_secret = ?", userID, secret).
+		Select("two_factor_enabled", "totp_last_step", "recovery_codes").
+		Updates(&model.User{TwoFactorEnabled: true, TotpLastStep: step, RecoveryCodes: recoveryCodes})
+	return result.RowsAffected == 1, result.Error
+}
+
+func DisableTwoFactor(userID uint) error {
+	return db.Model(&model.User{Model: gorm.Model{ID: userID}}).
+		Select("totp_secret", "two_factor_enabled", "totp_last_step", "recovery_codes").
+		Updates(&model.User{}).Error
+}
+
+// ConsumeTotpStep records that the code of a time step was used. It reports false if a code
+// of this step or a later one was already used, so that concurrent requests cannot both use it.
+func ConsumeTotpStep(userID uint, step int64) (bool, error) {
+	result := db.Model(&model.User{}).
+		Where("id = ? AND two_factor_enabled AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ConsumeRecoveryCode removes a recovery code of a user. It reports false if the user
+// does not have it, which includes it having been used by a concurrent request.
+func ConsumeRecoveryCode(userID uint, hash string) (bool, error) {
+	result := db.Exec(`
+		UPDATE users SET recovery_codes = (recovery_codes::jsonb - ?)::text
+		WHERE id = ? AND two_factor_enabled AND jsonb_exists(recovery_codes::jsonb, ?)
+	`, hash, userID, hash)
+	return result.RowsAffected == 1, result.Error
+}
+
+func SetRecoveryCodes(userID uint, recoveryCodes []string) error {
+	return db.Model(&model.User{Model: gorm.Model{ID: userID}}).
+		Select("recovery_codes").
+		Updates(&model.User{RecoveryCodes: recoveryCodes}).Error
+}
@@ -3,7 +3,6 @@ package dao
 import (
 	"errors"
 	"nysoure/server/model"
-	"time"
 
 	"gorm.io/gorm"
 )
@@ -144,8 +143,17 @@ func GetUserNotificationCount(userID uint) (uint, error) {
 	return count, nil
 }
 
+// BanUser bans a user, signs them out of all devices and revokes their API tokens.
 func BanUser(userID uint) error {
-	return db.Model(&model.User{}).Where("id = ?", userID).Update("banned", true).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Update("banned", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&model.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.APIToken{}).Error
+	})
 }
 
 func UnbanUser(userID uint) error {
@@ -168,13 +176,30 @@ func ListBannedUsers(page, pageSize int) ([]model.User, int64, error) {
 	return users, total, nil
 }
 
-func GetUserPermissionAndCreatedAt(id uint) (model.Permission, time.Time, error) {
+// GetUserAuthInfo returns the fields of a user which every authenticated request needs:
+// the permission, the creation time and the content preferences.
+func GetUserAuthInfo(id uint) (model.User, error) {
 	var user model.User
-	if err := db.Model(&model.User{}).Where("id = ?", id).Select("permission, created_at").Scan(&user).Error; err != nil {
+	if err := db.Select("id", "permission", "created_at", "content_preferences").Where("id = ?", id).Take(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return model.PermissionNone, time.Time{}, model.NewNotFoundError("User not found")
+			return user, model.NewNotFoundError("User not found")
 		}
-		return model.PermissionNone, time.Time{}, err
+		return user, err
+	}
+	return user, nil
+}
+
+// SetContentPreferences saves the content preferences of a user. Nil restores the defaults.
+func SetContentPreferences(userID uint, prefs *model.ContentPreferences) error {
+	return db.Model(&model.User{Model: gorm.Model{ID: userID}}).
+		Select("content_preferences").
+		Updates(&model.User{ContentPreferences: prefs}).Error
+}
+
+func IsTwoFactorEnabled(userID uint) (bool, error) {
+	var enabled bool
+	if err := db.Model(&model.User{}).Where("id = ?", userID).Select("two_factor_enabled").Scan(&enabled).Error; err != nil {
+		return false, err
 	}
-	return user.Permission, user.CreatedAt, nil
+	return enabled, nil
 }
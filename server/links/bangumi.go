@@ -0,0 +1,46 @@
+package links
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"time"
+)
+
+var bangumiLinkRegexp = regexp.MustCompile(`^https?://(?:bgm\.tv|bangumi\.tv|chii\.in)/subject/(\d+)(?:[/?#].*)?$`)
+
+type bangumiProvider struct {
+	apiURL string
+}
+
+func (p *bangumiProvider) Name() string  { return "bangumi" }
+func (p *bangumiProvider) Label() string { return "Bangumi" }
+func (p *bangumiProvider) Icon() string  { return "https://bgm.tv/img/favicon.ico" }
+
+func (p *bangumiProvider) Match(link string) (string, bool) {
+	return matchID(bangumiLinkRegexp, link)
+}
+
+func (p *bangumiProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	var resp struct {
+		Rating struct {
+			// Score is between 0 and 10.
+			Score float64 `json:"score"`
+			Total int     `json:"total"`
+		} `json:"rating"`
+	}
+	if err := getJSON(ctx, p.apiURL+"/v0/subjects/"+id, &resp); err != nil {
+		return Rating{}, err
+	}
+	return Rating{
+		Score: int(math.Round(resp.Rating.Score * 10)),
+		Votes: resp.Rating.Total,
+	}, nil
+}
+
+func init() {
+	Register(&bangumiProvider{apiURL: "https://api.bgm.tv"}, Options{
+		MinInterval: time.Second,
+		CacheTTL:    12 * time.Hour,
+	})
+}
@@ -0,0 +1,48 @@
+package links
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+var dlsiteLinkRegexp = regexp.MustCompile(`^https?://www\.dlsite\.com/[a-z-]+/(?:work|announce)/=/product_id/([A-Z]{2}\d+)(?:\.html)?(?:[/?#].*)?$`)
+
+type dlsiteProvider struct {
+	baseURL string
+}
+
+func (p *dlsiteProvider) Name() string  { return "dlsite" }
+func (p *dlsiteProvider) Label() string { return "DLsite" }
+func (p *dlsiteProvider) Icon() string  { return "https://www.dlsite.com/favicon.ico" }
+
+func (p *dlsiteProvider) Match(link string) (string, bool) {
+	return matchID(dlsiteLinkRegexp, link)
+}
+
+func (p *dlsiteProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	var resp map[string]struct {
+		// RateAverageStar is the average of 1 to 5 stars, multiplied by 10.
+		RateAverageStar int `json:"rate_average_star"`
+		RateCount       int `json:"rate_count"`
+	}
+	// The product info endpoint of any floor serves all products
+	if err := getJSON(ctx, p.baseURL+"/maniax/product/info/ajax?product_id="+id, &resp); err != nil {
+		return Rating{}, err
+	}
+	info, ok := resp[id]
+	if !ok || info.RateCount == 0 {
+		return Rating{}, nil
+	}
+	return Rating{
+		Score: info.RateAverageStar * 2,
+		Votes: info.RateCount,
+	}, nil
+}
+
+func init() {
+	Register(&dlsiteProvider{baseURL: "https://www.dlsite.com"}, Options{
+		MinInterval: time.Second,
+		CacheTTL:    12 * time.Hour,
+	})
+}
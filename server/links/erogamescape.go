@@ -0,0 +1,49 @@
+package links
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	erogamescapeLinkRegexp   = regexp.MustCompile(`^https?://erogamescape\.(?:dyndns\.org|org)/~ap2/ero/toukei_kaiseki/game\.php\?(?:.*&)?game=(\d+)(?:[&#].*)?$`)
+	erogamescapeMedianRegexp = regexp.MustCompile(`id="median"[\s\S]*?<td[^>]*>\s*(\d+)`)
+	erogamescapeCountRegexp  = regexp.MustCompile(`id="count"[\s\S]*?<td[^>]*>\s*(\d+)`)
+)
+
+// erogamescapeProvider reads the median score from the game page, since the site has no API.
+type erogamescapeProvider struct {
+	baseURL string
+}
+
+func (p *erogamescapeProvider) Name() string  { return "erogamescape" }
+func (p *erogamescapeProvider) Label() string { return "批評空間" }
+func (p *erogamescapeProvider) Icon() string  { return "https://erogamescape.dyndns.org/favicon.ico" }
+
+func (p *erogamescapeProvider) Match(link string) (string, bool) {
+	return matchID(erogamescapeLinkRegexp, link)
+}
+
+func (p *erogamescapeProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	page, err := getText(ctx, p.baseURL+"/~ap2/ero/toukei_kaiseki/game.php?game="+id)
+	if err != nil {
+		return Rating{}, err
+	}
+	median := erogamescapeMedianRegexp.FindStringSubmatch(page)
+	count := erogamescapeCountRegexp.FindStringSubmatch(page)
+	if median == nil || count == nil {
+		return Rating{}, nil
+	}
+	score, _ := strconv.Atoi(median[1])
+	votes, _ := strconv.Atoi(count[1])
+	return Rating{Score: min(score, 100), Votes: votes}, nil
+}
+
+func init() {
+	Register(&erogamescapeProvider{baseURL: "https://erogamescape.dyndns.org"}, Options{
+		MinInterval: 3 * time.Second,
+		CacheTTL:    12 * time.Hour,
+	})
+}
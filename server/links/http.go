@@ -0,0 +1,91 @@
+package links
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const userAgent = "nysoure (https://github.com/wgh136/nysoure)"
+
+// maxResponseSize limits how much of a response is read.
+const maxResponseSize = 4 << 20
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// StatusError is returned when a site responds with an unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.URL, e.StatusCode)
+}
+
+// isSiteFailure reports whether an error means the site itself is in trouble,
+// as opposed to a problem with a single entry.
+func isSiteFailure(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+func fetch(ctx context.Context, method, url string, body string) ([]byte, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+func getJSON(ctx context.Context, url string, out any) error {
+	data, err := fetch(ctx, http.MethodGet, url, "")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func postJSON(ctx context.Context, url string, in any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	data, err := fetch(ctx, http.MethodPost, url, string(body))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func getText(ctx context.Context, url string) (string, error) {
+	data, err := fetch(ctx, http.MethodGet, url, "")
+	return string(data), err
+}
@@ -0,0 +1,67 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	itchioLinkRegexp        = regexp.MustCompile(`^https?://([a-z0-9-]+)\.itch\.io/([A-Za-z0-9_-]+)/?(?:[?#].*)?$`)
+	itchioRatingValueRegexp = regexp.MustCompile(`"ratingValue"\s*:\s*"?([\d.]+)`)
+	itchioRatingCountRegexp = regexp.MustCompile(`"ratingCount"\s*:\s*"?(\d+)`)
+)
+
+// itchioProvider reads the aggregate rating from the structured data of the game page.
+type itchioProvider struct {
+	// baseURL replaces https://<author>.itch.io in tests.
+	baseURL string
+}
+
+func (p *itchioProvider) Name() string  { return "itchio" }
+func (p *itchioProvider) Label() string { return "itch.io" }
+func (p *itchioProvider) Icon() string  { return "https://itch.io/favicon.ico" }
+
+// Match returns "<author>/<game>" as the ID.
+func (p *itchioProvider) Match(link string) (string, bool) {
+	m := itchioLinkRegexp.FindStringSubmatch(strings.TrimSpace(link))
+	if m == nil {
+		return "", false
+	}
+	return m[1] + "/" + m[2], true
+}
+
+func (p *itchioProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	author, game, ok := strings.Cut(id, "/")
+	if !ok {
+		return Rating{}, fmt.Errorf("invalid itch.io id: %s", id)
+	}
+	url := fmt.Sprintf("https://%s.itch.io/%s", author, game)
+	if p.baseURL != "" {
+		url = fmt.Sprintf("%s/%s/%s", p.baseURL, author, game)
+	}
+	page, err := getText(ctx, url)
+	if err != nil {
+		return Rating{}, err
+	}
+	value := itchioRatingValueRegexp.FindStringSubmatch(page)
+	count := itchioRatingCountRegexp.FindStringSubmatch(page)
+	if value == nil || count == nil {
+		return Rating{}, nil
+	}
+	// Ratings are between 1 and 5 stars
+	stars, _ := strconv.ParseFloat(value[1], 64)
+	votes, _ := strconv.Atoi(count[1])
+	return Rating{Score: int(math.Round(stars * 20)), Votes: votes}, nil
+}
+
+func init() {
+	Register(&itchioProvider{}, Options{
+		MinInterval: 2 * time.Second,
+		CacheTTL:    12 * time.Hour,
+	})
+}
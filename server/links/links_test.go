@@ -0,0 +1,160 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"nysoure/server/cache"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixtureServer serves files from testdata by request path.
+func fixtureServer(t *testing.T, routes map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile("testdata/" + file)
+		assert.NoError(t, err)
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		link     string
+		provider string
+		id       string
+	}{
+		{"https://vndb.org/v17", "vndb", "v17"},
+		{"https://vndb.org/v17/chars", "vndb", "v17"},
+		{"https://store.steampowered.com/app/1234/Game_Name/", "steam", "1234"},
+		{"https://bgm.tv/subject/12345", "bangumi", "12345"},
+		{"https://bangumi.tv/subject/12345", "bangumi", "12345"},
+		{"https://www.dlsite.com/maniax/work/=/product_id/RJ123456.html", "dlsite", "RJ123456"},
+		{"https://erogamescape.dyndns.org/~ap2/ero/toukei_kaiseki/game.php?game=1234", "erogamescape", "1234"},
+		{"https://author.itch.io/some-game", "itchio", "author/some-game"},
+	}
+	for _, c := range cases {
+		p, id, ok := Match(c.link)
+		if assert.True(t, ok, c.link) {
+			assert.Equal(t, c.provider, p.Name(), c.link)
+			assert.Equal(t, c.id, id, c.link)
+		}
+	}
+
+	for _, link := range []string{
+		"https://vndb.org/c17",
+		"https://store.steampowered.com/bundle/1234",
+		"https://itch.io/games",
+		"https://example.com/v17",
+	} {
+		_, _, ok := Match(link)
+		assert.False(t, ok, link)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	server := fixtureServer(t, map[string]string{
+		"/vn":                               "vndb.json",
+		"/appreviews/1234":                  "steam.json",
+		"/v0/subjects/12345":                "bangumi.json",
+		"/maniax/product/info/ajax":         "dlsite.json",
+		"/~ap2/ero/toukei_kaiseki/game.php": "erogamescape.html",
+		"/author/some-game":                 "itchio.html",
+	})
+
+	cases := []struct {
+		provider Provider
+		id       string
+		expected Rating
+	}{
+		{&vndbProvider{apiURL: server.URL}, "v17", Rating{Score: 85, Votes: 7021}},
+		{&steamProvider{baseURL: server.URL}, "1234", Rating{Score: 94, Votes: 1000}},
+		{&bangumiProvider{apiURL: server.URL}, "12345", Rating{Score: 86, Votes: 3210}},
+		{&dlsiteProvider{baseURL: server.URL}, "RJ123456", Rating{Score: 90, Votes: 512}},
+		{&erogamescapeProvider{baseURL: server.URL}, "1234", Rating{Score: 88, Votes: 2468}},
+		{&itchioProvider{baseURL: server.URL}, "author/some-game", Rating{Score: 92, Votes: 120}},
+	}
+	for _, c := range cases {
+		r, err := c.provider.FetchRating(context.Background(), c.id)
+		assert.NoError(t, err, c.provider.Name())
+		assert.Equal(t, c.expected, r, c.provider.Name())
+	}
+
+	_, err := (&bangumiProvider{apiURL: server.URL}).FetchRating(context.Background(), "1")
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+type fakeProvider struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (p *fakeProvider) Name() string                     { return "fake" }
+func (p *fakeProvider) Label() string                    { return "Fake" }
+func (p *fakeProvider) Icon() string                     { return "" }
+func (p *fakeProvider) Match(link string) (string, bool) { return "", false }
+
+func (p *fakeProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	p.calls.Add(1)
+	return Rating{Score: 50, Votes: 1}, p.err
+}
+
+func TestGetRatingPolicy(t *testing.T) {
+	oldGet, oldSet := cacheGet, cacheSet
+	defer func() { cacheGet, cacheSet = oldGet, oldSet }()
+	store := map[string]string{}
+	cacheGet = func(key string) (string, error) {
+		v, ok := store[key]
+		if !ok {
+			return "", cache.ErrNotFound
+		}
+		return v, nil
+	}
+	cacheSet = func(key, value string, _ time.Duration) error {
+		store[key] = value
+		return nil
+	}
+
+	p := &fakeProvider{}
+	Register(p, Options{MinInterval: 50 * time.Millisecond, CacheTTL: time.Hour})
+
+	// The second request for the same entry is served from the cache
+	r, err := GetRating(context.Background(), p, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, Rating{Score: 50, Votes: 1}, r)
+	_, err = GetRating(context.Background(), p, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), p.calls.Load())
+
+	// Requests to the same provider are spaced out
+	start := time.Now()
+	_, _ = GetRating(context.Background(), p, "2")
+	_, _ = GetRating(context.Background(), p, "3")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Site failures pause the provider, while a missing entry does not
+	p.err = &StatusError{StatusCode: http.StatusNotFound}
+	_, err = GetRating(context.Background(), p, "4")
+	assert.Error(t, err)
+	p.err = &StatusError{StatusCode: http.StatusServiceUnavailable}
+	_, err = GetRating(context.Background(), p, "5")
+	assert.Error(t, err)
+	calls := p.calls.Load()
+	_, err = GetRating(context.Background(), p, "6")
+	assert.ErrorIs(t, err, ErrBackoff)
+	assert.Equal(t, calls, p.calls.Load())
+}
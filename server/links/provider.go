@@ -0,0 +1,200 @@
+package links
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nysoure/server/cache"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBackoff is returned while a provider is paused after failed requests.
+var ErrBackoff = errors.New("link provider is backing off after failures")
+
+// Rating is a normalized rating of an entry on an external site.
+type Rating struct {
+	// Score is between 0 and 100.
+	Score int `json:"score"`
+	// Votes is the number of votes. A rating without votes should not be shown.
+	Votes int `json:"votes"`
+}
+
+// Provider knows how to recognize links to an external site and fetch ratings from it.
+type Provider interface {
+	// Name is a stable identifier used in cache keys.
+	Name() string
+	// Label is shown for links which have no label of their own.
+	Label() string
+	// Icon is the URL of the site icon.
+	Icon() string
+	// Match extracts the entry ID from a link, or reports false if the link belongs to another site.
+	Match(link string) (string, bool)
+	// FetchRating fetches the current rating of an entry.
+	FetchRating(ctx context.Context, id string) (Rating, error)
+}
+
+// Options controls how a provider is called.
+type Options struct {
+	// MinInterval is the minimum time between two requests to the provider.
+	MinInterval time.Duration
+	// CacheTTL is how long a fetched rating is cached.
+	CacheTTL time.Duration
+}
+
+var (
+	backoffBase = time.Minute
+	backoffMax  = 6 * time.Hour
+
+	// cacheGet and cacheSet are replaced in tests, which run without redis.
+	cacheGet = cache.Get
+	cacheSet = cache.Set
+)
+
+type entry struct {
+	Provider
+	opts Options
+
+	mu        sync.Mutex
+	next      time.Time
+	failures  int
+	pauseTill time.Time
+}
+
+var (
+	registry   []*entry
+	registryMu sync.RWMutex
+)
+
+// Register adds a provider to the registry.
+// Registering a provider with the same name replaces the previous one.
+func Register(p Provider, opts Options) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	e := &entry{Provider: p, opts: opts}
+	for i, old := range registry {
+		if old.Name() == p.Name() {
+			registry[i] = e
+			return
+		}
+	}
+	registry = append(registry, e)
+}
+
+func lookup(name string) *entry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, e := range registry {
+		if e.Name() == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Match finds the provider for a link and returns it with the entry ID.
+func Match(link string) (Provider, string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, e := range registry {
+		if id, ok := e.Match(link); ok {
+			return e.Provider, id, true
+		}
+	}
+	return nil, "", false
+}
+
+// GetRating returns the rating of an entry, using the cache when possible.
+// Requests are rate limited per provider, and a provider which keeps failing
+// is paused with an exponential backoff.
+func GetRating(ctx context.Context, p Provider, id string) (Rating, error) {
+	e := lookup(p.Name())
+	if e == nil {
+		return Rating{}, fmt.Errorf("link provider %s is not registered", p.Name())
+	}
+
+	key := fmt.Sprintf("link_rating_%s_%s", e.Name(), id)
+	if cached, err := cacheGet(key); err == nil {
+		var r Rating
+		if err := json.Unmarshal([]byte(cached), &r); err == nil {
+			return r, nil
+		}
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		slog.Warn("failed to read rating cache", "key", key, "error", err)
+	}
+
+	if err := e.wait(ctx); err != nil {
+		return Rating{}, err
+	}
+	r, err := e.FetchRating(ctx, id)
+	e.done(err)
+	if err != nil {
+		return Rating{}, err
+	}
+
+	if data, err := json.Marshal(r); err == nil {
+		if err := cacheSet(key, string(data), e.opts.CacheTTL); err != nil {
+			slog.Warn("failed to write rating cache", "key", key, "error", err)
+		}
+	}
+	return r, nil
+}
+
+// wait blocks until the provider may be called again.
+func (e *entry) wait(ctx context.Context) error {
+	e.mu.Lock()
+	now := time.Now()
+	if now.Before(e.pauseTill) {
+		e.mu.Unlock()
+		return ErrBackoff
+	}
+	start := now
+	if e.next.After(now) {
+		start = e.next
+	}
+	e.next = start.Add(e.opts.MinInterval)
+	e.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// done records the result of a request for the failure backoff.
+func (e *entry) done(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil || !isSiteFailure(err) {
+		e.failures = 0
+		e.pauseTill = time.Time{}
+		return
+	}
+	e.failures++
+	pause := backoffBase << min(e.failures-1, 16)
+	if pause > backoffMax || pause <= 0 {
+		pause = backoffMax
+	}
+	e.pauseTill = time.Now().Add(pause)
+}
+
+// matchID returns the first submatch of re in link.
+func matchID(re *regexp.Regexp, link string) (string, bool) {
+	m := re.FindStringSubmatch(strings.TrimSpace(link))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
@@ -0,0 +1,51 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+var steamLinkRegexp = regexp.MustCompile(`^https?://store\.steampowered\.com/app/(\d+)(?:[/?#].*)?$`)
+
+type steamProvider struct {
+	baseURL string
+}
+
+func (p *steamProvider) Name() string  { return "steam" }
+func (p *steamProvider) Label() string { return "Steam" }
+func (p *steamProvider) Icon() string  { return "https://store.steampowered.com/favicon.ico" }
+
+func (p *steamProvider) Match(link string) (string, bool) {
+	return matchID(steamLinkRegexp, link)
+}
+
+func (p *steamProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	var resp struct {
+		QuerySummary struct {
+			TotalReviews  int `json:"total_reviews"`
+			TotalPositive int `json:"total_positive"`
+		} `json:"query_summary"`
+	}
+	url := fmt.Sprintf("%s/appreviews/%s?json=1&language=all&purchase_type=all&cursor=*&num_per_page=0", p.baseURL, id)
+	if err := getJSON(ctx, url, &resp); err != nil {
+		return Rating{}, err
+	}
+	total := resp.QuerySummary.TotalReviews
+	if total == 0 {
+		return Rating{}, nil
+	}
+	return Rating{
+		Score: int(math.Round(float64(resp.QuerySummary.TotalPositive) / float64(total) * 100)),
+		Votes: total,
+	}, nil
+}
+
+func init() {
+	Register(&steamProvider{baseURL: "https://store.steampowered.com"}, Options{
+		MinInterval: time.Second,
+		CacheTTL:    12 * time.Hour,
+	})
+}
@@ -0,0 +1,54 @@
+package links
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"time"
+)
+
+var vndbLinkRegexp = regexp.MustCompile(`^https?://vndb\.org/(v\d+)(?:[/?#].*)?$`)
+
+type vndbProvider struct {
+	apiURL string
+}
+
+func (p *vndbProvider) Name() string  { return "vndb" }
+func (p *vndbProvider) Label() string { return "VNDB" }
+func (p *vndbProvider) Icon() string  { return "https://vndb.org/favicon.ico" }
+
+func (p *vndbProvider) Match(link string) (string, bool) {
+	return matchID(vndbLinkRegexp, link)
+}
+
+func (p *vndbProvider) FetchRating(ctx context.Context, id string) (Rating, error) {
+	var resp struct {
+		Results []struct {
+			// Rating is between 10 and 100, or null if there are no votes.
+			Rating    *float64 `json:"rating"`
+			VoteCount int      `json:"votecount"`
+		} `json:"results"`
+	}
+	err := postJSON(ctx, p.apiURL+"/vn", map[string]any{
+		"filters": []string{"id", "=", id},
+		"fields":  "rating, votecount",
+	}, &resp)
+	if err != nil {
+		return Rating{}, err
+	}
+	if len(resp.Results) == 0 || resp.Results[0].Rating == nil {
+		return Rating{}, nil
+	}
+	return Rating{
+		Score: int(math.Round(*resp.Results[0].Rating)),
+		Votes: resp.Results[0].VoteCount,
+	}, nil
+}
+
+func init() {
+	// VNDB allows 200 requests in 5 minutes
+	Register(&vndbProvider{apiURL: "https://api.vndb.org/kana"}, Options{
+		MinInterval: 1500 * time.Millisecond,
+		CacheTTL:    12 * time.Hour,
+	})
+}
@@ -4,45 +4,152 @@ import (
 	"nysoure/server/model"
 	"nysoure/server/service"
 	"nysoure/server/utils"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+// apiTokenWritePaths are the routes where API tokens may change data.
+// The services check the scopes of the token there, API tokens can only read elsewhere.
+var apiTokenWritePaths = []string{"/api/resource", "/api/image", "/api/files", "/api/comments"}
+
 func JwtMiddleware(c fiber.Ctx) error {
-	token := c.Get("Authorization")
-	fromCookie := false
-	if token == "" {
-		token = c.Cookies("token")
-		fromCookie = true
-	}
-	if token != "" {
-		id, err := utils.ParseToken(token)
-		if err != nil {
-			if fromCookie {
-				c.ClearCookie("token")
-				return c.Next()
-			} else {
-				return model.NewUnAuthorizedError("Invalid token")
+	if token := strings.TrimPrefix(c.Get("Authorization"), "Bearer "); token != "" {
+		if strings.HasPrefix(token, utils.APITokenPrefix) {
+			if err := authenticateAPIToken(c, token); err != nil {
+				return err
 			}
+		} else if err := authenticate(c, token); err != nil {
+			return err
 		}
+		return c.Next()
+	}
 
-		p, createdAt, err := service.GetUserPermissionAndCreatedAt(id)
-		if err != nil {
-			if model.IsNotFoundError(err) {
-				if fromCookie {
-					c.ClearCookie("token")
-					return c.Next()
-				} else {
-					return model.NewUnAuthorizedError("Invalid token")
-				}
-			} else {
-				return err
-			}
+	token := c.Cookies("token")
+	if token != "" {
+		err := authenticate(c, token)
+		if err == nil {
+			return c.Next()
+		}
+		if !model.IsUnAuthorizedError(err) {
+			return err
 		}
+	}
 
-		c.Locals("uid", id)
-		c.Locals("permission", p)
-		c.Locals("created_at", createdAt)
+	// The access token has expired, the browser gets a new one with the refresh token
+	refreshToken := c.Cookies("refresh_token")
+	if refreshToken == "" {
+		if token != "" {
+			ClearTokenCookies(c)
+		}
+		return c.Next()
+	}
+	user, err := service.RefreshSession(refreshToken, c.IP())
+	if err != nil {
+		if model.IsUnAuthorizedError(err) {
+			ClearTokenCookies(c)
+			return c.Next()
+		}
+		return err
+	}
+	SetTokenCookies(c, user.Token, user.RefreshToken)
+	if err := authenticate(c, user.Token); err != nil {
+		return err
 	}
 	return c.Next()
 }
+
+// authenticate sets the user of the request from an access token.
+// The session of the token must not have been revoked.
+func authenticate(c fiber.Ctx, token string) error {
+	id, sid, err := utils.ParseToken(token)
+	if err != nil {
+		return model.NewUnAuthorizedError("Invalid token")
+	}
+	if err := service.AuthenticateSession(id, sid, c.IP()); err != nil {
+		return err
+	}
+	user, err := service.GetUserAuthInfo(id)
+	if err != nil {
+		if model.IsNotFoundError(err) {
+			return model.NewUnAuthorizedError("Invalid token")
+		}
+		return err
+	}
+	c.Locals("uid", id)
+	c.Locals("session_id", sid)
+	setUserLocals(c, user)
+	return nil
+}
+
+// authenticateAPIToken sets the user of the request from an API token.
+func authenticateAPIToken(c fiber.Ctx, secret string) error {
+	method := c.Method()
+	if method != fiber.MethodGet && method != fiber.MethodHead &&
+		!slices.ContainsFunc(apiTokenWritePaths, func(p string) bool { return strings.HasPrefix(c.Path(), p) }) {
+		return model.NewUnAuthorizedError("API tokens cannot be used for this action")
+	}
+	token, err := service.AuthenticateAPIToken(secret)
+	if err != nil {
+		return err
+	}
+	user, err := service.GetUserAuthInfo(token.UserID)
+	if err != nil {
+		if model.IsNotFoundError(err) {
+			return model.NewUnAuthorizedError("Invalid API token")
+		}
+		return err
+	}
+	c.Locals("uid", token.UserID)
+	c.Locals("api_token", token)
+	setUserLocals(c, user)
+	return nil
+}
+
+// setUserLocals keeps what the services need to know about the user of the request,
+// so that they do not load the user again.
+func setUserLocals(c fiber.Ctx, user model.User) {
+	c.Locals("permission", user.Permission)
+	c.Locals("created_at", user.CreatedAt)
+	c.Locals("content_preferences", user.ContentPreferences)
+}
+
+// SetTokenCookies stores the tokens of a session in the browser.
+// The refresh token is left unchanged if it is empty.
+func SetTokenCookies(c fiber.Ctx, token, refreshToken string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    token,
+		Expires:  time.Now().Add(utils.AccessTokenLifetime),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	if refreshToken != "" {
+		c.Cookie(&fiber.Cookie{
+			Name:     "refresh_token",
+			Value:    refreshToken,
+			Expires:  time.Now().Add(service.SessionLifetime),
+			HTTPOnly: true,
+			Secure:   true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+	}
+}
+
+// ClearTokenCookies removes the tokens of a session from the browser.
+func ClearTokenCookies(c fiber.Ctx) {
+	for _, name := range []string{"token", "refresh_token"} {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Value:    "",
+			Expires:  time.Now().Add(-time.Hour),
+			HTTPOnly: true,
+			Secure:   true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+			MaxAge:   -1,
+		})
+	}
+}
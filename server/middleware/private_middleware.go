@@ -11,7 +11,8 @@ func PrivateMiddleware(c fiber.Ctx) error {
 	if !config.PrivateDeployment() {
 		return c.Next()
 	}
-	if c.Path() == "/api/user/register" || c.Path() == "/api/user/login" {
+	switch c.Path() {
+	case "/api/user/register", "/api/user/login", "/api/user/login/2fa", "/api/user/refresh":
 		return c.Next()
 	}
 	_, ok := c.Locals("uid").(uint)
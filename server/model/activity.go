@@ -14,6 +14,14 @@ const (
 	ActivityTypeUpdateResource
 	ActivityTypeNewComment
 	ActivityTypeNewFile
+	ActivityTypeMetadataProposal
+	ActivityTypeEditSuggestion
+	ActivityTypeEditSuggestionAccepted
+	ActivityTypeEditSuggestionRejected
+	// ActivityTypeSubscriptionResource notifies a subscriber of a new resource matching a subscription.
+	ActivityTypeSubscriptionResource
+	// ActivityTypeSubscriptionFile notifies a subscriber of a new file on a resource matching a subscription.
+	ActivityTypeSubscriptionFile
 )
 
 type Activity struct {
@@ -25,11 +33,13 @@ type Activity struct {
 }
 
 type ActivityView struct {
-	ID       uint          `json:"id"`
-	Time     time.Time     `json:"time"`
-	Type     ActivityType  `json:"type"`
-	User     UserView      `json:"user"`
-	Comment  *CommentView  `json:"comment,omitempty"`
-	Resource *ResourceView `json:"resource,omitempty"`
-	File     *FileView     `json:"file,omitempty"`
+	ID         uint                  `json:"id"`
+	Time       time.Time             `json:"time"`
+	Type       ActivityType          `json:"type"`
+	User       UserView              `json:"user"`
+	Comment    *CommentView          `json:"comment,omitempty"`
+	Resource   *ResourceView         `json:"resource,omitempty"`
+	File       *FileView             `json:"file,omitempty"`
+	Proposal   *MetadataProposalView `json:"proposal,omitempty"`
+	Suggestion *EditSuggestionView   `json:"suggestion,omitempty"`
 }
@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+// TokenScope is an action an API token is allowed to perform.
+type TokenScope string
+
+const (
+	// ScopeRead allows reading only. Every token can read, a token with only this scope is read-only.
+	ScopeRead          TokenScope = "read"
+	ScopeResourceWrite TokenScope = "resource:write"
+	ScopeFileUpload    TokenScope = "file:upload"
+	ScopeCommentWrite  TokenScope = "comment:write"
+)
+
+var TokenScopes = []TokenScope{ScopeRead, ScopeResourceWrite, ScopeFileUpload, ScopeCommentWrite}
+
+// APIToken is a token created by a user for their scripts. It acts as the user,
+// limited to its scopes.
+type APIToken struct {
+	ID     uint   `gorm:"primaryKey"`
+	UserID uint   `gorm:"not null;index"`
+	Name   string `gorm:"not null"`
+	// TokenHash is the SHA-256 of the token, the token itself is only shown when it is created.
+	TokenHash string `gorm:"type:char(64);uniqueIndex;not null"`
+	// Prefix is the start of the token, to let the user recognise it.
+	Prefix string
+	Scopes []TokenScope `gorm:"serializer:json"`
+	// ExpiresAt is nil for tokens which never expire.
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type APITokenView struct {
+	ID         uint         `json:"id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []TokenScope `json:"scopes"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// APITokenWithSecret is returned once, when the token is created.
+type APITokenWithSecret struct {
+	APITokenView
+	Token string `json:"token"`
+}
+
+func (t *APIToken) ToView() APITokenView {
+	return APITokenView{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// HasScope reports whether the token allows an action.
+func (t *APIToken) HasScope(scope TokenScope) bool {
+	if scope == ScopeRead {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
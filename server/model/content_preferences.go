@@ -0,0 +1,12 @@
+package model
+
+// ContentPreferences decide which resources are shown to a user and how.
+type ContentPreferences struct {
+	// HiddenTags are tags whose resources are left out of listings.
+	HiddenTags []uint `json:"hidden_tags"`
+	// BlurredTags are tags whose resources are listed with their images blurred.
+	BlurredTags []uint `json:"blurred_tags"`
+	// ShowNsfw shows the NSFW images of resources. It only applies to users
+	// whose account is older than 72 hours.
+	ShowNsfw bool `json:"show_nsfw"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EditSuggestionStatus string
+
+const (
+	EditSuggestionPending  EditSuggestionStatus = "pending"
+	EditSuggestionAccepted EditSuggestionStatus = "accepted"
+	EditSuggestionRejected EditSuggestionStatus = "rejected"
+)
+
+// EditSuggestion is a change to a resource proposed by a user who cannot edit it.
+// It is applied once the owner of the resource or an admin accepts it.
+type EditSuggestion struct {
+	gorm.Model
+	ResourceID uint `gorm:"not null;index"`
+	Resource   Resource
+	UserID     uint `gorm:"not null;index"`
+	User       User
+	Status     EditSuggestionStatus `gorm:"type:varchar(16);not null;index"`
+	Note       string               `gorm:"type:text"`
+	// Base is the resource when the suggestion was made, and Proposed is the suggested state.
+	// Both are encoded as JSON in the shape of an update request.
+	Base         string `gorm:"type:text"`
+	Proposed     string `gorm:"type:text"`
+	ReviewerID   *uint
+	RejectReason string `gorm:"type:text"`
+}
+
+type EditSuggestionView struct {
+	ID           uint                 `json:"id"`
+	Resource     ResourceView         `json:"resource"`
+	User         UserView             `json:"user"`
+	Status       EditSuggestionStatus `json:"status"`
+	Note         string               `json:"note"`
+	RejectReason string               `json:"reject_reason,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
+
+func (s *EditSuggestion) ToView() *EditSuggestionView {
+	return &EditSuggestionView{
+		ID:           s.ID,
+		Resource:     s.Resource.ToView(),
+		User:         s.User.ToView(),
+		Status:       s.Status,
+		Note:         s.Note,
+		RejectReason: s.RejectReason,
+		CreatedAt:    s.CreatedAt,
+	}
+}
@@ -10,6 +10,15 @@ func NewRequestError(message string) error {
 	return fiber.NewError(400, message)
 }
 
+func IsRequestError(err error) bool {
+	var fiberError *fiber.Error
+	ok := errors.As(err, &fiberError)
+	if !ok {
+		return false
+	}
+	return fiberError.Code == 400
+}
+
 func NewUnAuthorizedError(message string) error {
 	return fiber.NewError(403, message)
 }
@@ -30,3 +39,12 @@ func IsNotFoundError(err error) bool {
 func NewInternalServerError(message string) error {
 	return fiber.NewError(500, message)
 }
+
+func IsUnAuthorizedError(err error) bool {
+	var fiberError *fiber.Error
+	ok := errors.As(err, &fiberError)
+	if !ok {
+		return false
+	}
+	return fiberError.Code == 403
+}
@@ -20,6 +20,9 @@ type File struct {
 	Size        int64
 	Hash        string `gorm:"default:null"`
 	Tag         string `gorm:"type:text;default:null"`
+	// InTrash marks a deleted file whose storage object is kept until the trash is purged.
+	// Files deleted before the trash existed have no storage object any more.
+	InTrash bool `gorm:"default:false;index"`
 }
 
 type FileView struct {
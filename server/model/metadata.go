@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MetadataProposalStatus string
+
+const (
+	MetadataProposalPending  MetadataProposalStatus = "pending"
+	MetadataProposalAccepted MetadataProposalStatus = "accepted"
+	MetadataProposalRejected MetadataProposalStatus = "rejected"
+)
+
+// ExternalRating is the rating of a resource on a linked site.
+type ExternalRating struct {
+	// Provider is the name of the link provider, such as "vndb".
+	Provider string `json:"provider"`
+	Label    string `json:"label"`
+	Icon     string `json:"icon"`
+	URL      string `json:"url"`
+	// Score is between 0 and 100.
+	Score int `json:"score"`
+	Votes int `json:"votes"`
+}
+
+// ExternalCharacter is a character fetched from an external site.
+// The image is only downloaded once the character is actually added to a resource.
+type ExternalCharacter struct {
+	Name     string   `json:"name"`
+	Alias    []string `json:"alias"`
+	CV       string   `json:"cv"`
+	Role     string   `json:"role"`
+	ImageURL string   `json:"image_url"`
+}
+
+// MetadataProposal holds changes found by the metadata sync which have to be
+// reviewed by the uploader before they are applied to the resource.
+type MetadataProposal struct {
+	gorm.Model
+	ResourceID        uint `gorm:"not null;index"`
+	Resource          Resource
+	Status            MetadataProposalStatus `gorm:"type:varchar(16);not null;index"`
+	OldReleaseDate    *time.Time
+	ReleaseDate       *time.Time
+	AlternativeTitles []string            `gorm:"serializer:json"`
+	Characters        []ExternalCharacter `gorm:"serializer:json"`
+	// Digest identifies the proposed changes, so that rejected changes are not proposed again.
+	Digest string `gorm:"type:varchar(64);index"`
+}
+
+type MetadataProposalView struct {
+	ID                uint                   `json:"id"`
+	Resource          ResourceView           `json:"resource"`
+	Status            MetadataProposalStatus `json:"status"`
+	CreatedAt         time.Time              `json:"created_at"`
+	OldReleaseDate    *time.Time             `json:"old_release_date,omitempty"`
+	ReleaseDate       *time.Time             `json:"release_date,omitempty"`
+	AlternativeTitles []string               `json:"alternative_titles"`
+	Characters        []ExternalCharacter    `json:"characters"`
+}
+
+func (p *MetadataProposal) ToView() *MetadataProposalView {
+	titles := p.AlternativeTitles
+	if titles == nil {
+		titles = []string{}
+	}
+	characters := p.Characters
+	if characters == nil {
+		characters = []ExternalCharacter{}
+	}
+	return &MetadataProposalView{
+		ID:                p.ID,
+		Resource:          p.Resource.ToView(),
+		Status:            p.Status,
+		CreatedAt:         p.CreatedAt,
+		OldReleaseDate:    p.OldReleaseDate,
+		ReleaseDate:       p.ReleaseDate,
+		AlternativeTitles: titles,
+		Characters:        characters,
+	}
+}
+
+// IsEmpty reports whether the proposal contains no changes.
+func (p *MetadataProposal) IsEmpty() bool {
+	return p.ReleaseDate == nil && len(p.AlternativeTitles) == 0 && len(p.Characters) == 0
+}
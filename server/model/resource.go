@@ -26,6 +26,10 @@ type Resource struct {
 	Gallery           []uint      `gorm:"serializer:json"`
 	GalleryNsfw       []uint      `gorm:"serializer:json"`
 	Characters        []Character `gorm:"foreignKey:ResourceID"`
+	// Ratings from linked sites, keyed by link label. Refreshed by the metadata sync.
+	Ratings          map[string]int   `gorm:"serializer:json"`
+	RatingDetails    []ExternalRating `gorm:"serializer:json"`
+	MetadataSyncedAt *time.Time
 }
 
 type Link struct {
@@ -41,29 +45,34 @@ type ResourceView struct {
 	Tags        []TagView  `json:"tags"`
 	Image       *ImageView `json:"image"`
 	Author      UserView   `json:"author"`
+	// Blurred is set if the resource has a tag the viewer wants blurred.
+	Blurred bool `json:"blurred,omitempty"`
 }
 
 type ResourceDetailView struct {
-	ID                uint            `json:"id"`
-	Title             string          `json:"title"`
-	AlternativeTitles []string        `json:"alternativeTitles"`
-	Links             []Link          `json:"links"`
-	Article           string          `json:"article"`
-	CreatedAt         time.Time       `json:"createdAt"`
-	ReleaseDate       *time.Time      `json:"releaseDate,omitempty"`
-	Tags              []TagView       `json:"tags"`
-	Images            []ImageView     `json:"images"`
-	CoverID           *uint           `json:"coverId,omitempty"`
-	Files             []FileView      `json:"files"`
-	Author            UserView        `json:"author"`
-	Views             uint            `json:"views"`
-	Downloads         uint            `json:"downloads"`
-	Comments          uint            `json:"comments"`
-	Related           []ResourceView  `json:"related"`
-	Gallery           []uint          `json:"gallery"`
-	GalleryNsfw       []uint          `json:"galleryNsfw"`
-	Characters        []CharacterView `json:"characters"`
-	Ratings           map[string]int  `json:"ratings"`
+	ID                uint             `json:"id"`
+	Title             string           `json:"title"`
+	AlternativeTitles []string         `json:"alternativeTitles"`
+	Links             []Link           `json:"links"`
+	Article           string           `json:"article"`
+	CreatedAt         time.Time        `json:"createdAt"`
+	ReleaseDate       *time.Time       `json:"releaseDate,omitempty"`
+	Tags              []TagView        `json:"tags"`
+	Images            []ImageView      `json:"images"`
+	CoverID           *uint            `json:"coverId,omitempty"`
+	Files             []FileView       `json:"files"`
+	Author            UserView         `json:"author"`
+	Views             uint             `json:"views"`
+	Downloads         uint             `json:"downloads"`
+	Comments          uint             `json:"comments"`
+	Related           []ResourceView   `json:"related"`
+	Gallery           []uint           `json:"gallery"`
+	GalleryNsfw       []uint           `json:"galleryNsfw"`
+	Characters        []CharacterView  `json:"characters"`
+	Ratings           map[string]int   `json:"ratings"`
+	RatingDetails     []ExternalRating `json:"ratingDetails"`
+	// Blurred is set if the resource has a tag the viewer wants hidden or blurred.
+	Blurred bool `json:"blurred,omitempty"`
 }
 
 type LowResResourceImageView struct {
@@ -126,6 +135,14 @@ func (r *Resource) ToDetailView() ResourceDetailView {
 	for i, character := range r.Characters {
 		characters[i] = *character.ToView()
 	}
+	ratings := r.Ratings
+	if ratings == nil {
+		ratings = make(map[string]int)
+	}
+	ratingDetails := r.RatingDetails
+	if ratingDetails == nil {
+		ratingDetails = []ExternalRating{}
+	}
 	return ResourceDetailView{
 		ID:                r.ID,
 		Title:             r.Title,
@@ -145,5 +162,7 @@ func (r *Resource) ToDetailView() ResourceDetailView {
 		Gallery:           r.Gallery,
 		GalleryNsfw:       r.GalleryNsfw,
 		Characters:        characters,
+		Ratings:           ratings,
+		RatingDetails:     ratingDetails,
 	}
 }
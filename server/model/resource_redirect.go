@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// ResourceRedirect points the ID of a resource which was merged into another one to the target,
+// so that old links keep working.
+type ResourceRedirect struct {
+	ID        uint `gorm:"primaryKey;autoIncrement:false"`
+	TargetID  uint `gorm:"not null;index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResourceRevision is a snapshot of the editable fields of a resource,
+// recorded every time the resource is updated.
+type ResourceRevision struct {
+	gorm.Model
+	ResourceID uint `gorm:"not null;index"`
+	UserID     uint
+	User       User
+	// Snapshot holds the resource in the shape of an update request, encoded as JSON.
+	Snapshot string `gorm:"type:text"`
+}
+
+type ResourceRevisionView struct {
+	ID         uint      `json:"id"`
+	ResourceID uint      `json:"resource_id"`
+	Editor     UserView  `json:"editor"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (r *ResourceRevision) ToView() ResourceRevisionView {
+	return ResourceRevisionView{
+		ID:         r.ID,
+		ResourceID: r.ResourceID,
+		Editor:     r.User.ToView(),
+		CreatedAt:  r.CreatedAt,
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SearchFacets counts the resources matching a search by some of their properties,
+// so that the results can be narrowed down.
+type SearchFacets struct {
+	Tags         []FacetCount `json:"tags"`
+	TagTypes     []FacetCount `json:"tag_types"`
+	Uploaders    []FacetCount `json:"uploaders"`
+	ReleaseYears []FacetCount `json:"release_years"`
+}
+
+func NewSearchFacets() *SearchFacets {
+	return &SearchFacets{
+		Tags:         []FacetCount{},
+		TagTypes:     []FacetCount{},
+		Uploaders:    []FacetCount{},
+		ReleaseYears: []FacetCount{},
+	}
+}
+
+type SearchPageResponse struct {
+	PageResponse[ResourceView]
+	Facets *SearchFacets `json:"facets"`
+}
+
+const (
+	CharacterSuggestionName = "character"
+	CharacterSuggestionCV   = "cv"
+)
+
+// SearchSuggestions are the completions of a partially typed search query.
+type SearchSuggestions struct {
+	Titles     []TitleSuggestion     `json:"titles"`
+	Tags       []TagSuggestion       `json:"tags"`
+	Characters []CharacterSuggestion `json:"characters"`
+	Uploaders  []UploaderSuggestion  `json:"uploaders"`
+}
+
+type TitleSuggestion struct {
+	ResourceID uint `json:"resource_id"`
+	// Title is the title or the alternative title which matches the query.
+	Title string `json:"title"`
+	Views uint   `json:"views"`
+}
+
+type TagSuggestion struct {
+	// Name is the name of the main tag.
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Alias is the alias which matches the query, if the main tag itself does not.
+	Alias          string `json:"alias,omitempty"`
+	ResourcesCount int64  `json:"resources_count"`
+}
+
+type CharacterSuggestion struct {
+	Name string `json:"name"`
+	// Kind is CharacterSuggestionName for character names and aliases, or CharacterSuggestionCV for voice actors.
+	Kind  string `json:"kind"`
+	Views uint   `json:"views"`
+}
+
+type UploaderSuggestion struct {
+	Username       string `json:"username"`
+	ResourcesCount int    `json:"resources_count"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// SearchDocument is the text of a resource indexed by the Postgres search engine.
+// The text is folded (case, width and kana script) before it is stored, and queries
+// must be folded the same way. The table also has the generated columns document,
+// a weighted tsvector, and content, all the text for substring and similarity matching.
+type SearchDocument struct {
+	ResourceID uint `gorm:"primaryKey;autoIncrement:false"`
+	// Title contains the title, the alternative titles and their romaji.
+	Title string `gorm:"not null;default:''"`
+	Tags  string `gorm:"not null;default:''"`
+	// Characters contains the names, aliases and voice actors of the characters.
+	Characters string `gorm:"not null;default:''"`
+	// Other contains the labels of the links and the names and descriptions of the files.
+	Other string `gorm:"not null;default:''"`
+	// Body contains the article and the latest comments.
+	Body string `gorm:"not null;default:''"`
+	// CharacterNames and CharacterCVs hold the original names for suggestions.
+	CharacterNames []string `gorm:"serializer:json"`
+	CharacterCVs   []string `gorm:"column:character_cvs;serializer:json"`
+	UpdatedAt      time.Time
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// Session is a login of a user on a device. Access tokens name their session and are
+// only accepted while it exists, so deleting a session signs the device out.
+type Session struct {
+	ID     uint `gorm:"primaryKey"`
+	UserID uint `gorm:"not null;index"`
+	// RefreshTokenHash is the SHA-256 of the refresh token, the token itself is only known to the client.
+	RefreshTokenHash string `gorm:"type:char(64);uniqueIndex;not null"`
+	// PreviousRefreshTokenHash is the hash of the refresh token replaced at RotatedAt.
+	// Presenting it again means the token was stolen, unless it is a concurrent refresh.
+	PreviousRefreshTokenHash string `gorm:"type:char(64);index"`
+	RotatedAt                *time.Time
+	Device                   string
+	IP                       string
+	UserAgent                string
+	CreatedAt                time.Time
+	LastSeenAt               time.Time
+	ExpiresAt                time.Time `gorm:"index"`
+}
+
+type SessionView struct {
+	ID         uint      `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func (s *Session) ToView(currentID uint) SessionView {
+	return SessionView{
+		ID:         s.ID,
+		Device:     s.Device,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == currentID,
+	}
+}
@@ -11,4 +11,6 @@ const (
 	RSortDownloadsDesc
 	RSortReleaseDateAsc
 	RSortReleaseDateDesc
+	// RSortRelevance is only available for search results.
+	RSortRelevance
 )
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SubscriptionKind string
+
+const (
+	SubscriptionSearch   SubscriptionKind = "search"
+	SubscriptionTag      SubscriptionKind = "tag"
+	SubscriptionUploader SubscriptionKind = "uploader"
+)
+
+// Subscription is a saved search, or a tag or an uploader followed by a user.
+// The user is notified of new resources matching it and of new files added to them.
+type Subscription struct {
+	gorm.Model
+	UserID uint             `gorm:"not null;index"`
+	Kind   SubscriptionKind `gorm:"type:varchar(16);not null;index:idx_subscription_target"`
+	// Name is chosen by the user for saved searches.
+	Name string
+	// Query is the search query of a saved search.
+	Query string `gorm:"type:text"`
+	// TargetID is the tag or the uploader followed.
+	TargetID uint `gorm:"index:idx_subscription_target"`
+}
+
+type SubscriptionView struct {
+	ID        uint             `json:"id"`
+	Kind      SubscriptionKind `json:"kind"`
+	Name      string           `json:"name,omitempty"`
+	Query     string           `json:"query,omitempty"`
+	Tag       *TagView         `json:"tag,omitempty"`
+	Uploader  *UserView        `json:"uploader,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func (s *Subscription) ToView() *SubscriptionView {
+	return &SubscriptionView{
+		ID:        s.ID,
+		Kind:      s.Kind,
+		Name:      s.Name,
+		Query:     s.Query,
+		CreatedAt: s.CreatedAt,
+	}
+}
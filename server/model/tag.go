@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Tag struct {
 	gorm.Model
@@ -8,8 +12,11 @@ type Tag struct {
 	Description string
 	AliasOf     *uint `gorm:"default:NULL"` // Foreign key for aliasing, can be NULL
 	Type        string
-	Resources   []Resource `gorm:"many2many:resource_tags;"`
-	Aliases     []Tag      `gorm:"foreignKey:AliasOf;references:ID"`
+	// ParentID is the broader tag of the tag. Resources with the tag also get the parent,
+	// and searching the parent also finds resources with its children.
+	ParentID  *uint      `gorm:"default:NULL;index"`
+	Resources []Resource `gorm:"many2many:resource_tags;"`
+	Aliases   []Tag      `gorm:"foreignKey:AliasOf;references:ID"`
 }
 
 type TagView struct {
@@ -18,6 +25,7 @@ type TagView struct {
 	Description string   `json:"description"`
 	Type        string   `json:"type"`
 	Aliases     []string `json:"aliases"`
+	ParentID    *uint    `json:"parent_id,omitempty"`
 }
 
 func (t *Tag) ToView() *TagView {
@@ -31,6 +39,7 @@ func (t *Tag) ToView() *TagView {
 		Description: t.Description,
 		Type:        t.Type,
 		Aliases:     aliases,
+		ParentID:    t.ParentID,
 	}
 }
 
@@ -45,3 +54,23 @@ func (t *TagView) WithCount(count int) *TagViewWithCount {
 		ResourceCount: count,
 	}
 }
+
+// TagImplication is a rule adding a tag to the resources which have another tag,
+// such as "Linux" for "Steam Deck Verified".
+type TagImplication struct {
+	ID           uint `gorm:"primarykey"`
+	TagID        uint `gorm:"not null;uniqueIndex:idx_tag_implication"`
+	ImpliedTagID uint `gorm:"not null;uniqueIndex:idx_tag_implication;index"`
+	CreatedAt    time.Time
+}
+
+// TagRelationsView describes the place of a tag in the tag graph.
+type TagRelationsView struct {
+	Tag      TagView   `json:"tag"`
+	Parent   *TagView  `json:"parent,omitempty"`
+	Children []TagView `json:"children"`
+	// Implies are the tags added by the implication rules of the tag.
+	Implies []TagView `json:"implies"`
+	// ImpliedBy are the tags whose implication rules add the tag.
+	ImpliedBy []TagView `json:"implied_by"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// TagType is a kind of tags, such as the developer or the language of a resource.
+// Tags refer to their type by its key, tags without a type have an empty key.
+type TagType struct {
+	Key         string `gorm:"primaryKey;type:varchar(32)"`
+	DisplayName string `gorm:"not null"`
+	// Order sorts the types, lower first.
+	Order int `gorm:"not null;default:0"`
+	// Color is a CSS hex color, or empty for the default color.
+	Color     string `gorm:"type:varchar(16)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type TagTypeView struct {
+	Key         string `json:"key"`
+	DisplayName string `json:"display_name"`
+	Order       int    `json:"order"`
+	Color       string `json:"color"`
+}
+
+func (t *TagType) ToView() TagTypeView {
+	return TagTypeView{
+		Key:         t.Key,
+		DisplayName: t.DisplayName,
+		Order:       t.Order,
+		Color:       t.Color,
+	}
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+type TrashedResourceView struct {
+	ResourceView
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+type TrashedFileView struct {
+	*FileView
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// ToTrashedView returns the view of a deleted resource, which is purged after the retention period.
+func (r *Resource) ToTrashedView(retention time.Duration) TrashedResourceView {
+	return TrashedResourceView{
+		ResourceView: r.ToView(),
+		DeletedAt:    r.DeletedAt.Time,
+		PurgeAt:      r.DeletedAt.Time.Add(retention),
+	}
+}
+
+// ToTrashedView returns the view of a deleted file, which is purged after the retention period.
+func (f *File) ToTrashedView(retention time.Duration) TrashedFileView {
+	return TrashedFileView{
+		FileView:  f.ToViewWithResource(),
+		DeletedAt: f.DeletedAt.Time,
+		PurgeAt:   f.DeletedAt.Time.Add(retention),
+	}
+}
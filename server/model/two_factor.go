@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// TwoFactorChallenge is returned by the login of a user with two-factor authentication,
+// instead of a session. The challenge token and a code are exchanged for the session.
+type TwoFactorChallenge struct {
+	ChallengeToken string    `json:"challenge_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
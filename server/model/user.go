@@ -30,6 +30,18 @@ type User struct {
 	Bio                      string
 	UnreadNotificationsCount uint `gorm:"not null;default:0"`
 	Banned                   bool `gorm:"default:false"`
+	// FeedVisitedAt is the last time the user read the feed of their subscriptions.
+	FeedVisitedAt *time.Time
+	// ContentPreferences are nil until the user chooses them, the defaults of the site apply meanwhile.
+	ContentPreferences *ContentPreferences `gorm:"serializer:json"`
+	// TotpSecret is the base32 secret of two-factor authentication. It is set on enrolment
+	// and only used once TwoFactorEnabled is set by verifying a first code.
+	TotpSecret       string
+	TwoFactorEnabled bool `gorm:"not null;default:false"`
+	// TotpLastStep is the time step of the last accepted code, so that a code cannot be used twice.
+	TotpLastStep int64
+	// RecoveryCodes are the SHA-256 hashes of the unused recovery codes.
+	RecoveryCodes []string `gorm:"serializer:json"`
 }
 
 type UserView struct {
@@ -48,6 +60,11 @@ type UserView struct {
 type UserViewWithToken struct {
 	UserView
 	Token string `json:"token"`
+	// RefreshToken is only returned when a session is created or refreshed.
+	// Each refresh replaces it, the client must keep the new one.
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	TwoFactorEnabled  bool   `json:"two_factor_enabled"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
 }
 
 func (u User) ToView() UserView {
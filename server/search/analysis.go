@@ -0,0 +1,174 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const (
+	analyzerName   = "nysoure"
+	kanaFilterName = "kana_hiragana"
+	// bigramFilterName also outputs single characters, so that one kanji can be searched.
+	bigramFilterName = "cjk_bigram_unigram"
+)
+
+func init() {
+	registry.RegisterTokenFilter(kanaFilterName, func(map[string]interface{}, *registry.Cache) (analysis.TokenFilter, error) {
+		return kanaFilter{}, nil
+	})
+}
+
+// kanaFilter converts katakana to hiragana, so that words written in either match each other.
+type kanaFilter struct{}
+
+func (kanaFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		term := string(token.Term)
+		if strings.IndexFunc(term, isKatakana) >= 0 {
+			token.Term = []byte(strings.Map(toHiragana, term))
+		}
+	}
+	return input
+}
+
+func isKatakana(r rune) bool {
+	return r >= 'ァ' && r <= 'ヶ'
+}
+
+func toHiragana(r rune) rune {
+	if isKatakana(r) {
+		return r - ('ァ' - 'ぁ')
+	}
+	return r
+}
+
+// newIndexMapping creates the mapping of the resource index. Text is split into words,
+// and CJK text into overlapping pairs of characters, after folding full and half width
+// characters and katakana.
+func newIndexMapping() (mapping.IndexMapping, error) {
+	m := bleve.NewIndexMapping()
+	err := m.AddCustomTokenFilter(bigramFilterName, map[string]interface{}{
+		"type":           cjk.BigramName,
+		"output_unigram": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = m.AddCustomAnalyzer(analyzerName, map[string]interface{}{
+		"type":      custom.Name,
+		"tokenizer": unicode.Name,
+		"token_filters": []string{
+			cjk.WidthName,
+			kanaFilterName,
+			lowercase.Name,
+			en.StopName,
+			bigramFilterName,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.DefaultAnalyzer = analyzerName
+	return m, nil
+}
+
+var romajiDigraphs = map[string]string{
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+}
+
+var romajiKana = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa", 'ゔ': "vu",
+}
+
+// romanize transcribes the kana in a text to romaji, leaving other characters as they are.
+// Kana is separated from the rest by spaces. It returns false if the text contains no kana.
+func romanize(s string) (string, bool) {
+	runes := []rune(strings.Map(toHiragana, s))
+	var sb strings.Builder
+	found := false
+	inKana := false
+	// geminate is set after a small tsu, which doubles the next consonant
+	geminate := false
+	lastVowel := byte(0)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		var latin string
+		if i+1 < len(runes) {
+			latin = romajiDigraphs[string(runes[i:i+2])]
+		}
+		if latin != "" {
+			i++
+		} else {
+			latin = romajiKana[r]
+		}
+		isKana := latin != "" || r == 'っ' || (r == 'ー' && inKana)
+		if isKana != inKana && sb.Len() > 0 && !strings.HasSuffix(sb.String(), " ") {
+			sb.WriteByte(' ')
+		}
+		inKana = isKana
+		switch {
+		case r == 'っ':
+			found = true
+			geminate = true
+		case r == 'ー' && isKana:
+			if lastVowel != 0 {
+				sb.WriteByte(lastVowel)
+			}
+		case latin != "":
+			found = true
+			if geminate {
+				if strings.HasPrefix(latin, "ch") {
+					sb.WriteByte('t')
+				} else if !strings.ContainsRune("aiueon", rune(latin[0])) {
+					sb.WriteByte(latin[0])
+				}
+				geminate = false
+			}
+			sb.WriteString(latin)
+			lastVowel = latin[len(latin)-1]
+		default:
+			sb.WriteRune(r)
+			lastVowel = 0
+		}
+	}
+	return sb.String(), found
+}
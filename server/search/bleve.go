@@ -0,0 +1,410 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"nysoure/server/searchql"
+	"nysoure/server/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// indexVersion is stored in the index. Indexes of other versions are rebuilt on startup,
+// so it must be changed whenever the document or the mapping changes.
+const indexVersion = "3"
+
+const (
+	// legacyIndexName is the index used before the current index was recorded.
+	legacyIndexName = "resource_index.bleve"
+	// currentIndexFile holds the name of the index directory in use.
+	currentIndexFile = "resource_index.current"
+	indexNamePrefix  = "resource_index."
+	indexNameSuffix  = ".bleve"
+)
+
+var indexVersionKey = []byte("version")
+
+func init() {
+	RegisterEngine(EngineBleve, func() (Engine, error) {
+		return openBleveEngine(utils.GetStoragePath())
+	})
+}
+
+// bleveEngine keeps the documents in an index on the local disk.
+type bleveEngine struct {
+	// dir is the directory containing the index directories.
+	dir   string
+	mu    sync.RWMutex
+	index bleve.Index
+	// indexName is the directory of index, or empty if it is only in memory.
+	indexName string
+	// building is the index being rebuilt. It receives all updates as well.
+	building bleve.Index
+	progress rebuildProgress
+}
+
+func openBleveEngine(dir string) (*bleveEngine, error) {
+	e := &bleveEngine{dir: dir}
+	name := e.currentIndexName()
+	idx, err := bleve.Open(e.path(name))
+	switch {
+	case err == nil:
+		e.index, e.indexName = idx, name
+		version, err := idx.GetInternal(indexVersionKey)
+		if err != nil {
+			_ = idx.Close()
+			return nil, err
+		}
+		e.removeStaleIndexes()
+		if string(version) != indexVersion {
+			// The outdated index keeps serving until the new one is built
+			slog.Info("Search index is outdated, rebuilding", "version", string(version))
+			if err := e.Rebuild(); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, bleve.ErrorIndexPathDoesNotExist):
+		mapping, err := newIndexMapping()
+		if err != nil {
+			return nil, err
+		}
+		e.index, err = bleve.NewMemOnly(mapping)
+		if err != nil {
+			return nil, err
+		}
+		e.removeStaleIndexes()
+		if err := e.Rebuild(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *bleveEngine) Name() string {
+	return EngineBleve
+}
+
+func (e *bleveEngine) Index(doc *ResourceParams) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.building != nil {
+		if err := e.building.Index(doc.key(), doc); err != nil {
+			return err
+		}
+	}
+	return e.index.Index(doc.key(), doc)
+}
+
+func (e *bleveEngine) Delete(id uint) error {
+	key := fmt.Sprintf("%d", id)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.building != nil {
+		if err := e.building.Delete(key); err != nil {
+			return err
+		}
+	}
+	return e.index.Delete(key)
+}
+
+func (e *bleveEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.index.Close()
+}
+
+func (e *bleveEngine) Search(keyword string) ([]uint, error) {
+	q := bleve.NewMatchQuery(keyword)
+	hits, err := e.search(bleve.NewSearchRequest(q))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, hit.id)
+	}
+	return results, nil
+}
+
+func (e *bleveEngine) MatchTerm(t *searchql.Term, limit int) ([]Hit, error) {
+	if limit == 0 {
+		e.mu.RLock()
+		count, err := e.index.DocCount()
+		e.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		limit = int(count)
+	}
+	hits, err := e.search(bleve.NewSearchRequestOptions(termQuery(t), limit, 0, false))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Hit, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, Hit{ID: hit.id, Score: hit.score})
+	}
+	return results, nil
+}
+
+func (e *bleveEngine) MatchesTerm(t *searchql.Term, id uint) (bool, error) {
+	doc := bleve.NewDocIDQuery([]string{strconv.FormatUint(uint64(id), 10)})
+	hits, err := e.search(bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(termQuery(t), doc), 1, 0, false))
+	if err != nil {
+		return false, err
+	}
+	return len(hits) > 0, nil
+}
+
+// termQuery creates the query of a text or title term.
+func termQuery(t *searchql.Term) query.Query {
+	fuzzy := !t.Phrase && isFuzzyWord(t.Text)
+	newQuery := func(field string) query.BoostableQuery {
+		if t.Phrase {
+			q := bleve.NewMatchPhraseQuery(t.Text)
+			q.SetField(field)
+			return q
+		}
+		q := bleve.NewMatchQuery(t.Text)
+		q.SetField(field)
+		q.SetOperator(query.MatchQueryOperatorAnd)
+		if !fuzzy {
+			return q
+		}
+		// Latin words also match with typos and as the beginning of longer words.
+		// Exact matches match all of them, so they still rank first.
+		typo := bleve.NewMatchQuery(t.Text)
+		typo.SetField(field)
+		typo.SetFuzziness(fuzziness(t.Text))
+		prefix := bleve.NewPrefixQuery(strings.ToLower(t.Text))
+		prefix.SetField(field)
+		return bleve.NewDisjunctionQuery(q, typo, prefix)
+	}
+	var q query.Query
+	if t.Field == searchql.FieldTitle {
+		q = bleve.NewDisjunctionQuery(newQuery("Title"), newQuery("Subtitles"), newQuery("Romaji"))
+	} else {
+		// Any field may match, fields only decide the relevance
+		b := bleve.NewBooleanQuery()
+		b.AddMust(newQuery(""))
+		for _, f := range fieldBoosts {
+			fq := newQuery(f.field)
+			fq.SetBoost(f.boost)
+			b.AddShould(fq)
+		}
+		q = b
+	}
+	return q
+}
+
+func (e *bleveEngine) SuggestResources(text string) ([]uint, error) {
+	request := bleve.NewSearchRequestOptions(suggestQuery(text, "Title", "Subtitles", "Romaji"), maxSuggestHits, 0, false)
+	hits, err := e.search(request)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.id)
+	}
+	return ids, nil
+}
+
+func (e *bleveEngine) SuggestCharacters(text string) ([]CharacterMatch, error) {
+	nameFields := []string{"Characters.Name", "Characters.Alias"}
+	request := bleve.NewSearchRequestOptions(
+		suggestQuery(text, "Characters.Name", "Characters.Alias", "Characters.CV", "Romaji"),
+		maxSuggestHits, 0, false,
+	)
+	request.Fields = append(nameFields, "Characters.CV")
+	hits, err := e.search(request)
+	if err != nil {
+		return nil, err
+	}
+	var matches []CharacterMatch
+	for _, hit := range hits {
+		var names []string
+		for _, field := range nameFields {
+			names = append(names, hit.strings(field)...)
+		}
+		matches = append(matches, matchCharacters(hit.id, names, hit.strings("Characters.CV"), text)...)
+	}
+	return matches, nil
+}
+
+func (e *bleveEngine) search(request *bleve.SearchRequest) ([]*searchResultHit, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	result, err := e.index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*searchResultHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		id, err := strconv.ParseUint(hit.ID, 10, 32)
+		if err != nil {
+			continue
+		}
+		hits = append(hits, &searchResultHit{id: uint(id), score: hit.Score, fields: hit.Fields})
+	}
+	return hits, nil
+}
+
+type searchResultHit struct {
+	id     uint
+	score  float64
+	fields map[string]interface{}
+}
+
+// strings returns the values of a stored field.
+func (h *searchResultHit) strings(field string) []string {
+	switch v := h.fields[field].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func newIndex(indexPath string) (bleve.Index, error) {
+	mapping, err := newIndexMapping()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := bleve.New(indexPath, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.SetInternal(indexVersionKey, []byte(indexVersion)); err != nil {
+		_ = idx.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (e *bleveEngine) path(name string) string {
+	return filepath.Join(e.dir, name)
+}
+
+// currentIndexName returns the directory of the index in use.
+func (e *bleveEngine) currentIndexName() string {
+	data, err := os.ReadFile(e.path(currentIndexFile))
+	if err != nil {
+		return legacyIndexName
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// setCurrentIndexName records the index in use. The file is replaced atomically,
+// so the previous index stays in use if the server stops in between.
+func (e *bleveEngine) setCurrentIndexName(name string) error {
+	tmp := e.path(currentIndexFile + ".tmp")
+	if err := os.WriteFile(tmp, []byte(name), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.path(currentIndexFile))
+}
+
+// removeStaleIndexes removes the directories of indexes which are not in use,
+// such as those left by an interrupted rebuild.
+func (e *bleveEngine) removeStaleIndexes() {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		slog.Error("Failed to list search indexes", "error", err)
+		return
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, indexNamePrefix) || !strings.HasSuffix(name, indexNameSuffix) {
+			continue
+		}
+		if name == e.indexName || (e.building != nil && name == e.building.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(e.path(name)); err != nil {
+			slog.Error("Failed to remove stale search index", "name", name, "error", err)
+		}
+	}
+}
+
+// Rebuild builds a new index in a side directory while the current one keeps
+// serving searches, and replaces the current one when it is complete.
+func (e *bleveEngine) Rebuild() error {
+	if err := e.progress.start(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s%d%s", indexNamePrefix, time.Now().UnixNano(), indexNameSuffix)
+	idx, err := newIndex(e.path(name))
+	if err != nil {
+		e.progress.finish(err)
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	e.mu.Lock()
+	e.building = idx
+	e.mu.Unlock()
+
+	go func() {
+		err := forEachResourceDocument(&e.progress, func(doc *ResourceParams) error {
+			return idx.Index(doc.key(), doc)
+		})
+		if err == nil {
+			err = e.swapIndex(idx, name)
+		}
+		if err != nil {
+			slog.Error("Failed to rebuild search index", "error", err)
+			e.mu.Lock()
+			e.building = nil
+			e.mu.Unlock()
+			_ = idx.Close()
+			_ = os.RemoveAll(e.path(name))
+		}
+		e.progress.finish(err)
+	}()
+	return nil
+}
+
+func (e *bleveEngine) RebuildStatus() RebuildStatus {
+	return e.progress.get()
+}
+
+// swapIndex replaces the current index with a rebuilt one and removes the old one.
+func (e *bleveEngine) swapIndex(idx bleve.Index, name string) error {
+	if err := e.setCurrentIndexName(name); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	old, oldName := e.index, e.indexName
+	e.index, e.indexName = idx, name
+	e.building = nil
+	e.mu.Unlock()
+
+	if err := old.Close(); err != nil {
+		slog.Error("Failed to close old search index", "error", err)
+	}
+	if oldName != "" {
+		if err := os.RemoveAll(e.path(oldName)); err != nil {
+			slog.Error("Failed to remove old search index", "error", err)
+		}
+	}
+	slog.Info("Search index rebuilt", "name", name)
+	return nil
+}
@@ -0,0 +1,304 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"nysoure/server/config"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/searchql"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Engine is a search backend holding a document for each resource.
+type Engine interface {
+	Name() string
+	// Index adds or replaces the document of a resource.
+	Index(doc *ResourceParams) error
+	// Delete removes the document of a resource.
+	Delete(id uint) error
+	// Search returns the resources matching any word of a keyword, the best matches first.
+	Search(keyword string) ([]uint, error)
+	// MatchTerm returns the best resources matching a text or title term of a structured query,
+	// with the relevance of each one. The other terms are filters applied by the database.
+	// At most limit resources are returned, or all of them if limit is 0.
+	MatchTerm(t *searchql.Term, limit int) ([]Hit, error)
+	// MatchesTerm reports whether the document of a resource matches a text or title term,
+	// however it would rank among the other matches.
+	MatchesTerm(t *searchql.Term, id uint) (bool, error)
+	// SuggestResources returns the resources with a title starting with or containing the text.
+	SuggestResources(text string) ([]uint, error)
+	// SuggestCharacters returns the character names, aliases and voice actors matching the text.
+	SuggestCharacters(text string) ([]CharacterMatch, error)
+	// Rebuild recreates the documents of all resources in the background.
+	Rebuild() error
+	RebuildStatus() RebuildStatus
+	Close() error
+}
+
+// EngineFactory creates a search engine.
+type EngineFactory func() (Engine, error)
+
+const (
+	EngineBleve    = "bleve"
+	EnginePostgres = "postgres"
+)
+
+var (
+	factories   = map[string]EngineFactory{}
+	factoriesMu sync.RWMutex
+
+	active   Engine
+	activeMu sync.RWMutex
+)
+
+// RegisterEngine makes a search engine available under the given name.
+// Registering the same name twice replaces the previous factory.
+func RegisterEngine(name string, factory EngineFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewEngine creates a registered search engine by name.
+func NewEngine(name string) (Engine, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown search engine: %s", name)
+	}
+	return factory()
+}
+
+// Init creates the search engine chosen in the config. It must be called once at startup,
+// after the database is ready, and opens or starts rebuilding the index.
+func Init() error {
+	name := config.SearchEngine()
+	if name == "" {
+		name = EngineBleve
+	}
+	e, err := NewEngine(name)
+	if err != nil {
+		return fmt.Errorf("failed to create search engine: %w", err)
+	}
+	slog.Info("Search engine created", "engine", e.Name())
+	SetEngine(e)
+	return nil
+}
+
+// SetEngine replaces the active search engine. It is mainly used by tests.
+func SetEngine(e Engine) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	active = e
+}
+
+func activeEngine() Engine {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+func AddResourceToIndex(r model.Resource) error {
+	doc, err := resourceDocument(r)
+	if err != nil {
+		return err
+	}
+	return activeEngine().Index(doc)
+}
+
+func RemoveResourceFromIndex(id uint) error {
+	return activeEngine().Delete(id)
+}
+
+func SearchResource(keyword string) ([]uint, error) {
+	return activeEngine().Search(keyword)
+}
+
+// MatchTerm returns the resources matching a text or title term of a structured query,
+// with the relevance of each one.
+func MatchTerm(t *searchql.Term) ([]Hit, error) {
+	if t.Field != searchql.FieldText && t.Field != searchql.FieldTitle {
+		return nil, fmt.Errorf("field %q is not indexed", t.Field)
+	}
+	return activeEngine().MatchTerm(t, maxTermHits)
+}
+
+// MatchAllTerm returns all resources matching a text or title term of a structured query.
+// A negated term has to exclude every match, not only the best ones.
+func MatchAllTerm(t *searchql.Term) ([]Hit, error) {
+	if t.Field != searchql.FieldText && t.Field != searchql.FieldTitle {
+		return nil, fmt.Errorf("field %q is not indexed", t.Field)
+	}
+	return activeEngine().MatchTerm(t, 0)
+}
+
+// MatchesTerm reports whether a resource matches a text or title term of a structured query.
+// Unlike MatchTerm, the result does not depend on how many other resources match.
+func MatchesTerm(t *searchql.Term, id uint) (bool, error) {
+	if t.Field != searchql.FieldText && t.Field != searchql.FieldTitle {
+		return false, fmt.Errorf("field %q is not indexed", t.Field)
+	}
+	return activeEngine().MatchesTerm(t, id)
+}
+
+// SuggestResources returns the resources with a title starting with or containing the text.
+func SuggestResources(text string) ([]uint, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return activeEngine().SuggestResources(text)
+}
+
+// SuggestCharacters returns the character names, aliases and voice actors matching the text.
+func SuggestCharacters(text string) ([]CharacterMatch, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return activeEngine().SuggestCharacters(text)
+}
+
+// RebuildSearchIndex recreates the documents of all resources in the background,
+// while the current ones keep serving searches.
+func RebuildSearchIndex() error {
+	return activeEngine().Rebuild()
+}
+
+func GetRebuildStatus() RebuildStatus {
+	return activeEngine().RebuildStatus()
+}
+
+// ReindexResource updates the document of a resource, or removes it if the resource is deleted.
+func ReindexResource(id uint) error {
+	r, err := dao.GetResourceByID(id)
+	if model.IsNotFoundError(err) {
+		return RemoveResourceFromIndex(id)
+	}
+	if err != nil {
+		return err
+	}
+	return AddResourceToIndex(r)
+}
+
+// ReindexTag updates the documents of the resources with a tag or one of its aliases.
+// It returns the number of resources updated.
+func ReindexTag(tagID uint) (int, error) {
+	ids, err := dao.GetResourcesIdWithTag(tagID)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := ReindexResource(id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// RebuildStatus reports the progress of a rebuild.
+type RebuildStatus struct {
+	Running    bool       `json:"running"`
+	Total      int        `json:"total"`
+	Done       int        `json:"done"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// ETA is the estimated remaining time in seconds.
+	ETA   int    `json:"eta_seconds"`
+	Error string `json:"error,omitempty"`
+}
+
+// rebuildProgress tracks the rebuild of an engine.
+type rebuildProgress struct {
+	mu     sync.Mutex
+	status RebuildStatus
+}
+
+// start marks a rebuild as running. It fails if one is running already.
+func (p *rebuildProgress) start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status.Running {
+		return errors.New("the search index is already being rebuilt")
+	}
+	now := time.Now()
+	p.status = RebuildStatus{Running: true, StartedAt: &now}
+	return nil
+}
+
+func (p *rebuildProgress) update(f func(s *RebuildStatus)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f(&p.status)
+}
+
+func (p *rebuildProgress) finish(err error) {
+	p.update(func(s *RebuildStatus) {
+		now := time.Now()
+		s.Running = false
+		s.FinishedAt = &now
+		if err != nil {
+			s.Error = err.Error()
+		}
+	})
+}
+
+func (p *rebuildProgress) get() RebuildStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.status
+	if s.Running && s.Done > 0 && s.StartedAt != nil {
+		perResource := time.Since(*s.StartedAt) / time.Duration(s.Done)
+		s.ETA = int((perResource * time.Duration(s.Total-s.Done)).Seconds())
+	}
+	return s
+}
+
+const rebuildPageSize = 100
+
+// forEachResourceDocument creates the documents of all resources, reporting the progress.
+func forEachResourceDocument(p *rebuildProgress, f func(doc *ResourceParams) error) error {
+	for !dao.IsReady() {
+		time.Sleep(1 * time.Second)
+	}
+	total, err := dao.CountResources()
+	if err != nil {
+		return err
+	}
+	p.update(func(s *RebuildStatus) {
+		s.Total = int(total)
+	})
+	for page, totalPages := 1, 1; page <= totalPages; page++ {
+		res, n, err := dao.GetResourceList(page, rebuildPageSize, model.RSortTimeAsc, nil)
+		if err != nil {
+			return err
+		}
+		totalPages = n
+		for _, r := range res {
+			r, err := dao.GetResourceByID(r.ID)
+			if model.IsNotFoundError(err) {
+				// Deleted in the meantime
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			doc, err := resourceDocument(r)
+			if err != nil {
+				return err
+			}
+			if err := f(doc); err != nil {
+				return err
+			}
+			p.update(func(s *RebuildStatus) {
+				s.Done++
+				s.Total = max(s.Total, s.Done)
+			})
+		}
+		slog.Info("Rebuilding search index", "page", page, "total_pages", totalPages)
+	}
+	return nil
+}
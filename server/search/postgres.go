@@ -0,0 +1,259 @@
+package search
+
+import (
+	"log/slog"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/searchql"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+func init() {
+	RegisterEngine(EnginePostgres, func() (Engine, error) {
+		return newPostgresEngine(), nil
+	})
+}
+
+// postgresEngine keeps the documents in the database, using full-text search and
+// trigrams, so that all instances of the server share them.
+type postgresEngine struct {
+	prepareOnce sync.Once
+	prepareErr  error
+	progress    rebuildProgress
+}
+
+func newPostgresEngine() *postgresEngine {
+	e := &postgresEngine{}
+	go func() {
+		for !dao.IsReady() {
+			time.Sleep(1 * time.Second)
+		}
+		if err := e.prepare(); err != nil {
+			slog.Error("Failed to prepare search documents", "error", err)
+			return
+		}
+		// The documents are empty when switching from another engine
+		documents, err := dao.CountSearchDocuments()
+		if err != nil {
+			slog.Error("Failed to count search documents", "error", err)
+			return
+		}
+		resources, err := dao.CountResources()
+		if err != nil {
+			slog.Error("Failed to count resources", "error", err)
+			return
+		}
+		if documents == 0 && resources > 0 {
+			slog.Info("Search documents are empty, rebuilding")
+			if err := e.Rebuild(); err != nil {
+				slog.Error("Failed to rebuild search documents", "error", err)
+			}
+		}
+	}()
+	return e
+}
+
+// prepare creates the table of the documents the first time it is called.
+func (e *postgresEngine) prepare() error {
+	e.prepareOnce.Do(func() {
+		e.prepareErr = dao.InitSearchDocuments()
+	})
+	return e.prepareErr
+}
+
+func (e *postgresEngine) Name() string {
+	return EnginePostgres
+}
+
+func (e *postgresEngine) Index(doc *ResourceParams) error {
+	if err := e.prepare(); err != nil {
+		return err
+	}
+	return dao.SaveSearchDocument(searchDocument(doc))
+}
+
+func (e *postgresEngine) Delete(id uint) error {
+	if err := e.prepare(); err != nil {
+		return err
+	}
+	return dao.DeleteSearchDocument(id)
+}
+
+func (e *postgresEngine) Close() error {
+	return nil
+}
+
+func (e *postgresEngine) Search(keyword string) ([]uint, error) {
+	q := documentQuery(keyword)
+	q.Any = true
+	hits, err := e.match(q, maxTermHits)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids, nil
+}
+
+func (e *postgresEngine) MatchTerm(t *searchql.Term, limit int) ([]Hit, error) {
+	return e.match(termDocumentQuery(t), limit)
+}
+
+func (e *postgresEngine) MatchesTerm(t *searchql.Term, id uint) (bool, error) {
+	q := termDocumentQuery(t)
+	q.ResourceID = id
+	hits, err := e.match(q, 1)
+	if err != nil {
+		return false, err
+	}
+	return len(hits) > 0, nil
+}
+
+// termDocumentQuery creates the query of a text or title term.
+func termDocumentQuery(t *searchql.Term) dao.SearchDocumentQuery {
+	var q dao.SearchDocumentQuery
+	if t.Phrase {
+		q.Phrase = foldText(t.Text)
+	} else {
+		q = documentQuery(t.Text)
+		q.Fuzzy = isFuzzyWord(t.Text)
+	}
+	q.TitleOnly = t.Field == searchql.FieldTitle
+	return q
+}
+
+func (e *postgresEngine) match(q dao.SearchDocumentQuery, limit int) ([]Hit, error) {
+	if err := e.prepare(); err != nil {
+		return nil, err
+	}
+	hits, err := dao.MatchSearchDocuments(q, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Hit, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, Hit{ID: hit.ResourceID, Score: hit.Score})
+	}
+	return results, nil
+}
+
+func (e *postgresEngine) SuggestResources(text string) ([]uint, error) {
+	if err := e.prepare(); err != nil {
+		return nil, err
+	}
+	return dao.SuggestSearchDocumentTitles(foldText(strings.TrimSpace(text)), maxSuggestHits)
+}
+
+func (e *postgresEngine) SuggestCharacters(text string) ([]CharacterMatch, error) {
+	if err := e.prepare(); err != nil {
+		return nil, err
+	}
+	docs, err := dao.SuggestSearchDocumentCharacters(foldText(strings.TrimSpace(text)), maxSuggestHits)
+	if err != nil {
+		return nil, err
+	}
+	var matches []CharacterMatch
+	for _, doc := range docs {
+		matches = append(matches, matchCharacters(doc.ResourceID, doc.CharacterNames, doc.CharacterCVs, text)...)
+	}
+	return matches, nil
+}
+
+// Rebuild saves the documents of all resources again, and deletes those of resources
+// which no longer exist. The current documents keep serving searches meanwhile.
+func (e *postgresEngine) Rebuild() error {
+	if err := e.prepare(); err != nil {
+		return err
+	}
+	if err := e.progress.start(); err != nil {
+		return err
+	}
+	startedAt := time.Now()
+	go func() {
+		err := forEachResourceDocument(&e.progress, func(doc *ResourceParams) error {
+			return dao.SaveSearchDocument(searchDocument(doc))
+		})
+		if err == nil {
+			err = dao.DeleteSearchDocumentsBefore(startedAt)
+		}
+		if err != nil {
+			slog.Error("Failed to rebuild search documents", "error", err)
+		} else {
+			slog.Info("Search documents rebuilt")
+		}
+		e.progress.finish(err)
+	}()
+	return nil
+}
+
+func (e *postgresEngine) RebuildStatus() RebuildStatus {
+	return e.progress.get()
+}
+
+// documentQuery splits a text into words, which are matched as substrings
+// if they are written in a script without spaces between words.
+func documentQuery(text string) dao.SearchDocumentQuery {
+	var q dao.SearchDocumentQuery
+	for _, word := range strings.Fields(foldText(text)) {
+		if strings.IndexFunc(word, isSpacelessScript) >= 0 {
+			q.Substrings = append(q.Substrings, word)
+		} else {
+			q.Words = append(q.Words, word)
+		}
+	}
+	return q
+}
+
+func isSpacelessScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
+
+// searchDocument converts an index document to the folded text stored in the database.
+func searchDocument(doc *ResourceParams) *model.SearchDocument {
+	join := func(parts ...[]string) string {
+		var all []string
+		for _, p := range parts {
+			all = append(all, p...)
+		}
+		return foldText(strings.Join(all, " "))
+	}
+	romaji := func(texts []string) []string {
+		var result []string
+		for _, t := range texts {
+			if r, ok := romanize(t); ok {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+
+	titles := append([]string{doc.Title}, doc.Subtitles...)
+	var names, cvs []string
+	for _, c := range doc.Characters {
+		names = append(names, c.Name)
+		names = append(names, c.Alias...)
+		if c.CV != "" {
+			cvs = append(cvs, c.CV)
+		}
+	}
+	var other []string
+	other = append(other, doc.Links...)
+	for _, f := range doc.Files {
+		other = append(other, f.Name, f.Description)
+	}
+	return &model.SearchDocument{
+		ResourceID:     doc.Id,
+		Title:          join(titles, romaji(titles)),
+		Tags:           join(doc.Tags),
+		Characters:     join(names, romaji(names), cvs),
+		Other:          join(other),
+		Body:           join([]string{doc.Article}, doc.Comments),
+		CharacterNames: names,
+		CharacterCVs:   cvs,
+	}
+}
@@ -1,24 +1,40 @@
 package search
 
 import (
-	"errors"
 	"fmt"
 	"log/slog"
 	"nysoure/server/dao"
 	"nysoure/server/model"
-	"nysoure/server/utils"
-	"os"
-	"strconv"
 	"sync"
 	"time"
 
-	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis"
 )
 
-var (
-	index bleve.Index
-	mu    = sync.RWMutex{}
-)
+// maxTermHits limits the number of resources a single query term can match.
+const maxTermHits = 1000
+
+// maxIndexedComments limits the number of comments indexed for each resource.
+const maxIndexedComments = 100
+
+// fieldBoosts weights the fields of the index document when ranking text matches.
+var fieldBoosts = []struct {
+	field string
+	boost float64
+}{
+	{"Title", 5},
+	{"Subtitles", 4},
+	{"Romaji", 3},
+	{"Tags", 3},
+	{"Characters.Name", 2},
+	{"Characters.Alias", 2},
+	{"Characters.CV", 1.5},
+	{"Links", 1.5},
+	{"Files.Name", 1},
+	{"Files.Description", 1},
+	{"Article", 1},
+	{"Comments", 0.5},
+}
 
 type ResourceParams struct {
 	Id         uint
@@ -26,6 +42,16 @@ type ResourceParams struct {
 	Subtitles  []string
 	Time       time.Time
 	Characters []ResourceCharacter
+	Article    string
+	// Tags contains the names of the tags and all their aliases.
+	Tags  []string
+	Files []ResourceFile
+	// Links contains the labels of the links.
+	Links []string
+	// Comments contains the latest comments.
+	Comments []string
+	// Romaji contains the titles and character names written in kana, transcribed to romaji.
+	Romaji []string
 }
 
 type ResourceCharacter struct {
@@ -34,9 +60,37 @@ type ResourceCharacter struct {
 	CV    string
 }
 
-func AddResourceToIndex(r model.Resource) error {
-	mu.RLock()
-	defer mu.RUnlock()
+type ResourceFile struct {
+	Name        string
+	Description string
+}
+
+func (p *ResourceParams) key() string {
+	return fmt.Sprintf("%d", p.Id)
+}
+
+// resourceDocument creates the index document of a resource.
+func resourceDocument(r model.Resource) (*ResourceParams, error) {
+	var tags []string
+	if len(r.Tags) > 0 {
+		ids := make([]uint, 0, len(r.Tags))
+		for _, t := range r.Tags {
+			ids = append(ids, t.ID)
+		}
+		var err error
+		tags, err = dao.GetTagGroupNames(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var comments []string
+	if r.Comments > 0 {
+		var err error
+		comments, err = dao.ListRecentCommentContents(r.ID, maxIndexedComments)
+		if err != nil {
+			return nil, err
+		}
+	}
 	cs := make([]ResourceCharacter, 0, len(r.Characters))
 	for _, c := range r.Characters {
 		cs = append(cs, ResourceCharacter{
@@ -45,123 +99,89 @@ func AddResourceToIndex(r model.Resource) error {
 			CV:    c.CV,
 		})
 	}
-	return index.Index(fmt.Sprintf("%d", r.ID), ResourceParams{
+	files := make([]ResourceFile, 0, len(r.Files))
+	for _, f := range r.Files {
+		files = append(files, ResourceFile{
+			Name:        f.Filename,
+			Description: f.Description,
+		})
+	}
+	var romaji []string
+	names := append([]string{r.Title}, r.AlternativeTitles...)
+	for _, c := range r.Characters {
+		names = append(names, c.Name)
+		names = append(names, c.Alias...)
+	}
+	for _, name := range names {
+		if text, ok := romanize(name); ok {
+			romaji = append(romaji, text)
+		}
+	}
+	links := make([]string, 0, len(r.Links))
+	for _, l := range r.Links {
+		if l.Label != "" {
+			links = append(links, l.Label)
+		}
+	}
+	return &ResourceParams{
 		Id:         r.ID,
 		Title:      r.Title,
 		Subtitles:  r.AlternativeTitles,
 		Time:       r.CreatedAt,
 		Characters: cs,
-	})
+		Article:    r.Article,
+		Tags:       tags,
+		Files:      files,
+		Links:      links,
+		Comments:   comments,
+		Romaji:     romaji,
+	}, nil
 }
 
-func RemoveResourceFromIndex(id uint) error {
-	return index.Delete(fmt.Sprintf("%d", id))
+type Hit struct {
+	ID    uint
+	Score float64
 }
 
-func createIndex() error {
-	for !dao.IsReady() {
-		time.Sleep(1 * time.Second)
+// minFuzzyWordLength is the length from which Latin words are matched fuzzily.
+const minFuzzyWordLength = 4
+
+// isFuzzyWord reports whether a word is a single Latin word long enough for fuzzy matching.
+func isFuzzyWord(word string) bool {
+	if len(word) < minFuzzyWordLength {
+		return false
 	}
-	page := 1
-	total := 1
-	current := 0
-	for page <= total {
-		res, totalPages, err := dao.GetResourceList(page, 100, model.RSortTimeAsc)
-		if err != nil {
-			return err
-		}
-		for _, r := range res {
-			r, err := dao.GetResourceByID(r.ID)
-			if err != nil {
-				return err
-			}
-			err = AddResourceToIndex(r)
-			if err != nil {
-				return err
-			}
-			current++
-			if current%20 == 0 {
-				slog.Info("Rebuilding search index", "current", current, "total", totalPages*100)
-			}
+	for _, r := range word {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
 		}
-		page++
-		total = totalPages
 	}
-	return nil
+	return true
 }
 
-func init() {
-	indexPath := utils.GetStoragePath() + "/resource_index.bleve"
-
-	var err error
-	index, err = bleve.Open(indexPath)
-	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
-		mapping := bleve.NewIndexMapping()
-		index, err = bleve.New(indexPath, mapping)
-		if err != nil {
-			panic("Failed to create search index: " + err.Error())
-		}
-		go func() {
-			err := createIndex()
-			if err != nil {
-				panic("Failed to create search index: " + err.Error())
-			}
-		}()
-	} else if err != nil {
-		panic("Failed to open search index: " + err.Error())
+// fuzziness returns the number of typos allowed in a word.
+func fuzziness(word string) int {
+	if len(word) >= 8 {
+		return 2
 	}
+	return 1
 }
 
-func SearchResource(keyword string) ([]uint, error) {
-	mu.RLock()
-	defer mu.RUnlock()
-	query := bleve.NewMatchQuery(keyword)
-	searchRequest := bleve.NewSearchRequest(query)
-	searchResults, err := index.Search(searchRequest)
+var defaultAnalyzer = sync.OnceValue(func() *analysis.Analyzer {
+	m, err := newIndexMapping()
 	if err != nil {
-		return nil, err
-	}
-
-	results := make([]uint, 0)
-	for _, hit := range searchResults.Hits {
-		id, err := strconv.ParseUint(hit.ID, 10, 32)
-		if err != nil {
-			continue
-		}
-		results = append(results, uint(id))
+		slog.Error("Failed to create index mapping", "error", err)
+		return nil
 	}
+	return m.AnalyzerNamed(analyzerName)
+})
 
-	return results, nil
-}
-
+// IsStopWord reports whether the index ignores the word entirely.
 func IsStopWord(word string) bool {
-	mapping := bleve.NewIndexMapping()
-	analyzerName := mapping.DefaultAnalyzer
-	analyzer := mapping.AnalyzerNamed(analyzerName)
+	analyzer := defaultAnalyzer()
 	if analyzer == nil {
 		return false
 	}
 	tokens := analyzer.Analyze([]byte(word))
 	return len(tokens) == 0
 }
-
-func RebuildSearchIndex() error {
-	mu.Lock()
-	defer mu.Unlock()
-	err := index.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close search index: %w", err)
-	}
-	indexPath := utils.GetStoragePath() + "/resource_index.bleve"
-	err = os.RemoveAll(indexPath)
-	if err != nil {
-		return fmt.Errorf("failed to remove search index: %w", err)
-	}
-	mapping := bleve.NewIndexMapping()
-	index, err = bleve.New(indexPath, mapping)
-	if err != nil {
-		return fmt.Errorf("failed to create search index: %w", err)
-	}
-	go createIndex()
-	return nil
-}
@@ -2,28 +2,30 @@ package search
 
 import (
 	"nysoure/server/model"
+	"nysoure/server/searchql"
 	"os"
+	"slices"
 	"testing"
 
 	"github.com/blevesearch/bleve"
 	"gorm.io/gorm"
 )
 
-func Init() {
-	err := index.Close()
+func initTestEngine() {
+	_ = os.RemoveAll("search_test.bleve")
+	mapper, err := newIndexMapping()
 	if err != nil {
 		panic(err)
 	}
-	_ = os.RemoveAll("search_test.bleve")
-	mapper := bleve.NewIndexMapping()
-	index, err = bleve.New("search_test.bleve", mapper)
+	index, err := bleve.New("search_test.bleve", mapper)
 	if err != nil {
 		panic(err)
 	}
+	SetEngine(&bleveEngine{index: index})
 }
 
 func TearDown() {
-	err := index.Close()
+	err := activeEngine().Close()
 	if err != nil {
 		panic(err)
 	}
@@ -32,7 +34,7 @@ func TearDown() {
 }
 
 func TestSearchResource(t *testing.T) {
-	Init()
+	initTestEngine()
 	defer TearDown()
 
 	resources := []model.Resource{
@@ -163,7 +165,7 @@ func TestSearchResource(t *testing.T) {
 }
 
 func TestIsStopWord(t *testing.T) {
-	Init()
+	initTestEngine()
 	defer TearDown()
 
 	stopWords := []string{"the", "is", "at", "which", "on", "and", "a", "an", "in", "to", "of"}
@@ -181,3 +183,296 @@ func TestIsStopWord(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchTerm(t *testing.T) {
+	initTestEngine()
+	defer TearDown()
+
+	resources := []model.Resource{
+		{Model: gorm.Model{ID: 1}, Title: "Lost City", AlternativeTitles: []string{"City of Gold"},
+			Characters: []model.Character{{Name: "Alice", CV: "Kana"}}},
+		{Model: gorm.Model{ID: 2}, Title: "Gold Rush", AlternativeTitles: []string{"Lost Gold"}},
+		{Model: gorm.Model{ID: 3}, Title: "Alice in the City"},
+	}
+	for _, r := range resources {
+		if err := AddResourceToIndex(r); err != nil {
+			t.Fatalf("Failed to add resource ID %d to index: %v", r.ID, err)
+		}
+	}
+
+	tests := []struct {
+		term     searchql.Term
+		expected []uint
+	}{
+		{searchql.Term{Field: searchql.FieldText, Text: "Alice"}, []uint{1, 3}},
+		{searchql.Term{Field: searchql.FieldTitle, Text: "Alice"}, []uint{3}},
+		{searchql.Term{Field: searchql.FieldTitle, Text: "gold"}, []uint{1, 2}},
+		{searchql.Term{Field: searchql.FieldText, Text: "rush gold"}, []uint{2}},
+		{searchql.Term{Field: searchql.FieldText, Text: "gold lost", Phrase: true}, nil},
+		{searchql.Term{Field: searchql.FieldTitle, Text: "city of gold", Phrase: true}, []uint{1}},
+	}
+	for _, test := range tests {
+		hits, err := MatchTerm(&test.term)
+		if err != nil {
+			t.Fatalf("Failed to match %+v: %v", test.term, err)
+		}
+		var ids []uint
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, test.expected) && !(len(ids) == 0 && len(test.expected) == 0) {
+			t.Errorf("Expected %v for %+v, got %v", test.expected, test.term, ids)
+		}
+	}
+}
+
+func TestMatchesTerm(t *testing.T) {
+	initTestEngine()
+	defer TearDown()
+
+	// More resources match than a single term returns, the last one ranks below all of them
+	for i := 1; i <= maxTermHits+1; i++ {
+		r := model.Resource{Model: gorm.Model{ID: uint(i)}, Title: "Common Word Common"}
+		if i == maxTermHits+1 {
+			r.Title = "Common Story"
+		}
+		if err := AddResourceToIndex(r); err != nil {
+			t.Fatalf("Failed to add resource ID %d to index: %v", r.ID, err)
+		}
+	}
+
+	last := uint(maxTermHits + 1)
+	term := &searchql.Term{Field: searchql.FieldText, Text: "common"}
+	hits, err := MatchTerm(term)
+	if err != nil {
+		t.Fatalf("Failed to match: %v", err)
+	}
+	if slices.ContainsFunc(hits, func(h Hit) bool { return h.ID == last }) {
+		t.Fatalf("Expected resource %d to rank below the returned hits", last)
+	}
+	all, err := MatchAllTerm(term)
+	if err != nil {
+		t.Fatalf("Failed to match: %v", err)
+	}
+	if len(all) != maxTermHits+1 {
+		t.Errorf("Expected all %d resources to match, got %d", maxTermHits+1, len(all))
+	}
+	matched, err := MatchesTerm(term, last)
+	if err != nil {
+		t.Fatalf("Failed to match: %v", err)
+	}
+	if !matched {
+		t.Errorf("Expected resource %d to match %q", last, term.Text)
+	}
+	matched, err = MatchesTerm(&searchql.Term{Field: searchql.FieldTitle, Text: "word"}, last)
+	if err != nil {
+		t.Fatalf("Failed to match: %v", err)
+	}
+	if matched {
+		t.Errorf("Expected resource %d not to match \"word\"", last)
+	}
+}
+
+func TestMatchTermFields(t *testing.T) {
+	initTestEngine()
+	defer TearDown()
+
+	resources := []model.Resource{
+		{Model: gorm.Model{ID: 1}, Title: "Moonlight", Article: "A story about a lighthouse keeper."},
+		{Model: gorm.Model{ID: 2}, Title: "Lighthouse", Links: []model.Link{{URL: "https://example.com", Label: "Steam"}}},
+		{Model: gorm.Model{ID: 3}, Title: "Harbor", Files: []model.File{{Filename: "harbor_patch.zip", Description: "Chinese translation"}}},
+	}
+	for _, r := range resources {
+		if err := AddResourceToIndex(r); err != nil {
+			t.Fatalf("Failed to add resource ID %d to index: %v", r.ID, err)
+		}
+	}
+
+	tests := []struct {
+		text     string
+		expected []uint
+	}{
+		// The title match ranks first
+		{"lighthouse", []uint{2, 1}},
+		{"steam", []uint{2}},
+		{"translation", []uint{3}},
+		{"keeper", []uint{1}},
+	}
+	for _, test := range tests {
+		hits, err := MatchTerm(&searchql.Term{Text: test.text})
+		if err != nil {
+			t.Fatalf("Failed to match %q: %v", test.text, err)
+		}
+		var ids []uint
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.text, ids)
+		}
+	}
+}
+
+func TestMatchTermCJK(t *testing.T) {
+	initTestEngine()
+	defer TearDown()
+
+	resources := []model.Resource{
+		{Model: gorm.Model{ID: 1}, Title: "魔法少女まどか☆マギカ"},
+		{Model: gorm.Model{ID: 2}, Title: "ソードアート・オンライン", AlternativeTitles: []string{"刀剑神域"}},
+		{Model: gorm.Model{ID: 3}, Title: "The Great Adventure"},
+		{Model: gorm.Model{ID: 4}, Title: "Ｆｕｌｌｗｉｄｔｈ Ｓｔｏｒｙ"},
+	}
+	for _, r := range resources {
+		if err := AddResourceToIndex(r); err != nil {
+			t.Fatalf("Failed to add resource ID %d to index: %v", r.ID, err)
+		}
+	}
+
+	tests := []struct {
+		field    searchql.Field
+		text     string
+		expected []uint
+	}{
+		// Substrings of CJK text
+		{searchql.FieldText, "少女", []uint{1}},
+		{searchql.FieldText, "神域", []uint{2}},
+		{searchql.FieldText, "魔", []uint{1}},
+		// Katakana, hiragana and half width katakana
+		{searchql.FieldText, "まぎか", []uint{1}},
+		{searchql.FieldText, "ｿｰﾄﾞ", []uint{2}},
+		// Romaji
+		{searchql.FieldText, "madoka", []uint{1}},
+		{searchql.FieldTitle, "onrain", []uint{2}},
+		// Full width Latin characters
+		{searchql.FieldText, "fullwidth", []uint{4}},
+		// Typos and prefixes
+		{searchql.FieldText, "adventrue", []uint{3}},
+		{searchql.FieldText, "advent", []uint{3}},
+		{searchql.FieldText, "grea", []uint{3}},
+	}
+	for _, test := range tests {
+		hits, err := MatchTerm(&searchql.Term{Field: test.field, Text: test.text})
+		if err != nil {
+			t.Fatalf("Failed to match %q: %v", test.text, err)
+		}
+		var ids []uint
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.text, ids)
+		}
+	}
+}
+
+func TestRomanize(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+		ok       bool
+	}{
+		{"まどか", "madoka", true},
+		{"マギカ", "magika", true},
+		{"しょうじょ", "shoujo", true},
+		{"がっこう", "gakkou", true},
+		{"マッチ", "matchi", true},
+		{"ラーメン", "raamen", true},
+		{"魔法少女まどか☆マギカ", "魔法少女 madoka ☆ magika", true},
+		{"Fate stay night", "Fate stay night", false},
+	}
+	for _, test := range tests {
+		result, ok := romanize(test.text)
+		if result != test.expected || ok != test.ok {
+			t.Errorf("Expected %q, %v for %q, got %q, %v", test.expected, test.ok, test.text, result, ok)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	initTestEngine()
+	defer TearDown()
+
+	resources := []model.Resource{
+		{Model: gorm.Model{ID: 1}, Title: "Summer Pockets", Characters: []model.Character{
+			{Name: "鳴瀬しろは", Alias: []string{"しろは"}, CV: "小原好美"},
+		}},
+		{Model: gorm.Model{ID: 2}, Title: "Little Busters!", AlternativeTitles: []string{"リトルバスターズ"}, Characters: []model.Character{
+			{Name: "棗鈴", CV: "民安ともえ"},
+		}},
+		{Model: gorm.Model{ID: 3}, Title: "Summer Days"},
+	}
+	for _, r := range resources {
+		if err := AddResourceToIndex(r); err != nil {
+			t.Fatalf("Failed to add resource ID %d to index: %v", r.ID, err)
+		}
+	}
+
+	titleTests := []struct {
+		text     string
+		expected []uint
+	}{
+		{"sum", []uint{1, 3}},
+		{"summer poc", []uint{1}},
+		{"りとる", []uint{2}},
+		{"ritorub", []uint{2}},
+	}
+	for _, test := range titleTests {
+		ids, err := SuggestResources(test.text)
+		if err != nil {
+			t.Fatalf("Failed to suggest %q: %v", test.text, err)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.text, ids)
+		}
+	}
+
+	characterTests := []struct {
+		text     string
+		expected []CharacterMatch
+	}{
+		{"しろ", []CharacterMatch{{Name: "鳴瀬しろは", ResourceID: 1}, {Name: "しろは", ResourceID: 1}}},
+		{"shiro", []CharacterMatch{{Name: "鳴瀬しろは", ResourceID: 1}, {Name: "しろは", ResourceID: 1}}},
+		{"民安", []CharacterMatch{{Name: "民安ともえ", CV: true, ResourceID: 2}}},
+	}
+	for _, test := range characterTests {
+		matches, err := SuggestCharacters(test.text)
+		if err != nil {
+			t.Fatalf("Failed to suggest %q: %v", test.text, err)
+		}
+		if !slices.Equal(matches, test.expected) {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.text, matches)
+		}
+	}
+}
+
+func TestPostgresDocument(t *testing.T) {
+	doc := searchDocument(&ResourceParams{
+		Id:         1,
+		Title:      "リトルバスターズ",
+		Subtitles:  []string{"Little Busters！"},
+		Tags:       []string{"Visual Novel"},
+		Characters: []ResourceCharacter{{Name: "棗鈴", Alias: []string{"リン"}, CV: "民安ともえ"}},
+		Files:      []ResourceFile{{Name: "LB.zip", Description: "Full Edition"}},
+		Article:    "An Article",
+	})
+	if doc.Title != "りとるばすたーず little busters! ritorubasutaazu" {
+		t.Errorf("Unexpected title %q", doc.Title)
+	}
+	if doc.Characters != "棗鈴 りん rin 民安ともえ" {
+		t.Errorf("Unexpected characters %q", doc.Characters)
+	}
+	if doc.Other != "lb.zip full edition" || doc.Body != "an article" || doc.Tags != "visual novel" {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+	if !slices.Equal(doc.CharacterNames, []string{"棗鈴", "リン"}) || !slices.Equal(doc.CharacterCVs, []string{"民安ともえ"}) {
+		t.Errorf("Unexpected character names %v, %v", doc.CharacterNames, doc.CharacterCVs)
+	}
+
+	q := documentQuery("Lost 東京 シティ")
+	if !slices.Equal(q.Words, []string{"lost"}) || !slices.Equal(q.Substrings, []string{"東京", "してぃ"}) {
+		t.Errorf("Unexpected query %+v", q)
+	}
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// maxSuggestHits limits the number of resources looked at for suggestions.
+const maxSuggestHits = 50
+
+type CharacterMatch struct {
+	Name       string
+	CV         bool
+	ResourceID uint
+}
+
+// foldText folds case, full width Latin characters and katakana.
+func foldText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		return toHiragana(r)
+	}, strings.ToLower(s))
+}
+
+// ContainsText reports whether s contains text, ignoring case, width and the kana script.
+// Kana in s may also be matched by romaji.
+func ContainsText(s, text string) bool {
+	text = foldText(strings.TrimSpace(text))
+	if strings.Contains(foldText(s), text) {
+		return true
+	}
+	romaji, ok := romanize(s)
+	return ok && strings.Contains(foldText(romaji), text)
+}
+
+// suggestQuery matches the words of a partially typed text, the last of which may be incomplete.
+func suggestQuery(text string, fields ...string) query.Query {
+	words := strings.Fields(text)
+	last := foldText(words[len(words)-1])
+	disjuncts := make([]query.Query, 0, len(fields))
+	for _, field := range fields {
+		whole := bleve.NewMatchQuery(text)
+		whole.SetField(field)
+		whole.SetOperator(query.MatchQueryOperatorAnd)
+		prefix := bleve.NewPrefixQuery(last)
+		prefix.SetField(field)
+		var q query.Query = prefix
+		if len(words) > 1 {
+			rest := bleve.NewMatchQuery(strings.Join(words[:len(words)-1], " "))
+			rest.SetField(field)
+			rest.SetOperator(query.MatchQueryOperatorAnd)
+			q = bleve.NewConjunctionQuery(rest, prefix)
+		}
+		disjuncts = append(disjuncts, whole, q)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// matchCharacters returns the names and voice actors of the characters of a resource
+// which contain the text.
+func matchCharacters(resourceID uint, names, cvs []string, text string) []CharacterMatch {
+	var matches []CharacterMatch
+	for _, name := range names {
+		if ContainsText(name, text) {
+			matches = append(matches, CharacterMatch{Name: name, ResourceID: resourceID})
+		}
+	}
+	for _, cv := range cvs {
+		if ContainsText(cv, text) {
+			matches = append(matches, CharacterMatch{Name: cv, CV: true, ResourceID: resourceID})
+		}
+	}
+	return matches
+}
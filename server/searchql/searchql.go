@@ -0,0 +1,605 @@
+// Package searchql parses the resource search query language, such as
+//
+//	tag:RPG -tag:NTR released:2020..2023 uploader:alice has:file size>1GB
+//
+// Terms are combined with AND by default. "OR" (or "|") combines alternatives,
+// "-" (or "NOT") negates a term or a group, and parentheses group terms.
+// Words without a field qualifier are matched against the text of resources.
+package searchql
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Field string
+
+const (
+	FieldText      Field = ""
+	FieldTitle     Field = "title"
+	FieldTag       Field = "tag"
+	FieldTagType   Field = "tagtype"
+	FieldUploader  Field = "uploader"
+	FieldReleased  Field = "released"
+	FieldCreated   Field = "created"
+	FieldViews     Field = "views"
+	FieldDownloads Field = "downloads"
+	FieldSize      Field = "size"
+	FieldHas       Field = "has"
+)
+
+// fieldNames maps the qualifiers accepted in queries to fields.
+var fieldNames = map[string]Field{
+	"title":     FieldTitle,
+	"tag":       FieldTag,
+	"tagtype":   FieldTagType,
+	"type":      FieldTagType,
+	"uploader":  FieldUploader,
+	"user":      FieldUploader,
+	"released":  FieldReleased,
+	"release":   FieldReleased,
+	"created":   FieldCreated,
+	"views":     FieldViews,
+	"downloads": FieldDownloads,
+	"size":      FieldSize,
+	"has":       FieldHas,
+}
+
+// Values accepted by the has: qualifier.
+const (
+	HasFile      = "file"
+	HasImage     = "image"
+	HasCover     = "cover"
+	HasCharacter = "character"
+	HasLink      = "link"
+	HasRelease   = "release"
+)
+
+var hasValues = []string{HasFile, HasImage, HasCover, HasCharacter, HasLink, HasRelease}
+
+// MaxTerms limits the number of terms in a query, which bounds the size of the compiled queries.
+const MaxTerms = 20
+
+// Node is a node of a parsed query: *Term, *And, *Or or *Not.
+type Node interface {
+	// Pos is the position of the node in the query, counted in characters.
+	Pos() int
+}
+
+type And struct {
+	Children []Node
+	pos      int
+}
+
+type Or struct {
+	Children []Node
+	pos      int
+}
+
+type Not struct {
+	Child Node
+	pos   int
+}
+
+// Term is a single condition. Which of the value fields is set depends on the field.
+type Term struct {
+	Field Field
+	// Text is the value of text, title, tag, tag type, uploader and has terms.
+	Text string
+	// Phrase is set for quoted text, which has to match as a whole.
+	Phrase bool
+	// Min and Max are the inclusive bounds of views, downloads and size terms. Nil means unbounded.
+	Min, Max *int64
+	// Since and Until are the bounds of date terms. Until is exclusive. Nil means unbounded.
+	Since, Until *time.Time
+	pos          int
+}
+
+func (n *And) Pos() int  { return n.pos }
+func (n *Or) Pos() int   { return n.pos }
+func (n *Not) Pos() int  { return n.pos }
+func (n *Term) Pos() int { return n.pos }
+
+// ParseError describes a malformed query.
+type ParseError struct {
+	// Position is the position of the error in the query, counted in characters from 0.
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+func errorAt(pos int, format string, args ...any) *ParseError {
+	return &ParseError{Position: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// Parse parses a query. The error is a *ParseError if the query is malformed.
+func Parse(query string) (Node, error) {
+	tokens, err := tokenize([]rune(query))
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, end: len([]rune(query))}
+	if len(tokens) == 0 {
+		return nil, errorAt(0, "empty query")
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, errorAt(t.pos, "unexpected %q", t.text)
+	}
+	if len(Terms(node)) > MaxTerms {
+		return nil, errorAt(Terms(node)[MaxTerms].pos, "too many terms, at most %d are allowed", MaxTerms)
+	}
+	return node, nil
+}
+
+// Terms returns the terms of a query in order.
+func Terms(node Node) []*Term {
+	var terms []*Term
+	var walk func(Node)
+	walk = func(n Node) {
+		switch n := n.(type) {
+		case *Term:
+			terms = append(terms, n)
+		case *And:
+			for _, c := range n.Children {
+				walk(c)
+			}
+		case *Or:
+			for _, c := range n.Children {
+				walk(c)
+			}
+		case *Not:
+			walk(n.Child)
+		}
+	}
+	walk(node)
+	return terms
+}
+
+// NegatedTerms returns the terms of a query which are inside a negation, in order.
+func NegatedTerms(node Node) []*Term {
+	var terms []*Term
+	var walk func(Node)
+	walk = func(n Node) {
+		switch n := n.(type) {
+		case *And:
+			for _, c := range n.Children {
+				walk(c)
+			}
+		case *Or:
+			for _, c := range n.Children {
+				walk(c)
+			}
+		case *Not:
+			terms = append(terms, Terms(n.Child)...)
+		}
+	}
+	walk(node)
+	return terms
+}
+
+// IsPlainText reports whether the query only consists of text terms combined with AND.
+func IsPlainText(node Node) bool {
+	switch n := node.(type) {
+	case *Term:
+		return n.Field == FieldText
+	case *And:
+		for _, c := range n.Children {
+			if !IsPlainText(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// RemoveTerms removes the terms for which drop returns true, as if they always matched.
+// It returns nil if nothing is left.
+func RemoveTerms(node Node, drop func(*Term) bool) Node {
+	switch n := node.(type) {
+	case *Term:
+		if drop(n) {
+			return nil
+		}
+		return n
+	case *Not:
+		child := RemoveTerms(n.Child, drop)
+		if child == nil {
+			return nil
+		}
+		return &Not{Child: child, pos: n.pos}
+	case *And, *Or:
+		var children []Node
+		var pos int
+		if and, ok := n.(*And); ok {
+			children, pos = and.Children, and.pos
+		} else {
+			children, pos = n.(*Or).Children, n.(*Or).pos
+		}
+		kept := make([]Node, 0, len(children))
+		for _, c := range children {
+			if c = RemoveTerms(c, drop); c != nil {
+				kept = append(kept, c)
+			} else if _, ok := n.(*Or); ok {
+				// An alternative which always matches makes the whole group match
+				return nil
+			}
+		}
+		switch {
+		case len(kept) == 0:
+			return nil
+		case len(kept) == 1:
+			return kept[0]
+		}
+		if _, ok := n.(*And); ok {
+			return &And{Children: kept, pos: pos}
+		}
+		return &Or{Children: kept, pos: pos}
+	}
+	return node
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	end    int
+}
+
+func (p *parser) peek() *token {
+	if p.i >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.i]
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for t := p.peek(); t != nil && t.kind == tokenOr; t = p.peek() {
+		p.i++
+		if next := p.peek(); next == nil || next.kind == tokenRParen || next.kind == tokenOr {
+			return nil, errorAt(t.pos, "expected a term after %q", t.text)
+		}
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Or{Children: children, pos: first.Pos()}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	var children []Node
+	for t := p.peek(); t != nil && t.kind != tokenOr && t.kind != tokenRParen; t = p.peek() {
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		if t := p.peek(); t != nil {
+			return nil, errorAt(t.pos, "unexpected %q", t.text)
+		}
+		return nil, errorAt(p.end, "expected a term")
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &And{Children: children, pos: children[0].Pos()}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t := p.peek()
+	if t.kind == tokenNot {
+		p.i++
+		next := p.peek()
+		if next == nil || next.kind == tokenOr || next.kind == tokenRParen {
+			return nil, errorAt(t.pos, "expected a term after %q", t.text)
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Child: child, pos: t.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.peek()
+	p.i++
+	switch t.kind {
+	case tokenLParen:
+		if next := p.peek(); next != nil && next.kind == tokenRParen {
+			return nil, errorAt(next.pos, "empty group")
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokenRParen {
+			return nil, errorAt(t.pos, "missing closing parenthesis")
+		}
+		p.i++
+		return node, nil
+	case tokenWord:
+		return parseTerm(t)
+	}
+	return nil, errorAt(t.pos, "unexpected %q", t.text)
+}
+
+// parseTerm splits a word into the field qualifier, the operator and the value.
+func parseTerm(t *token) (*Term, error) {
+	if !t.quoted {
+		name, op, value, valuePos := splitQualifier(t)
+		if field, ok := fieldNames[strings.ToLower(name)]; ok {
+			if value.text == "" {
+				return nil, errorAt(valuePos, "missing value for %q", name)
+			}
+			term := &Term{Field: field, pos: t.pos}
+			if err := term.setValue(op, value, valuePos); err != nil {
+				return nil, err
+			}
+			return term, nil
+		}
+	}
+	return &Term{Field: FieldText, Text: t.word(), Phrase: t.quoted, pos: t.pos}, nil
+}
+
+type value struct {
+	text   string
+	quoted bool
+}
+
+// splitQualifier splits "name:value", "name>value" and the like. The name is empty if
+// the word has no qualifier.
+func splitQualifier(t *token) (name, op string, v value, valuePos int) {
+	if len(t.parts) == 0 || t.parts[0].quoted {
+		return "", "", value{}, 0
+	}
+	for i, r := range t.parts[0].text {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_') {
+			if i == 0 {
+				return "", "", value{}, 0
+			}
+			rest := t.parts[0].text[i:]
+			for _, candidate := range []string{">=", "<=", ":", ">", "<", "="} {
+				if strings.HasPrefix(rest, candidate) {
+					name, op = t.parts[0].text[:i], candidate
+					rest = rest[len(candidate):]
+					// Comparisons may also follow a colon, such as views:>100
+					if op == ":" {
+						for _, cmp := range []string{">=", "<=", ">", "<"} {
+							if strings.HasPrefix(rest, cmp) {
+								op, rest = cmp, rest[len(cmp):]
+								break
+							}
+						}
+					}
+					valuePos = t.pos + len([]rune(t.parts[0].text)) - len([]rune(rest))
+					// The value may be followed by quoted parts, such as tag:"Visual Novel"
+					v.text = rest
+					for _, part := range t.parts[1:] {
+						v.text += part.text
+						v.quoted = v.quoted || part.quoted
+					}
+					return name, op, v, valuePos
+				}
+			}
+			return "", "", value{}, 0
+		}
+	}
+	return "", "", value{}, 0
+}
+
+func (t *Term) setValue(op string, v value, pos int) error {
+	switch t.Field {
+	case FieldTitle:
+		if op != ":" && op != "=" {
+			return errorAt(pos, "operator %q is not supported for %s", op, t.Field)
+		}
+		t.Text, t.Phrase = v.text, v.quoted
+	case FieldTag, FieldTagType, FieldUploader:
+		if op != ":" && op != "=" {
+			return errorAt(pos, "operator %q is not supported for %s", op, t.Field)
+		}
+		t.Text = v.text
+	case FieldHas:
+		if op != ":" && op != "=" {
+			return errorAt(pos, "operator %q is not supported for %s", op, t.Field)
+		}
+		t.Text = strings.ToLower(v.text)
+		if !slices.Contains(hasValues, t.Text) {
+			return errorAt(pos, "unknown value %q for has, expected one of %s", v.text, strings.Join(hasValues, ", "))
+		}
+	case FieldViews, FieldDownloads, FieldSize:
+		parse := parseCount
+		if t.Field == FieldSize {
+			parse = parseSize
+		}
+		return t.setNumberRange(op, v.text, pos, parse)
+	case FieldReleased, FieldCreated:
+		return t.setDateRange(op, v.text, pos)
+	}
+	return nil
+}
+
+func (t *Term) setNumberRange(op, text string, pos int, parse func(string) (int64, bool)) error {
+	if low, high, ok := strings.Cut(text, ".."); ok {
+		if op != ":" && op != "=" {
+			return errorAt(pos, "a range cannot be combined with %q", op)
+		}
+		if low == "" && high == "" {
+			return errorAt(pos, "empty range")
+		}
+		if low != "" {
+			n, ok := parse(low)
+			if !ok {
+				return errorAt(pos, "invalid %s %q", t.Field, low)
+			}
+			t.Min = &n
+		}
+		if high != "" {
+			n, ok := parse(high)
+			if !ok {
+				return errorAt(pos+len([]rune(low))+2, "invalid %s %q", t.Field, high)
+			}
+			t.Max = &n
+		}
+		if t.Min != nil && t.Max != nil && *t.Min > *t.Max {
+			return errorAt(pos, "the lower bound is greater than the upper bound")
+		}
+		return nil
+	}
+	n, ok := parse(text)
+	if !ok {
+		return errorAt(pos, "invalid %s %q", t.Field, text)
+	}
+	switch op {
+	case ":", "=":
+		t.Min, t.Max = &n, &n
+	case ">":
+		n++
+		t.Min = &n
+	case ">=":
+		t.Min = &n
+	case "<":
+		n--
+		t.Max = &n
+	case "<=":
+		t.Max = &n
+	}
+	return nil
+}
+
+func (t *Term) setDateRange(op, text string, pos int) error {
+	if low, high, ok := strings.Cut(text, ".."); ok {
+		if op != ":" && op != "=" {
+			return errorAt(pos, "a range cannot be combined with %q", op)
+		}
+		if low == "" && high == "" {
+			return errorAt(pos, "empty range")
+		}
+		if low != "" {
+			start, _, ok := parseDate(low)
+			if !ok {
+				return errorAt(pos, "invalid date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", low)
+			}
+			t.Since = &start
+		}
+		if high != "" {
+			_, end, ok := parseDate(high)
+			if !ok {
+				return errorAt(pos+len([]rune(low))+2, "invalid date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", high)
+			}
+			t.Until = &end
+		}
+		if t.Since != nil && t.Until != nil && !t.Since.Before(*t.Until) {
+			return errorAt(pos, "the start date is after the end date")
+		}
+		return nil
+	}
+	start, end, ok := parseDate(text)
+	if !ok {
+		return errorAt(pos, "invalid date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", text)
+	}
+	switch op {
+	case ":", "=":
+		t.Since, t.Until = &start, &end
+	case ">":
+		t.Since = &end
+	case ">=":
+		t.Since = &start
+	case "<":
+		t.Until = &start
+	case "<=":
+		t.Until = &end
+	}
+	return nil
+}
+
+// parseDate parses a year, month or day and returns the period it covers.
+func parseDate(s string) (start, end time.Time, ok bool) {
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006", 1, 0, 0},
+		{"2006-01", 0, 1, 0},
+		{"2006-01-02", 0, 0, 1},
+	}
+	for _, l := range layouts {
+		if len(s) != len(l.layout) {
+			continue
+		}
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		return t, t.AddDate(l.years, l.months, l.days), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func parseCount(s string) (int64, bool) {
+	var n int64
+	if s == "" || len(s) > 15 {
+		return 0, false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(r-'0')
+	}
+	return n, true
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   float64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a size such as 1GB, 1.5G or 500MB. Units are powers of 1024.
+func parseSize(s string) (int64, bool) {
+	upper := strings.ToUpper(s)
+	unit := 1.0
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, unit = strings.TrimSuffix(upper, u.suffix), u.size
+			break
+		}
+	}
+	if upper == "" || strings.TrimLeft(upper, "0123456789.") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n*unit > 1<<62 {
+		return 0, false
+	}
+	return int64(n * unit), true
+}
@@ -0,0 +1,118 @@
+package searchql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	node, err := Parse(`tag:RPG -tag:NTR released:2020..2023 uploader:alice has:file size>1GB`)
+	assert.NoError(t, err)
+	and, ok := node.(*And)
+	if !assert.True(t, ok) || !assert.Len(t, and.Children, 6) {
+		return
+	}
+	assert.Equal(t, &Term{Field: FieldTag, Text: "RPG", pos: 0}, and.Children[0])
+	not, ok := and.Children[1].(*Not)
+	if assert.True(t, ok) {
+		assert.Equal(t, &Term{Field: FieldTag, Text: "NTR", pos: 9}, not.Child)
+	}
+	released := and.Children[2].(*Term)
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), *released.Since)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *released.Until)
+	assert.Equal(t, "alice", and.Children[3].(*Term).Text)
+	assert.Equal(t, HasFile, and.Children[4].(*Term).Text)
+	size := and.Children[5].(*Term)
+	assert.Equal(t, int64(1<<30+1), *size.Min)
+	assert.Nil(t, size.Max)
+}
+
+func TestParseGroups(t *testing.T) {
+	node, err := Parse(`(tag:"Visual Novel" OR type:Engine) | -(views:>=100 downloads<10) "great adventure" Re:Zero`)
+	assert.NoError(t, err)
+	or, ok := node.(*Or)
+	if !assert.True(t, ok) || !assert.Len(t, or.Children, 2) {
+		return
+	}
+	inner := or.Children[0].(*Or)
+	assert.Equal(t, "Visual Novel", inner.Children[0].(*Term).Text)
+	assert.Equal(t, FieldTagType, inner.Children[1].(*Term).Field)
+
+	and := or.Children[1].(*And)
+	group := and.Children[0].(*Not).Child.(*And)
+	assert.Equal(t, int64(100), *group.Children[0].(*Term).Min)
+	assert.Equal(t, int64(9), *group.Children[1].(*Term).Max)
+	phrase := and.Children[1].(*Term)
+	assert.Equal(t, &Term{Field: FieldText, Text: "great adventure", Phrase: true, pos: 66}, phrase)
+	// Unknown qualifiers are part of the text
+	assert.Equal(t, "Re:Zero", and.Children[2].(*Term).Text)
+
+	assert.False(t, IsPlainText(node))
+	plain, err := Parse("Summer's Pockets")
+	assert.NoError(t, err)
+	assert.True(t, IsPlainText(plain))
+	assert.Equal(t, "Summer's", Terms(plain)[0].Text)
+}
+
+func TestParseDates(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	node, err := Parse("created>=2021-05 released<=2020-02-28 created<2019")
+	assert.NoError(t, err)
+	terms := Terms(node)
+	assert.Equal(t, day(2021, 5, 1), *terms[0].Since)
+	assert.Nil(t, terms[0].Until)
+	assert.Equal(t, day(2020, 2, 29), *terms[1].Until)
+	assert.Equal(t, day(2019, 1, 1), *terms[2].Until)
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		query    string
+		position int
+	}{
+		{"tag:RPG (views>10", 8},
+		{"tag:RPG )", 8},
+		{"a OR", 2},
+		{`title:"unterminated`, 6},
+		{"views>many", 6},
+		{"released:2020-13", 9},
+		{"released:2023..2020", 9},
+		{"size:1GB..2XB", 10},
+		{"has:nothing", 4},
+		{"tag>3", 4},
+		{"uploader:", 9},
+		{"()", 1},
+		{"   ", 0},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.query)
+		var parseErr *ParseError
+		if assert.True(t, errors.As(err, &parseErr), c.query) {
+			assert.Equal(t, c.position, parseErr.Position, c.query)
+		}
+	}
+}
+
+func TestRemoveTerms(t *testing.T) {
+	node, err := Parse("the (a OR tag:x) -the tag:y")
+	assert.NoError(t, err)
+	drop := func(t *Term) bool { return t.Field == FieldText }
+	assert.Equal(t, &Term{Field: FieldTag, Text: "y", pos: 22}, RemoveTerms(node, drop))
+
+	node, err = Parse("the")
+	assert.NoError(t, err)
+	assert.Nil(t, RemoveTerms(node, drop))
+}
+
+func TestNegatedTerms(t *testing.T) {
+	node, err := Parse("a -b (c OR -(d tag:e))")
+	assert.NoError(t, err)
+	texts := make([]string, 0)
+	for _, term := range NegatedTerms(node) {
+		texts = append(texts, term.Text)
+	}
+	assert.Equal(t, []string{"b", "d", "e"}, texts)
+}
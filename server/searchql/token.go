@@ -0,0 +1,124 @@
+package searchql
+
+import "unicode"
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenOr
+	tokenNot
+)
+
+// part is a quoted or unquoted piece of a word.
+type part struct {
+	text   string
+	quoted bool
+}
+
+type token struct {
+	kind tokenKind
+	// text is the token as written in the query.
+	text string
+	pos  int
+	// parts and quoted are only set for words. A word is quoted if it is a single quoted part.
+	parts  []part
+	quoted bool
+}
+
+// word returns the text of a word without quotes.
+func (t *token) word() string {
+	s := ""
+	for _, p := range t.parts {
+		s += p.text
+	}
+	return s
+}
+
+func tokenize(r []rune) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '|':
+			tokens = append(tokens, token{kind: tokenOr, text: "|", pos: i})
+			i++
+		case c == '-' && i+1 < len(r) && !unicode.IsSpace(r[i+1]) && r[i+1] != ')':
+			tokens = append(tokens, token{kind: tokenNot, text: "-", pos: i})
+			i++
+		default:
+			t, next, err := readWord(r, i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			switch {
+			case len(t.parts) == 1 && !t.quoted && t.parts[0].text == "OR":
+				t.kind = tokenOr
+			case len(t.parts) == 1 && !t.quoted && t.parts[0].text == "NOT":
+				t.kind = tokenNot
+			case len(t.parts) == 1 && !t.quoted && t.parts[0].text == "AND":
+				// Terms are combined with AND anyway
+				continue
+			}
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+// readWord reads a word starting at i. Double quotes may appear anywhere in a word, while single
+// quotes only start a quoted part at the beginning of the word or the value, so that apostrophes
+// in titles need no escaping.
+func readWord(r []rune, i int) (token, int, error) {
+	start := i
+	t := token{kind: tokenWord, pos: start}
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			t.parts = append(t.parts, part{text: string(current)})
+			current = nil
+		}
+	}
+	for i < len(r) && !unicode.IsSpace(r[i]) && r[i] != '(' && r[i] != ')' {
+		c := r[i]
+		opensQuote := c == '"' || c == '\'' && (i == start || isOperatorRune(r[i-1]))
+		if !opensQuote {
+			current = append(current, c)
+			i++
+			continue
+		}
+		flush()
+		var quoted []rune
+		j := i + 1
+		for ; j < len(r) && r[j] != c; j++ {
+			if r[j] == '\\' && j+1 < len(r) && r[j+1] == c {
+				j++
+			}
+			quoted = append(quoted, r[j])
+		}
+		if j >= len(r) {
+			return token{}, 0, errorAt(i, "unterminated quote")
+		}
+		t.parts = append(t.parts, part{text: string(quoted), quoted: true})
+		i = j + 1
+	}
+	flush()
+	t.text = string(r[start:i])
+	t.quoted = len(t.parts) == 1 && t.parts[0].quoted
+	return t, i, nil
+}
+
+func isOperatorRune(c rune) bool {
+	return c == ':' || c == '=' || c == '<' || c == '>'
+}
@@ -1,68 +1,30 @@
 package service
 
 import (
+	"nysoure/server/ctx"
 	"nysoure/server/dao"
 	"nysoure/server/model"
 )
 
-func GetActivityList(page int) ([]model.ActivityView, int, error) {
+func GetActivityList(c ctx.Context, page int) ([]model.ActivityView, int, error) {
 	offset := (page - 1) * pageSize
 	limit := pageSize
 
-	activities, total, err := dao.GetActivityList(offset, limit)
+	prefs := contentPreferences(c)
+	activities, total, err := dao.GetActivityList(offset, limit, newContentFilter(prefs))
 	if err != nil {
 		return nil, 0, err
 	}
 
 	var views []model.ActivityView
 	for _, activity := range activities {
-		user, err := dao.GetUserByID(activity.UserID)
+		view, err := newActivityView(activity)
 		if err != nil {
 			return nil, 0, err
 		}
-		var comment *model.CommentView
-		var resource *model.ResourceView
-		var file *model.FileView
-		switch activity.Type {
-		case model.ActivityTypeNewComment:
-			c, err := dao.GetCommentByID(activity.RefID)
-			if err != nil {
-				return nil, 0, err
-			}
-			comment = c.ToView()
-			comment.Content, comment.ContentTruncated = restrictCommentLength(c.Content)
-		case model.ActivityTypeNewResource, model.ActivityTypeUpdateResource:
-			r, err := dao.GetResourceByID(activity.RefID)
-			if err != nil {
-				return nil, 0, err
-			}
-			rv := r.ToView()
-			resource = &rv
-		case model.ActivityTypeNewFile:
-			f, err := dao.GetFileByID(activity.RefID)
-			if err != nil {
-				return nil, 0, err
-			}
-			fv := f.ToView()
-			file = fv
-			r, err := dao.GetResourceByID(f.ResourceID)
-			if err != nil {
-				return nil, 0, err
-			}
-			rv := r.ToView()
-			resource = &rv
-		}
-		view := model.ActivityView{
-			ID:       activity.ID,
-			User:     user.ToView(),
-			Type:     activity.Type,
-			Time:     activity.CreatedAt,
-			Comment:  comment,
-			Resource: resource,
-			File:     file,
-		}
-		views = append(views, view)
+		views = append(views, *view)
 	}
+	blurActivityViews(views, prefs)
 
 	totalPages := (total + pageSize - 1) / pageSize
 
@@ -80,55 +42,94 @@ func GetUserNotifications(userID uint, page int) ([]model.ActivityView, int, err
 
 	var views []model.ActivityView
 	for _, activity := range activities {
-		user, err := dao.GetUserByID(activity.UserID)
+		view, err := newActivityView(activity)
 		if err != nil {
 			return nil, 0, err
 		}
-		var comment *model.CommentView
-		var resource *model.ResourceView
-		var file *model.FileView
-		switch activity.Type {
-		case model.ActivityTypeNewComment:
-			c, err := dao.GetCommentByID(activity.RefID)
-			if err != nil {
-				return nil, 0, err
-			}
-			comment = c.ToView()
-			comment.Content, comment.ContentTruncated = restrictCommentLength(c.Content)
-		case model.ActivityTypeNewResource, model.ActivityTypeUpdateResource:
-			r, err := dao.GetResourceByID(activity.RefID)
-			if err != nil {
-				return nil, 0, err
-			}
-			rv := r.ToView()
-			resource = &rv
-		case model.ActivityTypeNewFile:
-			f, err := dao.GetFileByID(activity.RefID)
-			if err != nil {
-				return nil, 0, err
-			}
-			fv := f.ToView()
-			file = fv
-			r, err := dao.GetResourceByID(f.ResourceID)
-			if err != nil {
-				return nil, 0, err
-			}
-			rv := r.ToView()
-			resource = &rv
-		}
-		view := model.ActivityView{
-			ID:       activity.ID,
-			User:     user.ToView(),
-			Type:     activity.Type,
-			Time:     activity.CreatedAt,
-			Comment:  comment,
-			Resource: resource,
-			File:     file,
-		}
-		views = append(views, view)
+		views = append(views, *view)
 	}
 
 	totalPages := (total + pageSize - 1) / pageSize
 
 	return views, totalPages, nil
 }
+
+// blurActivityViews marks the resources of the activities which have a tag the user wants blurred.
+func blurActivityViews(views []model.ActivityView, prefs model.ContentPreferences) {
+	if len(prefs.BlurredTags) == 0 {
+		return
+	}
+	ids := blurredTagIDs(prefs.BlurredTags)
+	for _, v := range views {
+		if v.Resource != nil {
+			v.Resource.Blurred = hasAnyTag(v.Resource.Tags, ids)
+		}
+	}
+}
+
+func newActivityView(activity model.Activity) (*model.ActivityView, error) {
+	user, err := dao.GetUserByID(activity.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var comment *model.CommentView
+	var resource *model.ResourceView
+	var file *model.FileView
+	var proposal *model.MetadataProposalView
+	var suggestion *model.EditSuggestionView
+	switch activity.Type {
+	case model.ActivityTypeNewComment:
+		c, err := dao.GetCommentByID(activity.RefID)
+		if err != nil {
+			return nil, err
+		}
+		comment = c.ToView()
+		comment.Content, comment.ContentTruncated = restrictCommentLength(c.Content)
+	case model.ActivityTypeNewResource, model.ActivityTypeUpdateResource, model.ActivityTypeSubscriptionResource:
+		r, err := dao.GetResourceByID(activity.RefID)
+		if err != nil {
+			return nil, err
+		}
+		rv := r.ToView()
+		resource = &rv
+	case model.ActivityTypeNewFile, model.ActivityTypeSubscriptionFile:
+		f, err := dao.GetFileByID(activity.RefID)
+		if err != nil {
+			return nil, err
+		}
+		fv := f.ToView()
+		file = fv
+		r, err := dao.GetResourceByID(f.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		rv := r.ToView()
+		resource = &rv
+	case model.ActivityTypeMetadataProposal:
+		p, err := dao.GetMetadataProposalByID(activity.RefID)
+		if err != nil {
+			return nil, err
+		}
+		proposal = p.ToView()
+		resource = &proposal.Resource
+	case model.ActivityTypeEditSuggestion, model.ActivityTypeEditSuggestionAccepted, model.ActivityTypeEditSuggestionRejected:
+		s, err := dao.GetEditSuggestionByID(activity.RefID)
+		if err != nil {
+			return nil, err
+		}
+		suggestion = s.ToView()
+		resource = &suggestion.Resource
+	}
+	view := model.ActivityView{
+		ID:         activity.ID,
+		User:       user.ToView(),
+		Type:       activity.Type,
+		Time:       activity.CreatedAt,
+		Comment:    comment,
+		Resource:   resource,
+		File:       file,
+		Proposal:   proposal,
+		Suggestion: suggestion,
+	}
+	return &view, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/utils"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	maxAPITokensPerUser    = 20
+	maxAPITokenNameLength  = 64
+	maxAPITokenExpiresDays = 365
+	// apiTokenPrefixLength is how much of a token is kept to let the user recognise it.
+	apiTokenPrefixLength = 12
+)
+
+type APITokenParams struct {
+	Name   string             `json:"name"`
+	Scopes []model.TokenScope `json:"scopes"`
+	// ExpiresInDays is 0 for a token which never expires.
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+// CreateAPIToken creates an API token for the user. The token is only returned here,
+// only its hash is stored.
+func CreateAPIToken(c ctx.Context, params APITokenParams) (*model.APITokenWithSecret, error) {
+	if !c.LoggedIn() {
+		return nil, model.NewUnAuthorizedError("You must be logged in to create an API token")
+	}
+	if _, ok := c.SessionID(); !ok {
+		return nil, model.NewUnAuthorizedError("API tokens cannot create other API tokens")
+	}
+	uid := c.MustUserID()
+	nameLen := len([]rune(params.Name))
+	if nameLen == 0 || nameLen > maxAPITokenNameLength {
+		return nil, model.NewRequestError(fmt.Sprintf("Name must be between 1 and %d characters", maxAPITokenNameLength))
+	}
+	scopes, err := normalizeScopes(params.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if params.ExpiresInDays < 0 || params.ExpiresInDays > maxAPITokenExpiresDays {
+		return nil, model.NewRequestError(fmt.Sprintf("Expiry must be between 0 and %d days", maxAPITokenExpiresDays))
+	}
+	count, err := dao.CountUserAPITokens(uid)
+	if err != nil {
+		log.Error("CountUserAPITokens error: ", err)
+		return nil, model.NewInternalServerError("Failed to create API token")
+	}
+	if count >= maxAPITokensPerUser {
+		return nil, model.NewRequestError(fmt.Sprintf("You cannot have more than %d API tokens", maxAPITokensPerUser))
+	}
+	secret, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+	token := model.APIToken{
+		UserID:    uid,
+		Name:      params.Name,
+		TokenHash: hash,
+		Prefix:    secret[:apiTokenPrefixLength],
+		Scopes:    scopes,
+	}
+	if params.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(params.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := dao.CreateAPIToken(&token); err != nil {
+		log.Error("CreateAPIToken error: ", err)
+		return nil, model.NewInternalServerError("Failed to create API token")
+	}
+	return &model.APITokenWithSecret{
+		APITokenView: token.ToView(),
+		Token:        secret,
+	}, nil
+}
+
+func ListAPITokens(c ctx.Context) ([]model.APITokenView, error) {
+	if !c.LoggedIn() {
+		return nil, model.NewUnAuthorizedError("You must be logged in to list your API tokens")
+	}
+	tokens, err := dao.ListUserAPITokens(c.MustUserID())
+	if err != nil {
+		log.Error("ListUserAPITokens error: ", err)
+		return nil, model.NewInternalServerError("Failed to list API tokens")
+	}
+	views := make([]model.APITokenView, 0, len(tokens))
+	for _, t := range tokens {
+		views = append(views, t.ToView())
+	}
+	return views, nil
+}
+
+func RevokeAPIToken(c ctx.Context, id uint) error {
+	if !c.LoggedIn() {
+		return model.NewUnAuthorizedError("You must be logged in to revoke an API token")
+	}
+	return dao.DeleteUserAPIToken(c.MustUserID(), id)
+}
+
+// AuthenticateAPIToken returns the API token of a request and records that it was used.
+func AuthenticateAPIToken(secret string) (*model.APIToken, error) {
+	token, err := dao.GetAPITokenByHash(utils.HashToken(secret))
+	if model.IsNotFoundError(err) {
+		return nil, model.NewUnAuthorizedError("Invalid API token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > sessionTouchInterval {
+		if err := dao.SetAPITokenLastUsed(token.ID); err != nil {
+			log.Error("SetAPITokenLastUsed error: ", err)
+		}
+	}
+	return &token, nil
+}
+
+// requireScope checks that a request made with an API token is allowed to perform an action.
+func requireScope(c ctx.Context, scope model.TokenScope) error {
+	if !c.HasScope(scope) {
+		return model.NewUnAuthorizedError(fmt.Sprintf("The API token does not have the %s scope", scope))
+	}
+	return nil
+}
+
+// normalizeScopes checks the scopes of a new token and removes duplicates.
+// A token without scopes is read-only.
+func normalizeScopes(scopes []model.TokenScope) ([]model.TokenScope, error) {
+	result := make([]model.TokenScope, 0, len(scopes))
+	for _, s := range scopes {
+		if !slices.Contains(model.TokenScopes, s) {
+			return nil, model.NewRequestError(fmt.Sprintf("Unknown scope: %s", s))
+		}
+		if !slices.Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, model.ScopeRead)
+	}
+	if len(result) > 1 {
+		// Read is implied by the other scopes
+		result = slices.DeleteFunc(result, func(s model.TokenScope) bool { return s == model.ScopeRead })
+	}
+	slices.Sort(result)
+	return result, nil
+}
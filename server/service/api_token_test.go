@@ -0,0 +1,32 @@
+package service
+
+import (
+	"nysoure/server/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	scopes, err := normalizeScopes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TokenScope{model.ScopeRead}, scopes)
+
+	scopes, err = normalizeScopes([]model.TokenScope{model.ScopeFileUpload, model.ScopeRead, model.ScopeResourceWrite, model.ScopeFileUpload})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TokenScope{model.ScopeFileUpload, model.ScopeResourceWrite}, scopes)
+
+	_, err = normalizeScopes([]model.TokenScope{"admin"})
+	assert.Error(t, err)
+}
+
+func TestAPITokenHasScope(t *testing.T) {
+	readOnly := model.APIToken{Scopes: []model.TokenScope{model.ScopeRead}}
+	assert.True(t, readOnly.HasScope(model.ScopeRead))
+	assert.False(t, readOnly.HasScope(model.ScopeCommentWrite))
+
+	uploader := model.APIToken{Scopes: []model.TokenScope{model.ScopeFileUpload}}
+	assert.True(t, uploader.HasScope(model.ScopeRead))
+	assert.True(t, uploader.HasScope(model.ScopeFileUpload))
+	assert.False(t, uploader.HasScope(model.ScopeResourceWrite))
+}
@@ -124,7 +124,8 @@ func ListUserCollections(username string, page int, viewerUID uint) ([]*model.Co
 }
 
 // List resources in a collection with pagination.
-func ListCollectionResources(collectionID uint, page int, viewerUID uint) ([]*model.ResourceView, int64, error) {
+func ListCollectionResources(c ctx.Context, collectionID uint, page int) ([]*model.ResourceView, int64, error) {
+	viewerUID := c.MaybeUserID()
 	if collectionID == 0 || page < 1 {
 		return nil, 0, model.NewRequestError("invalid parameters")
 	}
@@ -139,13 +140,19 @@ func ListCollectionResources(collectionID uint, page int, viewerUID uint) ([]*mo
 		return nil, 0, model.NewUnAuthorizedError("you do not have permission to view this private collection")
 	}
 
-	resources, total, err := dao.ListCollectionResources(collectionID, page, pageSize)
+	prefs := contentPreferences(c)
+	resources, total, err := dao.ListCollectionResources(collectionID, page, pageSize, newContentFilter(prefs))
 	if err != nil {
 		return nil, 0, err
 	}
+	var blurred []uint
+	if len(prefs.BlurredTags) > 0 {
+		blurred = blurredTagIDs(prefs.BlurredTags)
+	}
 	var views []*model.ResourceView
 	for _, r := range resources {
 		v := r.ToView()
+		v.Blurred = hasAnyTag(v.Tags, blurred)
 		views = append(views, &v)
 	}
 	return views, total, nil
@@ -23,7 +23,14 @@ type CommentRequest struct {
 	// Images  []uint `json:"images"` // Unrequired after new design
 }
 
-func CreateComment(req CommentRequest, userID uint, refID uint, ip string, cType model.CommentType, host string) (*model.CommentView, error) {
+func CreateComment(ctx ctx.Context, req CommentRequest, refID uint, ip string, cType model.CommentType, host string) (*model.CommentView, error) {
+	userID, ok := ctx.UserID()
+	if !ok {
+		return nil, model.NewUnAuthorizedError("You must be logged in to comment")
+	}
+	if err := requireScope(ctx, model.ScopeCommentWrite); err != nil {
+		return nil, err
+	}
 	if len(req.Content) == 0 {
 		return nil, model.NewRequestError("Content cannot be empty")
 	}
@@ -77,6 +84,9 @@ func CreateComment(req CommentRequest, userID uint, refID uint, ip string, cType
 	if err != nil {
 		log.Error("Error creating comment activity:", err)
 	}
+	if cType == model.CommentTypeResource {
+		go reindexResource(refID)
+	}
 
 	// Asynchronously check if the comment is an ad
 	go func() {
@@ -212,6 +222,9 @@ func ListCommentsWithUser(username string, page int) ([]model.CommentWithResourc
 }
 
 func UpdateComment(commentID uint, c ctx.Context, req CommentRequest, host string) (*model.CommentView, error) {
+	if err := requireScope(c, model.ScopeCommentWrite); err != nil {
+		return nil, err
+	}
 	if len(req.Content) == 0 {
 		return nil, model.NewRequestError("Content cannot be empty")
 	}
@@ -245,10 +258,20 @@ func UpdateComment(commentID uint, c ctx.Context, req CommentRequest, host strin
 	if err != nil {
 		return nil, model.NewInternalServerError("Error updating comment")
 	}
+	if comment.Type == model.CommentTypeResource {
+		go reindexResource(comment.RefID)
+	}
 	return updated.ToView(), nil
 }
 
-func DeleteComment(commentID, userID uint) error {
+func DeleteComment(ctx ctx.Context, commentID uint) error {
+	userID, ok := ctx.UserID()
+	if !ok {
+		return model.NewUnAuthorizedError("You must be logged in to delete comment")
+	}
+	if err := requireScope(ctx, model.ScopeCommentWrite); err != nil {
+		return err
+	}
 	comment, err := dao.GetCommentByID(commentID)
 	if err != nil {
 		return model.NewNotFoundError("Comment not found")
@@ -259,6 +282,9 @@ func DeleteComment(commentID, userID uint) error {
 	if err := dao.DeleteCommentByID(commentID); err != nil {
 		return model.NewInternalServerError("Error deleting comment")
 	}
+	if comment.Type == model.CommentTypeResource {
+		go reindexResource(comment.RefID)
+	}
 	return nil
 }
 
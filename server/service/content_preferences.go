@@ -0,0 +1,188 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"nysoure/server/config"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+// ContentPreferencesCookie holds the content preferences of anonymous users.
+const ContentPreferencesCookie = "content_preferences"
+
+// defaultContentPreferences are the preferences chosen by the admins for the site.
+func defaultContentPreferences() model.ContentPreferences {
+	return model.ContentPreferences{
+		HiddenTags:  config.DefaultHiddenTags(),
+		BlurredTags: config.DefaultBlurredTags(),
+		ShowNsfw:    !config.HideNsfwByDefault(),
+	}
+}
+
+// contentPreferences returns the preferences of the user, loaded with the user of the
+// request for logged in users and read from a cookie for anonymous users, or the defaults
+// of the site.
+func contentPreferences(c ctx.Context) model.ContentPreferences {
+	if uid, ok := c.UserID(); ok {
+		if prefs, loaded := c.ContentPreferences(); loaded {
+			if prefs != nil {
+				return *prefs
+			}
+			return defaultContentPreferences()
+		}
+		// Internal calls have no request
+		user, err := dao.GetUserByID(uid)
+		if err != nil {
+			log.Error("GetUserByID error: ", err)
+		} else if user.ContentPreferences != nil {
+			return *user.ContentPreferences
+		}
+		return defaultContentPreferences()
+	}
+	if fc := c.FiberCtx(); fc != nil {
+		if prefs, ok := DecodeContentPreferences(fc.Cookies(ContentPreferencesCookie)); ok {
+			return prefs
+		}
+	}
+	return defaultContentPreferences()
+}
+
+// newContentFilter returns the filter leaving out the resources the user wants hidden.
+func newContentFilter(prefs model.ContentPreferences) *dao.ContentFilter {
+	if len(prefs.HiddenTags) == 0 {
+		return nil
+	}
+	return &dao.ContentFilter{HiddenTags: prefs.HiddenTags}
+}
+
+// canSeeNsfw reports whether the NSFW images of resources are shown to the user.
+func canSeeNsfw(c ctx.Context, prefs model.ContentPreferences) bool {
+	if !prefs.ShowNsfw || !c.LoggedIn() {
+		return false
+	}
+	return c.UserCreatedAt().Before(time.Now().Add(-72 * time.Hour))
+}
+
+// blurredTagIDs returns the IDs of the tags, their aliases and descendants.
+func blurredTagIDs(tags []uint) []uint {
+	ids, err := dao.GetTagTreeIDs(tags)
+	if err != nil {
+		log.Error("GetTagTreeIDs error: ", err)
+		return nil
+	}
+	return ids
+}
+
+func hasAnyTag(tags []model.TagView, ids []uint) bool {
+	return slices.ContainsFunc(tags, func(t model.TagView) bool {
+		return slices.Contains(ids, t.ID)
+	})
+}
+
+// blurResourceViews marks the resources with a tag the user wants blurred.
+func blurResourceViews(views []model.ResourceView, prefs model.ContentPreferences) {
+	if len(prefs.BlurredTags) == 0 {
+		return
+	}
+	ids := blurredTagIDs(prefs.BlurredTags)
+	for i := range views {
+		views[i].Blurred = hasAnyTag(views[i].Tags, ids)
+	}
+}
+
+// GetContentPreferences returns the preferences in effect for the user.
+func GetContentPreferences(c ctx.Context) model.ContentPreferences {
+	prefs := contentPreferences(c)
+	if prefs.HiddenTags == nil {
+		prefs.HiddenTags = []uint{}
+	}
+	if prefs.BlurredTags == nil {
+		prefs.BlurredTags = []uint{}
+	}
+	return prefs
+}
+
+// SetContentPreferences validates the preferences and saves them for logged in users.
+// Anonymous users keep the returned preferences in a cookie.
+func SetContentPreferences(c ctx.Context, prefs model.ContentPreferences) (*model.ContentPreferences, error) {
+	var err error
+	if prefs.HiddenTags, err = normalizePreferenceTags(prefs.HiddenTags); err != nil {
+		return nil, err
+	}
+	if prefs.BlurredTags, err = normalizePreferenceTags(prefs.BlurredTags); err != nil {
+		return nil, err
+	}
+	if uid, ok := c.UserID(); ok {
+		if err := dao.SetContentPreferences(uid, &prefs); err != nil {
+			log.Error("SetContentPreferences error: ", err)
+			return nil, model.NewInternalServerError("Failed to save content preferences")
+		}
+	}
+	return &prefs, nil
+}
+
+// ResetContentPreferences restores the defaults of the site for a logged in user.
+func ResetContentPreferences(c ctx.Context) error {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil
+	}
+	if err := dao.SetContentPreferences(uid, nil); err != nil {
+		log.Error("SetContentPreferences error: ", err)
+		return model.NewInternalServerError("Failed to reset content preferences")
+	}
+	return nil
+}
+
+// normalizePreferenceTags replaces aliases with their tags and removes duplicates
+// and tags which do not exist.
+func normalizePreferenceTags(ids []uint) ([]uint, error) {
+	if len(ids) > config.MaxPreferenceTags {
+		return nil, model.NewRequestError("Too many tags")
+	}
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		t, err := getRootTag(id)
+		if model.IsNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(result, t.ID) {
+			result = append(result, t.ID)
+		}
+	}
+	return result, nil
+}
+
+// EncodeContentPreferences encodes preferences for the cookie of anonymous users.
+func EncodeContentPreferences(prefs *model.ContentPreferences) string {
+	data, _ := json.Marshal(prefs)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeContentPreferences decodes the cookie of anonymous users.
+func DecodeContentPreferences(value string) (model.ContentPreferences, bool) {
+	var prefs model.ContentPreferences
+	if value == "" {
+		return prefs, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return prefs, false
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return prefs, false
+	}
+	if len(prefs.HiddenTags) > config.MaxPreferenceTags || len(prefs.BlurredTags) > config.MaxPreferenceTags {
+		return prefs, false
+	}
+	return prefs, true
+}
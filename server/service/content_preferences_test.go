@@ -0,0 +1,27 @@
+package service
+
+import (
+	"nysoure/server/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentPreferencesCookie(t *testing.T) {
+	prefs := &model.ContentPreferences{HiddenTags: []uint{3, 5}, BlurredTags: []uint{8}, ShowNsfw: true}
+	decoded, ok := DecodeContentPreferences(EncodeContentPreferences(prefs))
+	assert.True(t, ok)
+	assert.Equal(t, *prefs, decoded)
+
+	_, ok = DecodeContentPreferences("")
+	assert.False(t, ok)
+	_, ok = DecodeContentPreferences("not base64!")
+	assert.False(t, ok)
+}
+
+func TestHasAnyTag(t *testing.T) {
+	tags := []model.TagView{{ID: 1}, {ID: 4}}
+	assert.True(t, hasAnyTag(tags, []uint{4, 9}))
+	assert.False(t, hasAnyTag(tags, []uint{2}))
+	assert.False(t, hasAnyTag(tags, nil))
+}
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/links"
+	"nysoure/server/model"
+	"nysoure/server/search"
+	"nysoure/server/utils"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// duplicateTitleThreshold is the minimum title similarity for a resource to be reported.
+	duplicateTitleThreshold = 0.8
+	// containedTitleScore is the similarity of a title which starts with the other one,
+	// such as a title with a subtitle.
+	containedTitleScore = 0.9
+	// minContainedTitleLength avoids matching short words inside longer titles.
+	minContainedTitleLength = 4
+	maxDuplicateCandidates  = 10
+	duplicateReportLifetime = 10 * time.Minute
+)
+
+const (
+	DuplicateReasonTitle = "title"
+	DuplicateReasonLink  = "link"
+	DuplicateReasonFile  = "file"
+)
+
+type DuplicateCandidate struct {
+	Resource model.ResourceView `json:"resource"`
+	// Score is between 0 and 1. Shared links or files score 1.
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// DuplicateResourceError is returned by CreateResource if similar resources exist
+// and the uploader has not confirmed the creation with the force flag.
+type DuplicateResourceError struct {
+	Candidates []DuplicateCandidate
+}
+
+func (e *DuplicateResourceError) Error() string {
+	return fmt.Sprintf("found %d possible duplicate resources", len(e.Candidates))
+}
+
+type duplicateMatch struct {
+	score   float64
+	reasons []string
+}
+
+func (m *duplicateMatch) add(score float64, reason string) {
+	m.score = max(m.score, score)
+	if !slices.Contains(m.reasons, reason) {
+		m.reasons = append(m.reasons, reason)
+	}
+}
+
+// findDuplicateCandidates finds existing resources which are probably the same as a new one.
+func findDuplicateCandidates(params *ResourceParams) ([]DuplicateCandidate, error) {
+	matches := make(map[uint]*duplicateMatch)
+	add := func(id uint, score float64, reason string) {
+		if matches[id] == nil {
+			matches[id] = &duplicateMatch{}
+		}
+		matches[id].add(score, reason)
+	}
+
+	titles := append([]string{params.Title}, params.AlternativeTitles...)
+	ids := make([]uint, 0)
+	for _, title := range titles {
+		if strings.TrimSpace(title) == "" {
+			continue
+		}
+		res, err := search.SearchResource(title)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, res...)
+	}
+	similar, err := dao.BatchGetResources(utils.RemoveDuplicate(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range similar {
+		score := titleSimilarity(titles, append([]string{r.Title}, r.AlternativeTitles...))
+		if score >= duplicateTitleThreshold {
+			add(r.ID, score, DuplicateReasonTitle)
+		}
+	}
+
+	for _, link := range params.Links {
+		p, id, ok := links.Match(link.URL)
+		if !ok {
+			continue
+		}
+		// IDs made of several parts, like those of itch.io, end with the part in the URL path
+		found, err := dao.FindResourcesWithLinkID(id[strings.LastIndex(id, "/")+1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range found {
+			if slices.Contains(linkKeys(r.Links), p.Name()+":"+id) {
+				add(r.ID, 1, DuplicateReasonLink)
+			}
+		}
+	}
+
+	hashes := make([]string, 0, len(params.FileHashes))
+	for _, h := range params.FileHashes {
+		if h = strings.TrimSpace(h); h != "" {
+			hashes = append(hashes, h)
+		}
+	}
+	sameFiles, err := dao.FindResourceIDsByFileHashes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range sameFiles {
+		add(id, 1, DuplicateReasonFile)
+	}
+
+	ids = make([]uint, 0, len(matches))
+	for id := range matches {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b uint) int {
+		if c := cmp.Compare(matches[b].score, matches[a].score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	if len(ids) > maxDuplicateCandidates {
+		ids = ids[:maxDuplicateCandidates]
+	}
+	resources, err := dao.BatchGetResources(ids)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]DuplicateCandidate, 0, len(resources))
+	for _, r := range resources {
+		m := matches[r.ID]
+		candidates = append(candidates, DuplicateCandidate{
+			Resource: r.ToView(),
+			Score:    m.score,
+			Reasons:  m.reasons,
+		})
+	}
+	return candidates, nil
+}
+
+// linkKeys identifies the entries on external sites which the links point to.
+func linkKeys(resourceLinks []model.Link) []string {
+	keys := make([]string, 0, len(resourceLinks))
+	for _, link := range resourceLinks {
+		if p, id, ok := links.Match(link.URL); ok {
+			keys = append(keys, p.Name()+":"+id)
+		}
+	}
+	return keys
+}
+
+// titleSimilarity returns the highest similarity between any two of the titles.
+func titleSimilarity(a, b []string) float64 {
+	best := 0.0
+	for _, x := range a {
+		for _, y := range b {
+			best = max(best, stringSimilarity(x, y))
+		}
+	}
+	return best
+}
+
+// stringSimilarity compares two titles by the Dice coefficient of their character bigrams,
+// ignoring case, spaces and punctuation. It works for CJK titles without a tokenizer.
+// A title starting with the other one is considered similar.
+func stringSimilarity(a, b string) float64 {
+	x, y := normalizeForSimilarity(a), normalizeForSimilarity(b)
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	if string(x) == string(y) {
+		return 1
+	}
+	if len(x) < 2 || len(y) < 2 {
+		return 0
+	}
+	if min(len(x), len(y)) >= minContainedTitleLength &&
+		(strings.HasPrefix(string(x), string(y)) || strings.HasPrefix(string(y), string(x))) {
+		return containedTitleScore
+	}
+	bigrams := make(map[[2]rune]int, len(x)-1)
+	for i := 0; i+1 < len(x); i++ {
+		bigrams[[2]rune{x[i], x[i+1]}]++
+	}
+	common := 0
+	for i := 0; i+1 < len(y); i++ {
+		bg := [2]rune{y[i], y[i+1]}
+		if bigrams[bg] > 0 {
+			bigrams[bg]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(x)-1+len(y)-1)
+}
+
+func normalizeForSimilarity(s string) []rune {
+	result := make([]rune, 0, len(s))
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+type DuplicatePair struct {
+	Resource  model.ResourceView `json:"resource"`
+	Duplicate model.ResourceView `json:"duplicate"`
+	Score     float64            `json:"score"`
+	Reasons   []string           `json:"reasons"`
+}
+
+type duplicatePairKey struct {
+	a, b uint
+}
+
+type duplicatePairMatch struct {
+	duplicatePairKey
+	duplicateMatch
+}
+
+var (
+	duplicateReport     []duplicatePairMatch
+	duplicateReportTime time.Time
+	duplicateReportMu   sync.Mutex
+)
+
+// GetDuplicateReport lists pairs of existing resources which are probably duplicates.
+// The report is expensive to build, so it is cached for a few minutes.
+func GetDuplicateReport(c ctx.Context, page int) ([]DuplicatePair, int, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, 0, model.NewUnAuthorizedError("Only admin can view the duplicate report")
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	duplicateReportMu.Lock()
+	if duplicateReport == nil || time.Since(duplicateReportTime) > duplicateReportLifetime {
+		report, err := buildDuplicateReport()
+		if err != nil {
+			duplicateReportMu.Unlock()
+			return nil, 0, err
+		}
+		duplicateReport = report
+		duplicateReportTime = time.Now()
+	}
+	report := duplicateReport
+	duplicateReportMu.Unlock()
+
+	totalPages := (len(report) + pageSize - 1) / pageSize
+	start := min((page-1)*pageSize, len(report))
+	end := min(start+pageSize, len(report))
+	report = report[start:end]
+
+	ids := make([]uint, 0, len(report)*2)
+	for _, p := range report {
+		ids = append(ids, p.a, p.b)
+	}
+	resources, err := dao.BatchGetResources(utils.RemoveDuplicate(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+	views := make(map[uint]model.ResourceView, len(resources))
+	for _, r := range resources {
+		views[r.ID] = r.ToView()
+	}
+	pairs := make([]DuplicatePair, 0, len(report))
+	for _, p := range report {
+		a, okA := views[p.a]
+		b, okB := views[p.b]
+		if !okA || !okB {
+			// Deleted since the report was built
+			continue
+		}
+		pairs = append(pairs, DuplicatePair{
+			Resource:  a,
+			Duplicate: b,
+			Score:     p.score,
+			Reasons:   p.reasons,
+		})
+	}
+	return pairs, totalPages, nil
+}
+
+func buildDuplicateReport() ([]duplicatePairMatch, error) {
+	resources, err := dao.ListResourceIdentities()
+	if err != nil {
+		return nil, err
+	}
+	matches := make(map[duplicatePairKey]*duplicateMatch)
+	add := func(a, b uint, score float64, reason string) {
+		if a == b {
+			return
+		}
+		key := duplicatePairKey{min(a, b), max(a, b)}
+		if matches[key] == nil {
+			matches[key] = &duplicateMatch{}
+		}
+		matches[key].add(score, reason)
+	}
+	addGroup := func(ids []uint, reason string) {
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				add(ids[i], ids[j], 1, reason)
+			}
+		}
+	}
+
+	byID := make(map[uint]*model.Resource, len(resources))
+	byLink := make(map[string][]uint)
+	for i := range resources {
+		r := &resources[i]
+		byID[r.ID] = r
+		for _, key := range utils.RemoveDuplicate(linkKeys(r.Links)) {
+			byLink[key] = append(byLink[key], r.ID)
+		}
+	}
+	for _, ids := range byLink {
+		addGroup(ids, DuplicateReasonLink)
+	}
+
+	hashes, err := dao.ListSharedFileHashes()
+	if err != nil {
+		return nil, err
+	}
+	byHash := make(map[string][]uint)
+	for _, h := range hashes {
+		byHash[h.Hash] = append(byHash[h.Hash], h.ResourceID)
+	}
+	for _, ids := range byHash {
+		addGroup(ids, DuplicateReasonFile)
+	}
+
+	for _, r := range resources {
+		titles := append([]string{r.Title}, r.AlternativeTitles...)
+		hits, err := search.SearchResource(r.Title)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range hits {
+			other, ok := byID[id]
+			if !ok || id <= r.ID {
+				continue
+			}
+			score := titleSimilarity(titles, append([]string{other.Title}, other.AlternativeTitles...))
+			if score >= duplicateTitleThreshold {
+				add(r.ID, id, score, DuplicateReasonTitle)
+			}
+		}
+	}
+
+	report := make([]duplicatePairMatch, 0, len(matches))
+	for key, m := range matches {
+		report = append(report, duplicatePairMatch{key, *m})
+	}
+	slices.SortFunc(report, func(x, y duplicatePairMatch) int {
+		if c := cmp.Compare(y.score, x.score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(y.b, x.b); c != 0 {
+			return c
+		}
+		return cmp.Compare(y.a, x.a)
+	})
+	return report, nil
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, stringSimilarity("Ever17 -the out of infinity-", "ever17 the out of infinity"))
+	assert.Equal(t, 1.0, stringSimilarity("サクラノ詩", "サクラノ 詩"))
+	assert.Equal(t, containedTitleScore, stringSimilarity("サクラノ詩 -櫻の森の上を舞う-", "サクラノ詩"))
+	assert.Less(t, stringSimilarity("Forever", "Ever"), duplicateTitleThreshold)
+	assert.Less(t, stringSimilarity("サクラノ詩", "サクラノ刻"), duplicateTitleThreshold)
+	assert.Equal(t, 0.0, stringSimilarity("", "abc"))
+	assert.Equal(t, 0.0, stringSimilarity("a", "b"))
+
+	score := titleSimilarity([]string{"Summer Pockets"}, []string{"サマーポケッツ", "summer pockets"})
+	assert.Equal(t, 1.0, score)
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const maxEditSuggestionNoteLength = 1000
+
+type EditSuggestionParams struct {
+	// Patch holds the fields of ResourceParams to change. Fields which are not present are kept.
+	Patch json.RawMessage `json:"patch"`
+	Note  string          `json:"note"`
+}
+
+type EditSuggestionDetail struct {
+	*model.EditSuggestionView
+	Changes  []RevisionFieldDiff `json:"changes"`
+	Proposed ResourceParams      `json:"proposed"`
+}
+
+// checkSuggestionReviewPermission allows the owner of the resource and admins to review suggestions.
+func checkSuggestionReviewPermission(c ctx.Context, resourceOwner uint) error {
+	uid, ok := c.UserID()
+	if !ok {
+		return model.NewUnAuthorizedError("You must be logged in")
+	}
+	if resourceOwner != uid && c.UserPermission() < model.PermissionAdmin {
+		return model.NewUnAuthorizedError("You have not permission to review suggestions for this resource")
+	}
+	return nil
+}
+
+// applyParamsPatch applies a partial update request to the params of a resource.
+func applyParamsPatch(base *ResourceParams, patch json.RawMessage) (*ResourceParams, error) {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	var result ResourceParams
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &result); err != nil {
+		return nil, model.NewRequestError("Invalid patch")
+	}
+	result.FileHashes = nil
+	result.Force = false
+	return &result, nil
+}
+
+// mergeParamChanges applies the fields changed from base to proposed onto current,
+// so that edits made to the resource after the suggestion are kept.
+func mergeParamChanges(current, base, proposed *ResourceParams) (*ResourceParams, error) {
+	toMap := func(p *ResourceParams) (map[string]json.RawMessage, error) {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]json.RawMessage)
+		return m, json.Unmarshal(data, &m)
+	}
+	c, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toMap(base)
+	if err != nil {
+		return nil, err
+	}
+	p, err := toMap(proposed)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range p {
+		if !bytes.Equal(b[key], value) {
+			c[key] = value
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var result ResourceParams
+	return &result, json.Unmarshal(data, &result)
+}
+
+func decodeEditSuggestion(s *model.EditSuggestion) (base, proposed *ResourceParams, err error) {
+	base, proposed = &ResourceParams{}, &ResourceParams{}
+	if err := json.Unmarshal([]byte(s.Base), base); err != nil {
+		log.Error("Invalid edit suggestion: ", err)
+		return nil, nil, model.NewInternalServerError("Invalid edit suggestion")
+	}
+	if err := json.Unmarshal([]byte(s.Proposed), proposed); err != nil {
+		log.Error("Invalid edit suggestion: ", err)
+		return nil, nil, model.NewInternalServerError("Invalid edit suggestion")
+	}
+	return base, proposed, nil
+}
+
+// SubmitEditSuggestion proposes a change to a resource which the user cannot edit.
+func SubmitEditSuggestion(c ctx.Context, resourceID uint, params *EditSuggestionParams) (uint, error) {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return 0, err
+	}
+	uid, ok := c.UserID()
+	if !ok || c.UserPermission() < model.PermissionVerified {
+		return 0, model.NewUnAuthorizedError("Only verified users can suggest edits")
+	}
+	r, err := dao.GetResourceByID(resourceID)
+	if err != nil {
+		return 0, err
+	}
+	if r.UserID == uid || c.UserPermission() >= model.PermissionUploader {
+		return 0, model.NewRequestError("You can edit this resource directly")
+	}
+	params.Note = strings.TrimSpace(params.Note)
+	if utf8.RuneCountInString(params.Note) > maxEditSuggestionNoteLength {
+		return 0, model.NewRequestError("Note is too long")
+	}
+	if len(params.Patch) == 0 {
+		return 0, model.NewRequestError("Patch is required")
+	}
+	pending, err := dao.HasPendingEditSuggestion(resourceID, uid)
+	if err != nil {
+		return 0, err
+	}
+	if pending {
+		return 0, model.NewRequestError("You already have a pending suggestion for this resource")
+	}
+
+	base := resourceToParams(&r)
+	proposed, err := applyParamsPatch(&base, params.Patch)
+	if err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(proposed.Title) == "" {
+		return 0, model.NewRequestError("Title is required")
+	}
+	if proposed.ReleaseDate != "" {
+		if _, err := time.Parse("2006-01-02", proposed.ReleaseDate); err != nil {
+			return 0, model.NewRequestError("Invalid release date format, expected YYYY-MM-DD")
+		}
+	}
+	if len(diffResourceParams(&base, proposed)) == 0 {
+		return 0, model.NewRequestError("The suggestion does not change anything")
+	}
+
+	baseData, _ := json.Marshal(base)
+	proposedData, _ := json.Marshal(proposed)
+	s := &model.EditSuggestion{
+		ResourceID: resourceID,
+		UserID:     uid,
+		Note:       params.Note,
+		Base:       string(baseData),
+		Proposed:   string(proposedData),
+	}
+	if err := dao.CreateEditSuggestion(s, r.UserID); err != nil {
+		return 0, err
+	}
+	return s.ID, nil
+}
+
+// GetEditSuggestion returns a suggestion with its changes. It is visible to its author and reviewers.
+func GetEditSuggestion(c ctx.Context, id uint) (*EditSuggestionDetail, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil, model.NewUnAuthorizedError("You must be logged in")
+	}
+	s, err := dao.GetEditSuggestionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.UserID != uid {
+		if err := checkSuggestionReviewPermission(c, s.Resource.UserID); err != nil {
+			return nil, err
+		}
+	}
+	base, proposed, err := decodeEditSuggestion(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EditSuggestionDetail{
+		EditSuggestionView: s.ToView(),
+		Changes:            diffResourceParams(base, proposed),
+		Proposed:           *proposed,
+	}, nil
+}
+
+// ListEditSuggestions lists the pending suggestions the user can review.
+// If resourceID is not zero, only suggestions for the resource are listed.
+func ListEditSuggestions(c ctx.Context, resourceID uint, page int) ([]model.EditSuggestionView, int, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil, 0, model.NewUnAuthorizedError("You must be logged in")
+	}
+	ownerID := uid
+	if c.UserPermission() == model.PermissionAdmin {
+		ownerID = 0
+	}
+	if page < 1 {
+		page = 1
+	}
+	suggestions, totalPages, err := dao.ListEditSuggestions(resourceID, ownerID, model.EditSuggestionPending, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	views := make([]model.EditSuggestionView, 0, len(suggestions))
+	for _, s := range suggestions {
+		views = append(views, *s.ToView())
+	}
+	return views, totalPages, nil
+}
+
+// AcceptEditSuggestion applies a pending suggestion through UpdateResource.
+func AcceptEditSuggestion(c ctx.Context, id uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	s, err := dao.GetEditSuggestionByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkSuggestionReviewPermission(c, s.Resource.UserID); err != nil {
+		return err
+	}
+	if s.Status != model.EditSuggestionPending {
+		return model.NewRequestError("Suggestion is not pending")
+	}
+	base, proposed, err := decodeEditSuggestion(s)
+	if err != nil {
+		return err
+	}
+	r, err := dao.GetResourceByID(s.ResourceID)
+	if err != nil {
+		return err
+	}
+	current := resourceToParams(&r)
+	params, err := mergeParamChanges(&current, base, proposed)
+	if err != nil {
+		log.Error("Failed to merge edit suggestion: ", err)
+		return model.NewInternalServerError("Failed to apply suggestion")
+	}
+	if err := UpdateResource(c, s.ResourceID, params); err != nil {
+		return err
+	}
+	return dao.ReviewEditSuggestion(s, c.MustUserID(), model.EditSuggestionAccepted, "")
+}
+
+// RejectEditSuggestion discards a pending suggestion and tells its author why.
+func RejectEditSuggestion(c ctx.Context, id uint, reason string) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	s, err := dao.GetEditSuggestionByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkSuggestionReviewPermission(c, s.Resource.UserID); err != nil {
+		return err
+	}
+	if s.Status != model.EditSuggestionPending {
+		return model.NewRequestError("Suggestion is not pending")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return model.NewRequestError("Reason is required")
+	}
+	if utf8.RuneCountInString(reason) > maxEditSuggestionNoteLength {
+		return model.NewRequestError("Reason is too long")
+	}
+	return dao.ReviewEditSuggestion(s, c.MustUserID(), model.EditSuggestionRejected, reason)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditSuggestionPatch(t *testing.T) {
+	base := &ResourceParams{
+		Title:       "Ever17",
+		ReleaseDate: "2002-08-28",
+		Tags:        []uint{1, 2},
+		Article:     "old article",
+	}
+	proposed, err := applyParamsPatch(base, json.RawMessage(`{"release_date":"2002-08-29","tags":[1,2,3],"force":true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Ever17", proposed.Title)
+	assert.Equal(t, "2002-08-29", proposed.ReleaseDate)
+	assert.Equal(t, []uint{1, 2, 3}, proposed.Tags)
+	assert.False(t, proposed.Force)
+	// The base is not modified
+	assert.Equal(t, []uint{1, 2}, base.Tags)
+
+	_, err = applyParamsPatch(base, json.RawMessage(`{"tags":"x"}`))
+	assert.Error(t, err)
+
+	// The owner changed the article after the suggestion was made
+	current := *base
+	current.Article = "new article"
+	merged, err := mergeParamChanges(&current, base, proposed)
+	assert.NoError(t, err)
+	assert.Equal(t, "new article", merged.Article)
+	assert.Equal(t, "2002-08-29", merged.ReleaseDate)
+	assert.Equal(t, []uint{1, 2, 3}, merged.Tags)
+}
@@ -82,6 +82,9 @@ func init() {
 }
 
 func CreateUploadingFile(c ctx2.Context, filename string, description string, fileSize int64, resourceID, storageID uint, tag string) (*model.UploadingFileView, error) {
+	if err := requireScope(c, model.ScopeFileUpload); err != nil {
+		return nil, err
+	}
 	if filename == "" {
 		return nil, model.NewRequestError("filename is empty")
 	}
@@ -257,22 +260,25 @@ func FinishUploadingFile(uid uint, fid uint, md5Str string) (*model.FileView, er
 		err := dao.AddStorageUsage(uploadingFile.TargetStorageID, uploadingFile.TotalSize)
 		if err != nil {
 			log.Error("failed to add storage usage: ", err)
-			_ = dao.DeleteFile(dbFile.UUID)
+			_ = dao.PurgeFile(dbFile.UUID)
 			return
 		}
 		storageKey, err := iStorage.Upload(resultFilePath, uploadingFile.Filename)
 		if err != nil {
 			_ = dao.AddStorageUsage(uploadingFile.TargetStorageID, -uploadingFile.TotalSize)
 			log.Error("failed to upload file to storage: ", err)
-			_ = dao.DeleteFile(dbFile.UUID)
+			_ = dao.PurgeFile(dbFile.UUID)
 		} else {
 			err = dao.SetFileStorageKey(dbFile.UUID, storageKey)
 			if err != nil {
 				_ = dao.AddStorageUsage(uploadingFile.TargetStorageID, -uploadingFile.TotalSize)
 				_ = iStorage.Delete(storageKey)
-				_ = dao.DeleteFile(dbFile.UUID)
+				_ = dao.PurgeFile(dbFile.UUID)
 				log.Error("failed to set file storage key: ", err)
+				return
 			}
+			reindexResource(uploadingFile.TargetResourceID)
+			notifySubscribers(uid, uploadingFile.TargetResourceID, dbFile.ID)
 		}
 	}()
 
@@ -308,6 +314,9 @@ func CancelUploadingFile(uid uint, fid uint) error {
 }
 
 func CreateRedirectFile(c ctx2.Context, filename string, description string, resourceID uint, redirectUrl string, fileSize int64, md5 string, tag string) (*model.FileView, error) {
+	if err := requireScope(c, model.ScopeFileUpload); err != nil {
+		return nil, err
+	}
 	u, err := url.Parse(redirectUrl)
 	if err != nil {
 		return nil, model.NewRequestError("URL is not valid")
@@ -329,10 +338,15 @@ func CreateRedirectFile(c ctx2.Context, filename string, description string, res
 		log.Error("failed to create file in db: ", err)
 		return nil, model.NewInternalServerError("failed to create file in db")
 	}
+	reindexResource(resourceID)
+	go notifySubscribers(uid, resourceID, file.ID)
 	return file.ToView(), nil
 }
 
 func DeleteFile(c ctx2.Context, fid string) error {
+	if err := requireScope(c, model.ScopeFileUpload); err != nil {
+		return err
+	}
 	file, err := dao.GetFile(fid)
 	if err != nil {
 		log.Error("failed to get file: ", err)
@@ -346,26 +360,28 @@ func DeleteFile(c ctx2.Context, fid string) error {
 		return model.NewUnAuthorizedError("user cannot delete file")
 	}
 
-	if file.StorageID != nil {
-		iStorage := storage.NewStorage(file.Storage)
-		if iStorage != nil {
-			if err := iStorage.Delete(file.StorageKey); err != nil {
-				log.Error("failed to delete file from storage: ", err)
-				return model.NewInternalServerError("failed to delete file from storage")
-			}
-			_ = dao.AddStorageUsage(*file.StorageID, -file.Size)
+	// A file which is still being uploaded has nothing to keep in the trash
+	if file.StorageID != nil && file.StorageKey == storageKeyUnavailable {
+		if err := dao.PurgeFile(fid); err != nil {
+			log.Error("failed to delete file from db: ", err)
+			return model.NewInternalServerError("failed to delete file from db")
 		}
+		return nil
 	}
 
 	if err := dao.DeleteFile(fid); err != nil {
 		log.Error("failed to delete file from db: ", err)
 		return model.NewInternalServerError("failed to delete file from db")
 	}
+	reindexResource(file.ResourceID)
 
 	return nil
 }
 
 func UpdateFile(c ctx2.Context, fid string, filename string, description string, tag string, size int64) (*model.FileView, error) {
+	if err := requireScope(c, model.ScopeFileUpload); err != nil {
+		return nil, err
+	}
 	file, err := dao.GetFile(fid)
 	if err != nil {
 		log.Error("failed to get file: ", err)
@@ -384,6 +400,7 @@ func UpdateFile(c ctx2.Context, fid string, filename string, description string,
 		log.Error("failed to update file in db: ", err)
 		return nil, model.NewInternalServerError("failed to update file in db")
 	}
+	reindexResource(file.ResourceID)
 	return file.ToView(), nil
 }
 
@@ -560,6 +577,9 @@ func downloadFile(ctx context.Context, url string, path string) (string, error)
 }
 
 func CreateServerDownloadTask(c ctx2.Context, url, filename, description string, resourceID, storageID uint, tag string) (*model.FileView, error) {
+	if err := requireScope(c, model.ScopeFileUpload); err != nil {
+		return nil, err
+	}
 	if c.UserPermission() < model.PermissionUploader {
 		return nil, model.NewUnAuthorizedError("user cannot upload file")
 	}
@@ -616,7 +636,7 @@ func CreateServerDownloadTask(c ctx2.Context, url, filename, description string,
 		tempDir := filepath.Join(utils.GetStoragePath(), "temp")
 		if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
 			log.Error("failed to create temp dir: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			return
 		}
 
@@ -637,7 +657,7 @@ func CreateServerDownloadTask(c ctx2.Context, url, filename, description string,
 			if err != nil {
 				log.Error("failed to download file: ", err)
 				if i == 2 {
-					_ = dao.DeleteFile(file.UUID)
+					_ = dao.PurgeFile(file.UUID)
 					log.Error("Failed to download file after retries, deleting file record: ", file.UUID)
 					return
 				}
@@ -657,58 +677,60 @@ func CreateServerDownloadTask(c ctx2.Context, url, filename, description string,
 		stat, err := os.Stat(tempPath)
 		if err != nil {
 			log.Error("failed to get temp file info: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = os.Remove(tempPath)
 			return
 		}
 		size := stat.Size()
 		if size == 0 {
 			log.Error("downloaded file is empty")
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = os.Remove(tempPath)
 			return
 		}
 		if size != contentLength {
 			log.Error("downloaded file size does not match expected size: ", size, " != ", contentLength)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = os.Remove(tempPath)
 			return
 		}
 		s, err := dao.GetStorage(storageID)
 		if err != nil {
 			log.Error("failed to get storage: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = os.Remove(tempPath)
 			return
 		}
 		iStorage := storage.NewStorage(s)
 		if iStorage == nil {
 			log.Error("failed to find storage: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = os.Remove(tempPath)
 			return
 		}
 		storageKey, err := iStorage.Upload(tempPath, filename)
 		if err != nil {
 			log.Error("failed to upload file to storage: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = os.Remove(tempPath)
 			return
 		}
 		if err := dao.SetFileStorageKeyAndSize(file.UUID, storageKey, size, hash); err != nil {
 			log.Error("failed to set file storage key: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = iStorage.Delete(storageKey)
 			_ = os.Remove(tempPath)
 			return
 		}
 		if err := dao.AddStorageUsage(storageID, size); err != nil {
 			log.Error("failed to add storage usage: ", err)
-			_ = dao.DeleteFile(file.UUID)
+			_ = dao.PurgeFile(file.UUID)
 			_ = iStorage.Delete(storageKey)
 			_ = os.Remove(tempPath)
 			return
 		}
+		reindexResource(resourceID)
+		notifySubscribers(uid, resourceID, file.ID)
 	}()
 
 	return file.ToView(), nil
@@ -128,6 +128,9 @@ func init() {
 }
 
 func CreateImage(c ctx.Context, ip string, data []byte) (uint, error) {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return 0, err
+	}
 	canUpload := c.UserPermission() >= model.PermissionUploader
 
 	if len(data) == 0 {
@@ -217,6 +220,9 @@ func GetImage(id uint) ([]byte, error) {
 }
 
 func DeleteImage(c ctx.Context, id uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
 	if c.UserPermission() < model.PermissionUploader {
 		return model.NewUnAuthorizedError("User cannot upload images")
 	}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/search"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/log"
+)
+
+// MergeResources merges the source resource into the target. Files, comments, images,
+// characters, tags, collections and counters of the source are moved to the target,
+// and the ID of the source redirects to the target afterwards.
+func MergeResources(c ctx.Context, sourceID, targetID uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	uid := c.MustUserID()
+	if c.UserPermission() < model.PermissionAdmin {
+		return model.NewUnAuthorizedError("Only admin can merge resources")
+	}
+	if sourceID == targetID {
+		return model.NewRequestError("Cannot merge a resource into itself")
+	}
+	if err := dao.MergeResources(sourceID, targetID); err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return err
+		}
+		log.Error("MergeResources error: ", err)
+		return model.NewInternalServerError("Failed to merge resources")
+	}
+
+	if err := search.RemoveResourceFromIndex(sourceID); err != nil {
+		log.Error("RemoveResourceFromIndex error: ", err)
+	}
+	reindexResource(targetID)
+	if err := updateCachedTagList(); err != nil {
+		log.Error("Error updating cached tag list:", err)
+	}
+	if err := dao.AddUpdateResourceActivity(uid, targetID); err != nil {
+		log.Error("AddUpdateResourceActivity error: ", err)
+	}
+	return nil
+}
+
+// getResourceFollowingRedirect returns the resource with the given ID,
+// or the resource it was merged into.
+func getResourceFollowingRedirect(id uint) (model.Resource, error) {
+	r, err := dao.GetResourceByID(id)
+	if err == nil {
+		return r, nil
+	}
+	if !model.IsNotFoundError(err) {
+		return r, err
+	}
+	targetID, redirectErr := dao.GetResourceRedirect(id)
+	if redirectErr != nil {
+		return r, err
+	}
+	return dao.GetResourceByID(targetID)
+}
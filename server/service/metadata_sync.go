@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/links"
+	"nysoure/server/model"
+	"nysoure/server/search"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	// metadataSyncInterval is how often the metadata of a resource is refreshed.
+	metadataSyncInterval  = 24 * time.Hour
+	metadataSyncBatchSize = 50
+	// metadataSyncDelay is the pause between two resources. Ratings are rate
+	// limited by the link providers; this also covers the VNDB metadata requests.
+	metadataSyncDelay = 5 * time.Second
+)
+
+func init() {
+	// Start a goroutine to sync metadata from linked sites
+	go func() {
+		// Wait for 1 minute to ensure the database is ready
+		time.Sleep(time.Minute)
+		for {
+			n, err := syncMetadataBatch()
+			if err != nil {
+				log.Errorf("Failed to sync resource metadata: %v", err)
+			}
+			if n < metadataSyncBatchSize {
+				time.Sleep(time.Hour)
+			}
+		}
+	}()
+}
+
+// syncMetadataBatch syncs a batch of outdated resources and returns the number of resources processed.
+func syncMetadataBatch() (int, error) {
+	resources, err := dao.ListResourcesForMetadataSync(time.Now().Add(-metadataSyncInterval), metadataSyncBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range resources {
+		if err := syncResourceMetadata(r); err != nil {
+			log.Errorf("Failed to sync metadata of resource %d: %v", r.ID, err)
+		}
+		time.Sleep(metadataSyncDelay)
+	}
+	return len(resources), nil
+}
+
+// scheduleMetadataSync syncs a resource in the background, so that ratings
+// are available soon after the resource is created or its links are changed.
+func scheduleMetadataSync(resourceID uint) {
+	go func() {
+		r, err := dao.GetResourceByID(resourceID)
+		if err != nil {
+			log.Error("Failed to get resource for metadata sync: ", err)
+			return
+		}
+		if err := syncResourceMetadata(r); err != nil {
+			log.Errorf("Failed to sync metadata of resource %d: %v", r.ID, err)
+		}
+	}()
+}
+
+func hasSyncableLinks(resourceLinks []model.Link) bool {
+	for _, link := range resourceLinks {
+		if _, _, ok := links.Match(link.URL); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func vndbIDFromLink(u string) (string, bool) {
+	vnID, ok := strings.CutPrefix(strings.TrimSpace(u), "https://vndb.org/v")
+	if !ok {
+		return "", false
+	}
+	id, err := normalizeVndbID(strings.TrimSuffix(vnID, "/"))
+	if err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// syncResourceMetadata refreshes the ratings of a resource and proposes other changes
+// found on VNDB to the owner. Data entered by the uploader is never overwritten here.
+func syncResourceMetadata(r model.Resource) error {
+	ratings := make([]model.ExternalRating, 0)
+	var proposal *model.MetadataProposal
+	for _, link := range r.Links {
+		p, id, ok := links.Match(link.URL)
+		if !ok {
+			continue
+		}
+		label := link.Label
+		if label == "" {
+			label = p.Label()
+		}
+		rating, err := links.GetRating(context.Background(), p, id)
+		if err != nil {
+			if !errors.Is(err, links.ErrBackoff) {
+				log.Errorf("Failed to get %s rating: %v", p.Name(), err)
+			}
+			// Keep the previous rating until the site is available again
+			for _, old := range r.RatingDetails {
+				if old.URL == link.URL {
+					ratings = append(ratings, old)
+				}
+			}
+		} else if rating.Votes > 0 {
+			ratings = append(ratings, model.ExternalRating{
+				Provider: p.Name(),
+				Label:    label,
+				Icon:     p.Icon(),
+				URL:      link.URL,
+				Score:    rating.Score,
+				Votes:    rating.Votes,
+			})
+		}
+
+		if vnID, ok := vndbIDFromLink(link.URL); ok && proposal == nil {
+			vn, err := fetchVndbVisualNovel(vnID, vndbSyncFields)
+			if err != nil {
+				log.Error("Failed to get VNDB metadata: ", err)
+				continue
+			}
+			proposal, err = buildVndbProposal(&r, vnID, vn)
+			if err != nil {
+				log.Error("Failed to build metadata proposal: ", err)
+			}
+		}
+	}
+
+	if err := dao.UpdateResourceRatings(r.ID, ratings); err != nil {
+		return err
+	}
+	if proposal == nil || proposal.IsEmpty() {
+		return nil
+	}
+	proposal.Digest = proposalDigest(proposal)
+	exists, err := dao.ExistsMetadataProposal(r.ID, proposal.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return dao.CreateMetadataProposal(proposal, r.UserID)
+}
+
+const vndbSyncFields = "released, title, alttitle, aliases, titles.title, titles.official"
+
+// buildVndbProposal compares the resource with the VNDB entry and collects missing data.
+func buildVndbProposal(r *model.Resource, vnID string, vn *vndbVisualNovel) (*model.MetadataProposal, error) {
+	p := &model.MetadataProposal{
+		ResourceID:        r.ID,
+		AlternativeTitles: []string{},
+		Characters:        []model.ExternalCharacter{},
+	}
+
+	if released, err := time.Parse("2006-01-02", vn.Released); err == nil {
+		if r.ReleaseDate == nil || !sameDay(*r.ReleaseDate, released) {
+			p.OldReleaseDate = r.ReleaseDate
+			p.ReleaseDate = &released
+		}
+	}
+
+	known := make(map[string]bool, len(r.AlternativeTitles)+1)
+	known[normalizeTitle(r.Title)] = true
+	for _, t := range r.AlternativeTitles {
+		known[normalizeTitle(t)] = true
+	}
+	title, alternatives := vndbTitles(vn)
+	for _, t := range append([]string{title}, alternatives...) {
+		if !known[normalizeTitle(t)] {
+			known[normalizeTitle(t)] = true
+			p.AlternativeTitles = append(p.AlternativeTitles, t)
+		}
+	}
+
+	characters, err := fetchVndbCharacterList(vnID)
+	if err != nil {
+		return p, err
+	}
+	existing := make(map[string]bool, len(r.Characters))
+	for _, c := range r.Characters {
+		existing[normalizeTitle(c.Name)] = true
+	}
+	for _, c := range characters {
+		if !existing[normalizeTitle(c.Name)] {
+			p.Characters = append(p.Characters, c)
+		}
+	}
+	return p, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func normalizeTitle(s string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+}
+
+func proposalDigest(p *model.MetadataProposal) string {
+	var releaseDate string
+	if p.ReleaseDate != nil {
+		releaseDate = p.ReleaseDate.Format("2006-01-02")
+	}
+	characters := make([]string, 0, len(p.Characters))
+	for _, c := range p.Characters {
+		characters = append(characters, c.Name)
+	}
+	data, _ := json.Marshal([]any{releaseDate, p.AlternativeTitles, characters})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkResourceEditPermission mirrors the permission check of UpdateResource.
+func checkResourceEditPermission(c ctx.Context, resourceOwner uint) error {
+	uid, ok := c.UserID()
+	if !ok {
+		return model.NewUnAuthorizedError("You must be logged in")
+	}
+	if resourceOwner != uid && c.UserPermission() < model.PermissionUploader {
+		return model.NewUnAuthorizedError("You have not permission to edit this resource")
+	}
+	return nil
+}
+
+// GetPendingMetadataProposal returns the pending proposal of a resource, or nil if there is none.
+func GetPendingMetadataProposal(c ctx.Context, resourceID uint) (*model.MetadataProposalView, error) {
+	owner, err := dao.GetResourceOwnerID(resourceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResourceEditPermission(c, owner); err != nil {
+		return nil, err
+	}
+	p, err := dao.GetPendingMetadataProposal(resourceID)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return p.ToView(), nil
+}
+
+// ListMetadataProposals lists pending proposals for resources of the current user.
+func ListMetadataProposals(c ctx.Context, page int) ([]model.MetadataProposalView, int, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil, 0, model.NewUnAuthorizedError("You must be logged in")
+	}
+	if page < 1 {
+		page = 1
+	}
+	proposals, totalPages, err := dao.ListPendingMetadataProposals(uid, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	views := make([]model.MetadataProposalView, 0, len(proposals))
+	for _, p := range proposals {
+		views = append(views, *p.ToView())
+	}
+	return views, totalPages, nil
+}
+
+// AcceptMetadataProposal applies a pending proposal to its resource.
+func AcceptMetadataProposal(c ctx.Context, id uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	p, err := dao.GetMetadataProposalByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkResourceEditPermission(c, p.Resource.UserID); err != nil {
+		return err
+	}
+	if p.Status != model.MetadataProposalPending {
+		return model.NewRequestError("Proposal is not pending")
+	}
+	characters := make([]model.Character, 0, len(p.Characters))
+	for _, ch := range p.Characters {
+		character := model.Character{
+			Name:  ch.Name,
+			Alias: ch.Alias,
+			CV:    ch.CV,
+			Role:  ch.Role,
+		}
+		if ch.ImageURL != "" {
+			imageID, err := downloadAndCreateImage(ch.ImageURL)
+			if err != nil {
+				log.Error("Failed to download character image:", err)
+			} else {
+				character.ImageID = &imageID
+			}
+		}
+		characters = append(characters, character)
+	}
+	old, err := dao.GetResourceByID(p.ResourceID)
+	if err != nil {
+		return err
+	}
+	if err := dao.AcceptMetadataProposal(p, characters); err != nil {
+		return err
+	}
+	r, err := dao.GetResourceByID(p.ResourceID)
+	if err != nil {
+		return err
+	}
+	saveResourceRevision(newResourceRevision(c.MustUserID(), &r), newBaselineRevision(&old))
+	if err := search.AddResourceToIndex(r); err != nil {
+		log.Error("AddResourceToIndex error: ", err)
+	}
+	return nil
+}
+
+// RejectMetadataProposal discards a pending proposal. The same changes will not be proposed again.
+func RejectMetadataProposal(c ctx.Context, id uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	p, err := dao.GetMetadataProposalByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkResourceEditPermission(c, p.Resource.UserID); err != nil {
+		return err
+	}
+	if p.Status != model.MetadataProposalPending {
+		return model.NewRequestError("Proposal is not pending")
+	}
+	return dao.RejectMetadataProposal(id)
+}
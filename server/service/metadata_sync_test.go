@@ -0,0 +1,67 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nysoure/server/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildVndbProposal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(vndbStubCharacters))
+	}))
+	defer server.Close()
+	oldUrl := vndbApiUrl
+	vndbApiUrl = server.URL
+	defer func() { vndbApiUrl = oldUrl }()
+
+	releaseDate := time.Date(2002, 8, 28, 0, 0, 0, 0, time.UTC)
+	r := &model.Resource{
+		Title:             "Ever17",
+		AlternativeTitles: []string{"E17"},
+		ReleaseDate:       &releaseDate,
+	}
+	vn := &vndbVisualNovel{
+		Released: "2002-08-29",
+		Title:    "Ever17 -the out of infinity-",
+		Aliases:  []string{"e17", "Ever 17"},
+	}
+
+	p, err := buildVndbProposal(r, "v17", vn)
+	assert.NoError(t, err)
+	if assert.NotNil(t, p.ReleaseDate) {
+		assert.Equal(t, "2002-08-29", p.ReleaseDate.Format("2006-01-02"))
+	}
+	assert.Equal(t, &releaseDate, p.OldReleaseDate)
+	// Titles already present are not proposed, ignoring case and spaces
+	assert.Equal(t, []string{"Ever17 -the out of infinity-"}, p.AlternativeTitles)
+	if assert.Len(t, p.Characters, 1) {
+		assert.Equal(t, "小町つぐみ", p.Characters[0].Name)
+		assert.Equal(t, "https://t.vndb.org/ch/1.jpg", p.Characters[0].ImageURL)
+	}
+
+	// Nothing is proposed once the resource is up to date
+	r.ReleaseDate = p.ReleaseDate
+	r.AlternativeTitles = append(r.AlternativeTitles, p.AlternativeTitles...)
+	r.Characters = []model.Character{{Name: "小町 つぐみ"}}
+	p2, err := buildVndbProposal(r, "v17", vn)
+	assert.NoError(t, err)
+	assert.True(t, p2.IsEmpty())
+
+	assert.NotEqual(t, proposalDigest(p), proposalDigest(p2))
+}
+
+func TestLinkIDs(t *testing.T) {
+	id, ok := vndbIDFromLink("https://vndb.org/v17/")
+	assert.True(t, ok)
+	assert.Equal(t, "v17", id)
+	_, ok = vndbIDFromLink("https://vndb.org/c17")
+	assert.False(t, ok)
+
+	assert.True(t, hasSyncableLinks([]model.Link{{URL: "https://store.steampowered.com/app/123/Game_Name/"}}))
+	assert.False(t, hasSyncableLinks([]model.Link{{URL: "https://example.com/game"}}))
+}
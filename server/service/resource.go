@@ -1,20 +1,19 @@
 package service
 
 import (
+	"cmp"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
-	"nysoure/server/cache"
 	"nysoure/server/config"
 	"nysoure/server/ctx"
 	"nysoure/server/dao"
 	"nysoure/server/model"
 	"nysoure/server/search"
+	"nysoure/server/searchql"
 	"nysoure/server/utils"
 	"slices"
 	"strconv"
@@ -28,8 +27,12 @@ import (
 
 const (
 	maxSearchQueryLength = 100
+	maxSearchFacetValues = 20
 )
 
+// vndbApiUrl is the base URL of the VNDB Kana API. Tests replace it with a stub server.
+var vndbApiUrl = "https://api.vndb.org/kana"
+
 type ResourceParams struct {
 	Title             string            `json:"title" binding:"required"`
 	AlternativeTitles []string          `json:"alternative_titles"`
@@ -42,6 +45,11 @@ type ResourceParams struct {
 	Gallery           []uint            `json:"gallery"`
 	GalleryNsfw       []uint            `json:"gallery_nsfw"`
 	Characters        []CharacterParams `json:"characters"`
+	// FileHashes are hashes of the files the uploader is going to add,
+	// used to detect duplicates when creating a resource.
+	FileHashes []string `json:"file_hashes,omitempty"`
+	// Force creates the resource even if possible duplicates are found.
+	Force bool `json:"force,omitempty"`
 }
 
 type CharacterParams struct {
@@ -53,6 +61,9 @@ type CharacterParams struct {
 }
 
 func CreateResource(c ctx.Context, params *ResourceParams) (uint, error) {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return 0, err
+	}
 	if c.UserPermission() < model.PermissionUploader {
 		return 0, model.NewUnAuthorizedError("You have not permission to upload resources")
 	}
@@ -66,8 +77,13 @@ func CreateResource(c ctx.Context, params *ResourceParams) (uint, error) {
 			},
 		}
 	}
-	tags := make([]model.Tag, len(params.Tags))
-	for i, id := range params.Tags {
+	tagIDs, err := withImpliedTags(params.Tags)
+	if err != nil {
+		log.Error("withImpliedTags error: ", err)
+		return 0, model.NewInternalServerError("Failed to apply implied tags")
+	}
+	tags := make([]model.Tag, len(tagIDs))
+	for i, id := range tagIDs {
 		tags[i] = model.Tag{
 			Model: gorm.Model{
 				ID: id,
@@ -134,7 +150,15 @@ func CreateResource(c ctx.Context, params *ResourceParams) (uint, error) {
 		GalleryNsfw:       nsfw,
 		Characters:        characters,
 	}
-	var err error
+	if !params.Force {
+		candidates, err := findDuplicateCandidates(params)
+		if err != nil {
+			return 0, err
+		}
+		if len(candidates) > 0 {
+			return 0, &DuplicateResourceError{Candidates: candidates}
+		}
+	}
 	if r, err = dao.CreateResource(r); err != nil {
 		return 0, err
 	}
@@ -149,6 +173,11 @@ func CreateResource(c ctx.Context, params *ResourceParams) (uint, error) {
 	if err := search.AddResourceToIndex(r); err != nil {
 		log.Error("AddResourceToIndex error: ", err)
 	}
+	go notifySubscribers(uid, r.ID, 0)
+	saveResourceRevision(newResourceRevision(uid, &r), nil)
+	if hasSyncableLinks(r.Links) {
+		scheduleMetadataSync(r.ID)
+	}
 	return r.ID, nil
 }
 
@@ -196,7 +225,7 @@ func parseResourceIfPresent(line string, host string) *model.ResourceView {
 	if err != nil {
 		return nil
 	}
-	r, err := dao.GetResourceByID(uint(id))
+	r, err := getResourceFollowingRedirect(uint(id))
 	if err != nil {
 		return nil
 	}
@@ -205,39 +234,39 @@ func parseResourceIfPresent(line string, host string) *model.ResourceView {
 }
 
 func GetResource(id uint, c ctx.Context) (*model.ResourceDetailView, error) {
-	r, err := dao.GetResourceByID(id)
+	r, err := getResourceFollowingRedirect(id)
 	if err != nil {
 		return nil, err
 	}
 	if c.IsRealUser() {
-		err = dao.AddResourceViewCount(id)
+		err = dao.AddResourceViewCount(r.ID)
 		if err != nil {
 			log.Error("AddResourceViewCount error: ", err)
 		}
 	}
 	v := r.ToDetailView()
+	prefs := contentPreferences(c)
 	if c.Host() != "" {
 		related := findRelatedResources(r, c.Host())
+		blurResourceViews(related, prefs)
 		v.Related = related
 	}
-	fillRatings(&v)
-
-	removeNsfw := true
-	if c.LoggedIn() {
-		createdAt := c.UserCreatedAt()
-		if createdAt.Before(time.Now().Add(-72 * time.Hour)) {
-			removeNsfw = false
-		}
-	}
-	if removeNsfw {
-		removeNsfwImages(&v)
-	}
+	applyContentPreferences(c, &v, prefs)
 
 	return &v, nil
 }
 
-func GetResourceList(page int, sort model.RSort) ([]model.ResourceView, int, error) {
-	resources, totalPages, err := dao.GetResourceList(page, pageSize, sort)
+// reindexResource refreshes the search index document of a resource
+// after something it contains has changed.
+func reindexResource(id uint) {
+	if err := search.ReindexResource(id); err != nil {
+		log.Error("ReindexResource error: ", err)
+	}
+}
+
+func GetResourceList(c ctx.Context, page int, sort model.RSort) ([]model.ResourceView, int, error) {
+	prefs := contentPreferences(c)
+	resources, totalPages, err := dao.GetResourceList(page, pageSize, sort, newContentFilter(prefs))
 	if err != nil {
 		return nil, 0, err
 	}
@@ -245,233 +274,151 @@ func GetResourceList(page int, sort model.RSort) ([]model.ResourceView, int, err
 	for _, r := range resources {
 		views = append(views, r.ToView())
 	}
+	blurResourceViews(views, prefs)
 	return views, totalPages, nil
 }
 
-// splitQuery splits the input query string into keywords, treating quoted substrings (single or double quotes)
-// as single keywords and supporting escape characters for quotes. Spaces outside quotes are used as separators.
-func splitQuery(query string) []string {
-	var keywords []string
-
-	query = strings.TrimSpace(query)
-	if query == "" {
-		return keywords
-	}
-
-	l, r := 0, 0
-	inQuote := false
-	quoteChar := byte(0)
-
-	for r < len(query) {
-		if (query[r] == '"' || query[r] == '\'') && (r == 0 || query[r-1] != '\\') {
-			if !inQuote {
-				inQuote = true
-				quoteChar = query[r]
-				l = r + 1
-			} else if query[r] == quoteChar {
-				if r > l {
-					keywords = append(keywords, strings.TrimSpace(query[l:r]))
-				}
-				inQuote = false
-				r++
-				l = r
-				continue
-			}
-		} else if !inQuote && query[r] == ' ' {
-			if r > l {
-				keywords = append(keywords, strings.TrimSpace(query[l:r]))
-			}
-			for r < len(query) && query[r] == ' ' {
-				r++
-			}
-			l = r
-			continue
-		}
-
-		r++
+// compileSearchQuery parses a query written in the search query language and matches its
+// text terms against the search index. It returns the resources matched by each text term,
+// and the relevance of each of them. The node is nil if the query cannot match anything.
+func compileSearchQuery(query string) (searchql.Node, map[*searchql.Term][]uint, map[uint]float64, error) {
+	node, err := parseSearchQuery(query)
+	if err != nil || node == nil {
+		return nil, nil, nil, err
 	}
 
-	if l < len(query) {
-		keywords = append(keywords, strings.TrimSpace(query[l:r]))
-	}
-
-	return keywords
-}
-
-func searchWithKeyword(keyword string) ([]uint, error) {
-	resources := make([]uint, 0)
-
-	if len([]rune(keyword)) <= maxTagLength {
-		exists, err := dao.ExistsTag(keyword)
-		if err != nil {
-			return nil, err
+	textHits := make(map[*searchql.Term][]uint)
+	scores := make(map[uint]float64)
+	negated := searchql.NegatedTerms(node)
+	for _, t := range searchql.Terms(node) {
+		if t.Field != searchql.FieldText && t.Field != searchql.FieldTitle {
+			continue
 		}
-		if exists {
-			t, err := dao.GetTagByName(keyword)
-			if err != nil {
-				return nil, err
-			}
-			res, err := dao.GetResourcesIdWithTag(t.ID)
-			if err != nil {
-				return nil, err
+		// The resources excluded by a negated term must not depend on how they rank,
+		// and they do not make the other resources more relevant
+		isNegated := slices.Contains(negated, t)
+		var hits []search.Hit
+		if isNegated {
+			hits, err = search.MatchAllTerm(t)
+		} else {
+			hits, err = search.MatchTerm(t)
+		}
+		if err != nil {
+			log.Error("Failed to search resources: ", err)
+			return nil, nil, nil, model.NewInternalServerError("Failed to search resources")
+		}
+		ids := make([]uint, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+			if !isNegated {
+				scores[hit.ID] += hit.Score
 			}
-			resources = append(resources, res...)
 		}
+		textHits[t] = ids
 	}
+	return node, textHits, scores, nil
+}
 
-	searchResult, err := search.SearchResource(keyword)
+// parseSearchQuery parses a query written in the search query language, leaving out the
+// words which the index ignores. The node is nil if the query cannot match anything.
+func parseSearchQuery(query string) (searchql.Node, error) {
+	node, err := searchql.Parse(query)
 	if err != nil {
 		return nil, err
 	}
+	plain := searchql.IsPlainText(node)
 
-	resources = append(resources, searchResult...)
-
-	return resources, nil
-}
-
-func SearchResource(query string, page int) ([]model.ResourceView, int, error) {
-	if len([]rune(query)) > maxSearchQueryLength {
-		return nil, 0, model.NewRequestError("Search query is too long")
-	}
-
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	resources := make([]uint, 0)
-
-	checkTag := func(tag string) error {
-		if len([]rune(tag)) > maxTagLength {
-			return nil
+	// Words which the index ignores would match nothing
+	node = searchql.RemoveTerms(node, func(t *searchql.Term) bool {
+		if t.Field != searchql.FieldText {
+			return false
 		}
-		exists, err := dao.ExistsTag(tag)
-		if err != nil {
-			return err
+		return strings.TrimSpace(t.Text) == "" || utils.OnlyPunctuation(t.Text) ||
+			(!t.Phrase && search.IsStopWord(t.Text))
+	})
+
+	// A plain query may be the name of a tag, with or without spaces
+	if plain {
+		query = strings.TrimSpace(query)
+		alternatives := make([]searchql.Node, 0, 3)
+		if node != nil {
+			alternatives = append(alternatives, node)
 		}
-		if exists {
-			t, err := dao.GetTagByName(tag)
-			if err != nil {
-				return err
-			}
-			res, err := dao.GetResourcesIdWithTag(t.ID)
-			if err != nil {
-				return err
+		for _, name := range utils.RemoveDuplicate([]string{query, utils.RemoveSpaces(query)}) {
+			if len([]rune(name)) <= maxTagLength {
+				alternatives = append(alternatives, &searchql.Term{Field: searchql.FieldTag, Text: name})
 			}
-			resources = append(resources, res...)
 		}
-		return nil
+		if len(alternatives) > 0 {
+			node = &searchql.Or{Children: alternatives}
+		}
 	}
+	return node, nil
+}
 
-	// check tag
-	if err := checkTag(query); err != nil {
-		return nil, 0, err
+// SearchResource lists the resources matching a query written in the search query language,
+// with facet counts of all matching resources. Malformed queries return a *searchql.ParseError.
+func SearchResource(c ctx.Context, query string, sort model.RSort, page int) ([]model.ResourceView, int, *model.SearchFacets, error) {
+	if len([]rune(query)) > maxSearchQueryLength {
+		return nil, 0, nil, model.NewRequestError("Search query is too long")
 	}
-
-	// check tag after removing spaces
-	trimmed := utils.RemoveSpaces(query)
-	if trimmed != query {
-		if err := checkTag(trimmed); err != nil {
-			return nil, 0, err
-		}
+	if page < 1 {
+		page = 1
 	}
-
-	// split query to search
-	keywords := splitQuery(query)
-	var temp []uint
-	haveTag := false
-	for _, keyword := range keywords {
-		if len([]rune(keyword)) <= maxTagLength {
-			exists, err := dao.ExistsTag(keyword)
-			if err != nil {
-				return nil, 0, err
-			}
-			if exists {
-				haveTag = true
-			}
-		}
+	node, textHits, scores, err := compileSearchQuery(query)
+	if err != nil {
+		return nil, 0, nil, err
 	}
-	if haveTag {
-		first := true
-		for _, keyword := range keywords {
-			if keyword == "" {
-				continue
-			}
-			if utils.OnlyPunctuation(keyword) {
-				continue
-			}
-
-			res, err := searchWithKeyword(keyword)
-			if err != nil {
-				return nil, 0, err
-			}
-			if len(res) == 0 && search.IsStopWord(keyword) {
-				continue
-			}
-			if first {
-				temp = utils.RemoveDuplicate(res)
-				first = false
-			} else {
-				temp1 := make([]uint, 0)
-				for _, id := range temp {
-					for _, id2 := range res {
-						if id == id2 {
-							temp1 = append(temp1, id)
-							break
-						}
-					}
-				}
-				temp = temp1
-			}
-		}
-	} else {
-		res, err := searchWithKeyword(query)
-		if err != nil {
-			return nil, 0, err
-		}
-		temp = res
+	if node == nil {
+		return []model.ResourceView{}, 0, model.NewSearchFacets(), nil
 	}
-	resources = append(resources, temp...)
-	resources = utils.RemoveDuplicate(resources)
 
-	if start >= len(resources) {
-		return []model.ResourceView{}, 0, nil
+	// Resources matching more words of the query are more relevant
+	ranking := make([]uint, 0, len(scores))
+	for id := range scores {
+		ranking = append(ranking, id)
 	}
+	slices.SortFunc(ranking, func(a, b uint) int {
+		if c := cmp.Compare(scores[b], scores[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
 
-	total := len(resources)
-	totalPages := (total + pageSize - 1) / pageSize
-	if start >= total {
-		return []model.ResourceView{}, totalPages, nil
-	}
-	if end > total {
-		end = total
+	prefs := contentPreferences(c)
+	filter := newContentFilter(prefs)
+	resources, totalPages, err := dao.SearchResources(node, textHits, ranking, sort, page, pageSize, filter)
+	if err != nil {
+		log.Error("Failed to search resources: ", err)
+		return nil, 0, nil, model.NewInternalServerError("Failed to search resources")
 	}
-	idsPage := resources[start:end]
-
-	resourcesPage, err := dao.BatchGetResources(idsPage)
+	facets, err := dao.GetSearchFacets(node, textHits, maxSearchFacetValues, filter)
 	if err != nil {
-		return nil, 0, err
+		log.Error("Failed to count search facets: ", err)
+		return nil, 0, nil, model.NewInternalServerError("Failed to search resources")
 	}
-	var views []model.ResourceView
-	for _, r := range resourcesPage {
+	views := make([]model.ResourceView, 0, len(resources))
+	for _, r := range resources {
 		views = append(views, r.ToView())
 	}
-	return views, totalPages, nil
+	blurResourceViews(views, prefs)
+	return views, totalPages, facets, nil
 }
 
 func DeleteResource(c ctx.Context, id uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
 	uid := c.MustUserID()
 	isAdmin := c.UserPermission() == model.PermissionAdmin
-	if !isAdmin {
-		r, err := dao.GetResourceByID(id)
-		if err != nil {
-			return err
-		}
-		if r.UserID != uid {
-			return model.NewUnAuthorizedError("You have not permission to delete this resource")
-		}
-	}
-	r, err := GetResource(id, c)
+	// Merged resources are already deleted, their ids must not lead to the merge target
+	r, err := dao.GetResourceByID(id)
 	if err != nil {
 		return err
 	}
+	if !isAdmin && r.UserID != uid {
+		return model.NewUnAuthorizedError("You have not permission to delete this resource")
+	}
 	if len(r.Files) > 0 {
 		return model.NewRequestError("This resource has files, please delete them first")
 	}
@@ -488,13 +435,14 @@ func DeleteResource(c ctx.Context, id uint) error {
 	return nil
 }
 
-func GetResourcesWithTag(tag string, page int) ([]model.ResourceView, int, error) {
+func GetResourcesWithTag(c ctx.Context, tag string, page int) ([]model.ResourceView, int, error) {
 	t, err := dao.GetTagByName(tag)
 	if err != nil {
 		return nil, 0, err
 	}
 	tagID := t.ID
-	resources, totalPages, err := dao.GetResourceByTag(tagID, page, pageSize)
+	prefs := contentPreferences(c)
+	resources, totalPages, err := dao.GetResourceByTag(tagID, page, pageSize, newContentFilter(prefs))
 	if err != nil {
 		return nil, 0, err
 	}
@@ -502,6 +450,7 @@ func GetResourcesWithTag(tag string, page int) ([]model.ResourceView, int, error
 	for _, r := range resources {
 		views = append(views, r.ToView())
 	}
+	blurResourceViews(views, prefs)
 	return views, totalPages, nil
 }
 
@@ -518,6 +467,9 @@ func GetResourcesWithUser(username string, page int) ([]model.ResourceView, int,
 }
 
 func UpdateResource(c ctx.Context, rid uint, params *ResourceParams) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
 	uid := c.MustUserID()
 	canUpload := c.UserPermission() >= model.PermissionUploader
 	r, err := dao.GetResourceByID(rid)
@@ -527,6 +479,7 @@ func UpdateResource(c ctx.Context, rid uint, params *ResourceParams) error {
 	if r.UserID != uid && !canUpload {
 		return model.NewUnAuthorizedError("You have not permission to edit this resource")
 	}
+	baseline := newBaselineRevision(&r)
 
 	gallery := make([]uint, 0, len(params.Gallery))
 	for _, id := range params.Gallery {
@@ -577,6 +530,7 @@ func UpdateResource(c ctx.Context, rid uint, params *ResourceParams) error {
 		coverID = params.CoverID
 	}
 
+	linksChanged := !slices.Equal(r.Links, params.Links)
 	r.Title = params.Title
 	r.AlternativeTitles = params.AlternativeTitles
 	r.Article = params.Article
@@ -595,8 +549,13 @@ func UpdateResource(c ctx.Context, rid uint, params *ResourceParams) error {
 			},
 		}
 	}
-	tags := make([]model.Tag, len(params.Tags))
-	for i, id := range params.Tags {
+	tagIDs, err := withImpliedTags(params.Tags)
+	if err != nil {
+		log.Error("withImpliedTags error: ", err)
+		return model.NewInternalServerError("Failed to apply implied tags")
+	}
+	tags := make([]model.Tag, len(tagIDs))
+	for i, id := range tagIDs {
 		tags[i] = model.Tag{
 			Model: gorm.Model{
 				ID: id,
@@ -617,23 +576,29 @@ func UpdateResource(c ctx.Context, rid uint, params *ResourceParams) error {
 	if err != nil {
 		log.Error("AddUpdateResourceActivity error: ", err)
 	}
+	saveResourceRevision(newResourceRevision(uid, &r), baseline)
 	if err := search.AddResourceToIndex(r); err != nil {
 		log.Error("AddResourceToIndex error: ", err)
 	}
+	if linksChanged && hasSyncableLinks(r.Links) {
+		scheduleMetadataSync(r.ID)
+	}
 	return nil
 }
 
-func RandomResource(host string) (*model.ResourceDetailView, error) {
-	r, err := dao.RandomResource()
+func RandomResource(c ctx.Context, host string) (*model.ResourceDetailView, error) {
+	prefs := contentPreferences(c)
+	r, err := dao.RandomResource(newContentFilter(prefs))
 	if err != nil {
 		return nil, err
 	}
 	v := r.ToDetailView()
 	if host != "" {
 		related := findRelatedResources(r, host)
+		blurResourceViews(related, prefs)
 		v.Related = related
 	}
-	fillRatings(&v)
+	applyContentPreferences(c, &v, prefs)
 	return &v, nil
 }
 
@@ -641,7 +606,7 @@ var lastSuccessCover uint
 
 func RandomCover() (uint, error) {
 	for retries := 0; retries < 5; retries++ {
-		v, err := dao.RandomResource()
+		v, err := dao.RandomResource(nil)
 		if err != nil {
 			return 0, err
 		}
@@ -679,10 +644,48 @@ func GetPinnedResources() ([]model.ResourceView, error) {
 }
 
 func GetCharactersFromVndb(vnID string, c ctx.Context) ([]CharacterParams, error) {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return nil, err
+	}
 	if c.UserPermission() < model.PermissionUploader {
 		return nil, model.NewUnAuthorizedError("You have not permission to fetch characters from VNDB")
 	}
+	return fetchVndbCharacters(vnID, downloadAndCreateImage)
+}
 
+// imageDownloader downloads an image from a URL and stores it, returning the image ID.
+type imageDownloader func(imageURL string) (uint, error)
+
+func fetchVndbCharacters(vnID string, download imageDownloader) ([]CharacterParams, error) {
+	list, err := fetchVndbCharacterList(vnID)
+	if err != nil {
+		return nil, err
+	}
+	characters := make([]CharacterParams, 0, len(list))
+	for _, ch := range list {
+		character := CharacterParams{
+			Name:  ch.Name,
+			Alias: ch.Alias,
+			CV:    ch.CV,
+			Role:  ch.Role,
+		}
+		// 下载并保存角色图片
+		if ch.ImageURL != "" {
+			imageID, err := download(ch.ImageURL)
+			if err != nil {
+				log.Error("Failed to download character image:", err)
+				// 继续处理，即使图片下载失败
+			} else {
+				character.Image = imageID
+			}
+		}
+		characters = append(characters, character)
+	}
+	return characters, nil
+}
+
+// fetchVndbCharacterList fetches the main characters of a visual novel without downloading their images.
+func fetchVndbCharacterList(vnID string) ([]model.ExternalCharacter, error) {
 	client := http.Client{}
 	jsonStr := fmt.Sprintf(`
 	{
@@ -692,7 +695,7 @@ func GetCharactersFromVndb(vnID string, c ctx.Context) ([]CharacterParams, error
 	`, vnID)
 	jsonStr = strings.TrimSpace(jsonStr)
 	reader := strings.NewReader(jsonStr)
-	resp, err := client.Post("https://api.vndb.org/kana/vn", "application/json", reader)
+	resp, err := client.Post(vndbApiUrl+"/vn", "application/json", reader)
 	if err != nil {
 		return nil, model.NewInternalServerError("Failed to fetch data from VNDB")
 	}
@@ -733,11 +736,11 @@ func GetCharactersFromVndb(vnID string, c ctx.Context) ([]CharacterParams, error
 	}
 
 	if len(vndbResp.Results) == 0 {
-		return []CharacterParams{}, nil
+		return []model.ExternalCharacter{}, nil
 	}
 
 	result := vndbResp.Results[0]
-	var characters []CharacterParams
+	var characters []model.ExternalCharacter
 	processedCharacters := make(map[string]bool) // 避免重复角色
 
 	// 遍历声优信息
@@ -775,26 +778,13 @@ func GetCharactersFromVndb(vnID string, c ctx.Context) ([]CharacterParams, error
 			cvName = va.Staff.Name
 		}
 
-		character := CharacterParams{
-			Name:  characterName,
-			Alias: []string{},
-			CV:    cvName,
-			Role:  role,
-			Image: 0, // 默认值，下面会下载图片
-		}
-
-		// 下载并保存角色图片
-		if va.Character.Image.URL != "" {
-			imageID, err := downloadAndCreateImage(va.Character.Image.URL)
-			if err != nil {
-				log.Error("Failed to download character image:", err)
-				// 继续处理，即使图片下载失败
-			} else {
-				character.Image = imageID
-			}
-		}
-
-		characters = append(characters, character)
+		characters = append(characters, model.ExternalCharacter{
+			Name:     characterName,
+			Alias:    []string{},
+			CV:       cvName,
+			Role:     role,
+			ImageURL: va.Character.Image.URL,
+		})
 	}
 
 	return characters, nil
@@ -851,7 +841,7 @@ func GetReleaseDateFromVndb(vnID string) (string, error) {
 	`, vnID)
 	jsonStr = strings.TrimSpace(jsonStr)
 	reader := strings.NewReader(jsonStr)
-	resp, err := client.Post("https://api.vndb.org/kana/vn", "application/json", reader)
+	resp, err := client.Post(vndbApiUrl+"/vn", "application/json", reader)
 	if err != nil {
 		return "", model.NewInternalServerError("Failed to fetch data from VNDB")
 	}
@@ -879,6 +869,9 @@ func GetReleaseDateFromVndb(vnID string) (string, error) {
 
 // UpdateCharacterImage 更新角色的图片ID
 func UpdateCharacterImage(c ctx.Context, resourceID, characterID, imageID uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
 	// 检查资源是否存在并且用户有权限修改
 	resource, err := dao.GetResourceByID(resourceID)
 	if err != nil {
@@ -969,6 +962,9 @@ func GetLowResolutionResourceImages(page int, pageSize int, maxWidth, maxHeight
 
 // UpdateResourceImage 更新资源图片
 func UpdateResourceImage(c ctx.Context, resourceID, oldImageID, newImageID uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
 	// 首先检查用户权限 - 确保用户是资源的所有者或管理员
 	resource, err := dao.GetResourceByID(resourceID)
 	if err != nil {
@@ -986,142 +982,15 @@ func UpdateResourceImage(c ctx.Context, resourceID, oldImageID, newImageID uint)
 	return dao.UpdateResourceImage(resourceID, oldImageID, newImageID)
 }
 
-func getVNDBRating(vnID string) (int, error) {
-	client := http.Client{}
-	jsonStr := fmt.Sprintf(`
-	{
-		"filters": ["id", "=", "%s"],
-		"fields": "rating"
-	}
-	`, vnID)
-	jsonStr = strings.TrimSpace(jsonStr)
-	reader := strings.NewReader(jsonStr)
-	resp, err := client.Post("https://api.vndb.org/kana/vn", "application/json", reader)
-	if err != nil {
-		return 0, model.NewInternalServerError("Failed to fetch data from VNDB")
+// applyContentPreferences removes the NSFW images of a resource unless the user opted in,
+// and marks it blurred if it has a tag the user wants hidden or blurred.
+func applyContentPreferences(c ctx.Context, v *model.ResourceDetailView, prefs model.ContentPreferences) {
+	if !canSeeNsfw(c, prefs) {
+		removeNsfwImages(v)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return 0, model.NewInternalServerError("Failed to fetch data from VNDB")
-	}
-	type VndbResponse struct {
-		Results []struct {
-			Rating float32 `json:"rating"`
-		} `json:"results"`
-	}
-	var vndbResp VndbResponse
-	if err := json.NewDecoder(resp.Body).Decode(&vndbResp); err != nil {
-		return 0, model.NewInternalServerError("Failed to parse VNDB response: " + err.Error())
-	}
-	if len(vndbResp.Results) == 0 {
-		return 0, nil
-	}
-	rating := vndbResp.Results[0].Rating
-	return int(math.Round(float64(rating))), nil
-}
-
-func getVNDBRatingWithCache(vnID string) (int, error) {
-	cacheKey := fmt.Sprintf("vndb_rating_%s", vnID)
-	ratingStr, err := cache.Get(cacheKey)
-	if err != nil && !errors.Is(err, cache.ErrNotFound) {
-		return 0, err
-	} else if errors.Is(err, cache.ErrNotFound) {
-		rating, err := getVNDBRating(vnID)
-		if err != nil {
-			return 0, err
-		}
-		err = cache.Set(cacheKey, strconv.Itoa(rating), 24*time.Hour)
-		if err != nil {
-			log.Error("Failed to set VNDB rating cache: ", err)
-		}
-		return rating, nil
-	}
-	rating, err := strconv.Atoi(ratingStr)
-	if err != nil {
-		return 0, model.NewInternalServerError("Failed to parse VNDB rating")
-	}
-	return rating, nil
-}
-
-func getSteamRating(steamID string) (int, error) {
-	client := http.Client{}
-	url := fmt.Sprintf("https://store.steampowered.com/appreviews/%s?json=1&language=all&purchase_type=all&cursor=*&num_per_page=0", steamID)
-	resp, err := client.Get(url)
-	if err != nil {
-		return 0, model.NewInternalServerError("Failed to get Steam rating")
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return 0, model.NewInternalServerError("Failed to get Steam rating")
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, model.NewInternalServerError("Failed to read Steam rating")
-	}
-	var steamResp struct {
-		QuerySummary struct {
-			TotalReviews  int `json:"total_reviews"`
-			TotalPositive int `json:"total_positive"`
-		} `json:"query_summary"`
-	}
-	if err := json.Unmarshal(body, &steamResp); err != nil {
-		return 0, model.NewInternalServerError("Failed to parse Steam rating")
-	}
-	rating := int(math.Round(float64(steamResp.QuerySummary.TotalPositive) / float64(steamResp.QuerySummary.TotalReviews) * 100))
-	return rating, nil
-}
-
-func getSteamRatingWithCache(steamID string) (int, error) {
-	cacheKey := fmt.Sprintf("steam_rating_%s", steamID)
-	ratingStr, err := cache.Get(cacheKey)
-	if err != nil && !errors.Is(err, cache.ErrNotFound) {
-		return 0, err
-	} else if errors.Is(err, cache.ErrNotFound) {
-		rating, err := getSteamRating(steamID)
-		if err != nil {
-			return 0, err
-		}
-		err = cache.Set(cacheKey, strconv.Itoa(rating), 24*time.Hour)
-		if err != nil {
-			log.Error("Failed to set Steam rating cache: ", err)
-		}
-		return rating, nil
-	}
-	rating, err := strconv.Atoi(ratingStr)
-	if err != nil {
-		return 0, model.NewInternalServerError("Failed to parse Steam rating")
-	}
-	return rating, nil
-}
-
-func fillRatings(resource *model.ResourceDetailView) {
-	ratings := make(map[string]int)
-	for _, link := range resource.Links {
-		if link.Label == "" {
-			continue
-		}
-		if vnID, ok := strings.CutPrefix(link.URL, "https://vndb.org/v"); ok {
-			vnID = strings.TrimSpace(vnID)
-			rating, err := getVNDBRatingWithCache(vnID)
-			if err == nil {
-				ratings[link.Label] = rating
-			} else {
-				log.Error("Failed to get VNDB rating: ", err)
-			}
-		} else if steamID, ok := strings.CutPrefix(link.URL, "https://store.steampowered.com/app/"); ok {
-			steamID = strings.TrimSpace(steamID)
-			if strings.Contains(steamID, "/") {
-				steamID = strings.Split(steamID, "/")[0]
-			}
-			rating, err := getSteamRatingWithCache(steamID)
-			if err == nil {
-				ratings[link.Label] = rating
-			} else {
-				log.Error("Failed to get Steam rating: ", err)
-			}
-		}
+	if tags := slices.Concat(prefs.HiddenTags, prefs.BlurredTags); len(tags) > 0 {
+		v.Blurred = hasAnyTag(v.Tags, blurredTagIDs(tags))
 	}
-	resource.Ratings = ratings
 }
 
 func removeNsfwImages(r *model.ResourceDetailView) {
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	aireview "nysoure/server/ai_review"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/search"
+	"nysoure/server/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	maxDraftArticleLength  = 20000
+	maxDraftSummaryLength  = 200
+	maxDraftPromptTags     = 500
+	maxDraftDuplicates     = 5
+	maxDraftAlternateNames = 10
+)
+
+type ResourceDraftParams struct {
+	Title   string       `json:"title"`
+	Article string       `json:"article"`
+	Links   []model.Link `json:"links"`
+}
+
+type ResourceDraftSuggestion struct {
+	AlternativeTitles []string             `json:"alternative_titles"`
+	Tags              []model.TagView      `json:"tags"`
+	Summary           string               `json:"summary"`
+	Duplicates        []model.ResourceView `json:"duplicates"`
+	// AIUsed is false if the AI provider is unavailable and the suggestions
+	// were produced by local matching only.
+	AIUsed bool `json:"ai_used"`
+}
+
+const resourceDraftPrompt = `你是一个资源信息整理助手。请根据下面的资源标题、介绍和链接提取信息。
+
+要求：
+1. alternative_titles：资源的其他名称（如原名、译名、简称），不要包含标题本身，没有则返回空数组
+2. tags：从候选标签中选择与资源相符的标签，只能使用候选标签中的名称
+3. summary：用一两句话概括资源内容，纯文本，不要包含链接
+
+候选标签：
+%s
+
+标题：
+%s
+
+链接：
+%s
+
+介绍：
+%s`
+
+var resourceDraftSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"alternative_titles": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "string"},
+		},
+		"tags": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "string"},
+		},
+		"summary": map[string]any{
+			"type": "string",
+		},
+	},
+	"required":             []string{"alternative_titles", "tags", "summary"},
+	"additionalProperties": false,
+}
+
+// SuggestResourceDraft produces suggestions for a resource which is being created.
+// Nothing is saved; the uploader decides which suggestions to apply.
+func SuggestResourceDraft(c ctx.Context, params *ResourceDraftParams) (*ResourceDraftSuggestion, error) {
+	if c.UserPermission() < model.PermissionUploader {
+		return nil, model.NewUnAuthorizedError("You have not permission to upload resources")
+	}
+	params.Title = strings.TrimSpace(params.Title)
+	if params.Title == "" && strings.TrimSpace(params.Article) == "" {
+		return nil, model.NewRequestError("Title or article is required")
+	}
+	if len([]rune(params.Article)) > maxDraftArticleLength {
+		return nil, model.NewRequestError("Article is too long")
+	}
+
+	matcher, err := newTagMatcher()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := GetTagList()
+	if err != nil {
+		return nil, err
+	}
+	tagNames := make([]string, 0, len(tags))
+	for _, t := range tags {
+		tagNames = append(tagNames, t.Name)
+	}
+
+	return suggestResourceDraft(c, params, matcher, tagNames, findDraftDuplicates)
+}
+
+// suggestResourceDraft produces the suggestions with the given candidate tags.
+// findDuplicates receives the title and the suggested alternative titles.
+func suggestResourceDraft(c context.Context, params *ResourceDraftParams, matcher *tagMatcher, tagNames []string, findDuplicates func(titles []string) ([]model.ResourceView, error)) (*ResourceDraftSuggestion, error) {
+	suggestion := &ResourceDraftSuggestion{
+		AlternativeTitles: []string{},
+		Tags:              []model.TagView{},
+	}
+
+	var aiResult struct {
+		AlternativeTitles []string `json:"alternative_titles"`
+		Tags              []string `json:"tags"`
+		Summary           string   `json:"summary"`
+	}
+	prompt := buildResourceDraftPrompt(params, tagNames)
+	err := aireview.ChatJSON(c, prompt, "resource_draft", resourceDraftSchema, &aiResult)
+	if err != nil {
+		if !errors.Is(err, aireview.ErrNotConfigured) {
+			log.Error("AI resource draft failed: ", err)
+		}
+		suggestion.Tags = matcher.findInText(params.Title + "\n" + params.Article)
+		suggestion.Summary = utils.ArticleToDescription(params.Article, maxDraftSummaryLength)
+	} else {
+		suggestion.AIUsed = true
+		suggestion.Tags = matcher.match(aiResult.Tags)
+		suggestion.Summary = utils.ArticleToDescription(aiResult.Summary, maxDraftSummaryLength)
+		for _, t := range utils.RemoveDuplicate(aiResult.AlternativeTitles) {
+			t = strings.TrimSpace(t)
+			if t == "" || t == params.Title {
+				continue
+			}
+			suggestion.AlternativeTitles = append(suggestion.AlternativeTitles, t)
+			if len(suggestion.AlternativeTitles) >= maxDraftAlternateNames {
+				break
+			}
+		}
+	}
+
+	suggestion.Duplicates, err = findDuplicates(append([]string{params.Title}, suggestion.AlternativeTitles...))
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestion, nil
+}
+
+func buildResourceDraftPrompt(params *ResourceDraftParams, tagNames []string) string {
+	if len(tagNames) > maxDraftPromptTags {
+		tagNames = tagNames[:maxDraftPromptTags]
+	}
+	links := make([]string, 0, len(params.Links))
+	for _, l := range params.Links {
+		links = append(links, fmt.Sprintf("%s: %s", l.Label, l.URL))
+	}
+	return fmt.Sprintf(resourceDraftPrompt,
+		strings.Join(tagNames, ", "),
+		params.Title,
+		strings.Join(links, "\n"),
+		params.Article,
+	)
+}
+
+// findDraftDuplicates returns existing resources whose titles match any of the given titles.
+func findDraftDuplicates(titles []string) ([]model.ResourceView, error) {
+	ids := make([]uint, 0)
+	for _, title := range titles {
+		if title == "" {
+			continue
+		}
+		res, err := search.SearchResource(title)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, res...)
+	}
+	ids = utils.RemoveDuplicate(ids)
+	if len(ids) > maxDraftDuplicates {
+		ids = ids[:maxDraftDuplicates]
+	}
+	resources, err := dao.BatchGetResources(ids)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]model.ResourceView, 0, len(resources))
+	for _, r := range resources {
+		views = append(views, r.ToView())
+	}
+	return views, nil
+}
+
+// tagMatcher resolves tag names and aliases to existing main tags.
+type tagMatcher struct {
+	byName map[string]model.Tag
+	tags   []model.Tag
+}
+
+func newTagMatcher() (*tagMatcher, error) {
+	tags, err := dao.ListTags()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := dao.ListTagAliases()
+	if err != nil {
+		return nil, err
+	}
+	return newTagMatcherFromTags(tags, aliases), nil
+}
+
+// newTagMatcherFromTags builds a matcher from main tags and aliases pointing to them.
+func newTagMatcherFromTags(tags []model.Tag, aliases []model.Tag) *tagMatcher {
+	m := &tagMatcher{
+		byName: make(map[string]model.Tag, len(tags)+len(aliases)),
+		tags:   tags,
+	}
+	byID := make(map[uint]model.Tag, len(tags))
+	for _, t := range tags {
+		byID[t.ID] = t
+		m.byName[strings.ToLower(t.Name)] = t
+	}
+	for _, a := range aliases {
+		if main, ok := byID[*a.AliasOf]; ok {
+			m.byName[strings.ToLower(a.Name)] = main
+			m.tags = append(m.tags, model.Tag{Name: a.Name, AliasOf: &main.ID})
+		}
+	}
+	return m
+}
+
+// match maps names to main tags, dropping unknown names.
+func (m *tagMatcher) match(names []string) []model.TagView {
+	result := make([]model.TagView, 0, len(names))
+	seen := make(map[uint]bool)
+	for _, name := range names {
+		t, ok := m.byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok || seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		result = append(result, *t.ToView())
+	}
+	return result
+}
+
+// findInText returns main tags whose name or alias appears in text.
+func (m *tagMatcher) findInText(text string) []model.TagView {
+	text = strings.ToLower(text)
+	names := make([]string, 0)
+	for _, t := range m.tags {
+		// Very short names match too many unrelated words
+		if len([]rune(t.Name)) < 2 {
+			continue
+		}
+		if strings.Contains(text, strings.ToLower(t.Name)) {
+			names = append(names, t.Name)
+		}
+	}
+	return m.match(names)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	aireview "nysoure/server/ai_review"
+	"nysoure/server/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTestDraftMatcher() *tagMatcher {
+	mainID := uint(1)
+	return newTagMatcherFromTags(
+		[]model.Tag{
+			{Model: gorm.Model{ID: 1}, Name: "科幻"},
+			{Model: gorm.Model{ID: 2}, Name: "Time Travel"},
+			{Model: gorm.Model{ID: 3}, Name: "R"},
+		},
+		[]model.Tag{{Model: gorm.Model{ID: 4}, Name: "Sci-fi", AliasOf: &mainID}},
+	)
+}
+
+func draftTagIDs(tags []model.TagView) []uint {
+	ids := make([]uint, 0, len(tags))
+	for _, t := range tags {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+func TestTagMatcherMatch(t *testing.T) {
+	matcher := newTestDraftMatcher()
+
+	// Aliases resolve to the main tag, which is returned once
+	tags := matcher.match([]string{" sci-fi ", "科幻", "time travel", "Unknown"})
+	assert.Equal(t, []uint{1, 2}, draftTagIDs(tags))
+	assert.Equal(t, "科幻", tags[0].Name)
+}
+
+func TestTagMatcherFindInText(t *testing.T) {
+	matcher := newTestDraftMatcher()
+
+	tags := matcher.findInText("一部硬核科幻作品，讲述了TIME TRAVELERS的故事")
+	assert.Equal(t, []uint{1, 2}, draftTagIDs(tags))
+
+	tags = matcher.findInText("A classic SCI-FI story")
+	assert.Equal(t, []uint{1}, draftTagIDs(tags))
+
+	// Single letter names are not matched inside words
+	assert.Empty(t, matcher.findInText("Romance"))
+}
+
+func TestSuggestResourceDraft(t *testing.T) {
+	fake := &aireview.FakeProvider{Reply: `{
+		"alternative_titles": ["Ever17", "E17", "Ever17", " ", "Ever 17"],
+		"tags": ["Sci-fi", "科幻", "Mystery"],
+		"summary": "A **story** set in an underwater park."
+	}`}
+	aireview.SetProvider(fake)
+	defer aireview.SetProvider(nil)
+
+	var searched []string
+	duplicates := func(titles []string) ([]model.ResourceView, error) {
+		searched = titles
+		return []model.ResourceView{{ID: 7, Title: "Ever17 -the out of infinity-"}}, nil
+	}
+	params := &ResourceDraftParams{
+		Title:   "Ever 17",
+		Article: "Time travel",
+		Links:   []model.Link{{Label: "VNDB", URL: "https://vndb.org/v17"}},
+	}
+
+	suggestion, err := suggestResourceDraft(context.Background(), params, newTestDraftMatcher(), []string{"科幻", "Time Travel"}, duplicates)
+	assert.NoError(t, err)
+	assert.True(t, suggestion.AIUsed)
+	assert.Equal(t, []string{"Ever17", "E17"}, suggestion.AlternativeTitles)
+	assert.Equal(t, []uint{1}, draftTagIDs(suggestion.Tags))
+	assert.Equal(t, "A story set in an underwater park.", suggestion.Summary)
+
+	// Near duplicates are looked up by the title and the alternative titles
+	assert.Equal(t, []string{"Ever 17", "Ever17", "E17"}, searched)
+	if assert.Len(t, suggestion.Duplicates, 1) {
+		assert.Equal(t, uint(7), suggestion.Duplicates[0].ID)
+	}
+
+	requests := fake.Requests()
+	if assert.Len(t, requests, 1) {
+		assert.Contains(t, requests[0].Prompt, "科幻, Time Travel")
+		assert.Contains(t, requests[0].Prompt, "VNDB: https://vndb.org/v17")
+		assert.NotNil(t, requests[0].Schema)
+	}
+}
+
+func TestSuggestResourceDraftWithoutProvider(t *testing.T) {
+	aireview.SetProvider(nil)
+
+	var searched []string
+	duplicates := func(titles []string) ([]model.ResourceView, error) {
+		searched = titles
+		return []model.ResourceView{}, nil
+	}
+	params := &ResourceDraftParams{
+		Title:   "Ever17",
+		Article: "A *sci-fi* mystery about time travel.",
+	}
+
+	suggestion, err := suggestResourceDraft(context.Background(), params, newTestDraftMatcher(), nil, duplicates)
+	assert.NoError(t, err)
+	assert.False(t, suggestion.AIUsed)
+	assert.Empty(t, suggestion.AlternativeTitles)
+	assert.ElementsMatch(t, []uint{1, 2}, draftTagIDs(suggestion.Tags))
+	assert.Equal(t, "A sci-fi mystery about time travel.", suggestion.Summary)
+	assert.Equal(t, []string{"Ever17"}, searched)
+	assert.Empty(t, suggestion.Duplicates)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"encoding/json"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/utils"
+	"slices"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+type ResourceRevisionDetail struct {
+	model.ResourceRevisionView
+	Snapshot ResourceParams `json:"snapshot"`
+}
+
+// RevisionFieldDiff describes a field which differs between two revisions.
+type RevisionFieldDiff struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+	// Lines is the line diff of the article, which is too long to be shown as a whole.
+	Lines []utils.DiffLine `json:"lines,omitempty"`
+}
+
+// resourceToParams converts a resource to the shape of an update request.
+func resourceToParams(r *model.Resource) ResourceParams {
+	params := ResourceParams{
+		Title:             r.Title,
+		AlternativeTitles: r.AlternativeTitles,
+		Links:             r.Links,
+		Article:           r.Article,
+		CoverID:           r.CoverID,
+		Gallery:           r.Gallery,
+		GalleryNsfw:       r.GalleryNsfw,
+		Tags:              make([]uint, len(r.Tags)),
+		Images:            make([]uint, len(r.Images)),
+		Characters:        make([]CharacterParams, len(r.Characters)),
+	}
+	if r.ReleaseDate != nil {
+		params.ReleaseDate = r.ReleaseDate.Format("2006-01-02")
+	}
+	for i, t := range r.Tags {
+		params.Tags[i] = t.ID
+	}
+	for i, image := range r.Images {
+		params.Images[i] = image.ID
+	}
+	for i, c := range r.Characters {
+		params.Characters[i] = CharacterParams{
+			Name:  c.Name,
+			Alias: c.Alias,
+			CV:    c.CV,
+			Role:  c.Role,
+		}
+		if c.ImageID != nil {
+			params.Characters[i].Image = *c.ImageID
+		}
+	}
+	return params
+}
+
+func newResourceRevision(uid uint, r *model.Resource) *model.ResourceRevision {
+	// Marshalling ResourceParams cannot fail
+	data, _ := json.Marshal(resourceToParams(r))
+	return &model.ResourceRevision{
+		ResourceID: r.ID,
+		UserID:     uid,
+		Snapshot:   string(data),
+	}
+}
+
+// newBaselineRevision records the state of a resource before an update, attributed to its owner.
+func newBaselineRevision(r *model.Resource) *model.ResourceRevision {
+	revision := newResourceRevision(r.UserID, r)
+	revision.CreatedAt = r.ModifiedTime
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = r.CreatedAt
+	}
+	return revision
+}
+
+// saveResourceRevision stores a new revision. Resources created before revisions were
+// recorded have no history, so their previous state is stored first as a baseline.
+func saveResourceRevision(revision, baseline *model.ResourceRevision) {
+	if baseline != nil {
+		exists, err := dao.HasResourceRevisions(revision.ResourceID)
+		if err != nil {
+			log.Error("HasResourceRevisions error: ", err)
+		} else if !exists {
+			if err := dao.CreateResourceRevision(baseline); err != nil {
+				log.Error("CreateResourceRevision error: ", err)
+			}
+		}
+	}
+	if err := dao.CreateResourceRevision(revision); err != nil {
+		log.Error("CreateResourceRevision error: ", err)
+	}
+}
+
+func ListResourceRevisions(c ctx.Context, resourceID uint, page int) ([]model.ResourceRevisionView, int, error) {
+	owner, err := dao.GetResourceOwnerID(resourceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := checkResourceEditPermission(c, owner); err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	revisions, totalPages, err := dao.ListResourceRevisions(resourceID, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	views := make([]model.ResourceRevisionView, len(revisions))
+	for i := range revisions {
+		views[i] = revisions[i].ToView()
+	}
+	return views, totalPages, nil
+}
+
+// getResourceRevision loads a revision of the resource after checking the permission.
+func getResourceRevision(c ctx.Context, resourceID, revisionID uint) (*model.ResourceRevision, *ResourceParams, error) {
+	owner, err := dao.GetResourceOwnerID(resourceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkResourceEditPermission(c, owner); err != nil {
+		return nil, nil, err
+	}
+	revision, err := dao.GetResourceRevision(revisionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revision.ResourceID != resourceID {
+		return nil, nil, model.NewNotFoundError("Revision not found")
+	}
+	var params ResourceParams
+	if err := json.Unmarshal([]byte(revision.Snapshot), &params); err != nil {
+		log.Error("Invalid revision snapshot: ", err)
+		return nil, nil, model.NewInternalServerError("Invalid revision snapshot")
+	}
+	return revision, &params, nil
+}
+
+func GetResourceRevision(c ctx.Context, resourceID, revisionID uint) (*ResourceRevisionDetail, error) {
+	revision, params, err := getResourceRevision(c, resourceID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	return &ResourceRevisionDetail{
+		ResourceRevisionView: revision.ToView(),
+		Snapshot:             *params,
+	}, nil
+}
+
+// DiffResourceRevisions compares two revisions of a resource field by field.
+func DiffResourceRevisions(c ctx.Context, resourceID, fromID, toID uint) ([]RevisionFieldDiff, error) {
+	_, from, err := getResourceRevision(c, resourceID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	_, to, err := getResourceRevision(c, resourceID, toID)
+	if err != nil {
+		return nil, err
+	}
+	return diffResourceParams(from, to), nil
+}
+
+func diffResourceParams(from, to *ResourceParams) []RevisionFieldDiff {
+	fields := []struct {
+		name     string
+		old, new any
+	}{
+		{"title", from.Title, to.Title},
+		{"alternative_titles", from.AlternativeTitles, to.AlternativeTitles},
+		{"links", from.Links, to.Links},
+		{"release_date", from.ReleaseDate, to.ReleaseDate},
+		{"tags", from.Tags, to.Tags},
+		{"images", from.Images, to.Images},
+		{"cover_id", from.CoverID, to.CoverID},
+		{"gallery", from.Gallery, to.Gallery},
+		{"gallery_nsfw", from.GalleryNsfw, to.GalleryNsfw},
+		{"characters", from.Characters, to.Characters},
+	}
+	diffs := make([]RevisionFieldDiff, 0)
+	for _, f := range fields {
+		if !sameJSON(f.old, f.new) {
+			diffs = append(diffs, RevisionFieldDiff{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	if from.Article != to.Article {
+		diffs = append(diffs, RevisionFieldDiff{
+			Field: "article",
+			Lines: utils.DiffLines(from.Article, to.Article),
+		})
+	}
+	return diffs
+}
+
+// sameJSON compares two values by their JSON encoding, so that nil and empty slices are equal.
+func sameJSON(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return normalizeEmptyJSON(string(x)) == normalizeEmptyJSON(string(y))
+}
+
+func normalizeEmptyJSON(s string) string {
+	if s == "[]" || s == `""` || s == "0" {
+		return "null"
+	}
+	return s
+}
+
+// RollbackResource restores a resource to the state of a revision. The rollback is
+// applied as a normal update, so it is recorded as a new revision itself.
+func RollbackResource(c ctx.Context, resourceID, revisionID uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	_, params, err := getResourceRevision(c, resourceID, revisionID)
+	if err != nil {
+		return err
+	}
+
+	// Images and tags may have been deleted since the revision was recorded
+	imageIDs := slices.Clone(params.Images)
+	for _, character := range params.Characters {
+		if character.Image != 0 {
+			imageIDs = append(imageIDs, character.Image)
+		}
+	}
+	images, err := dao.FilterExistingImageIDs(imageIDs)
+	if err != nil {
+		return err
+	}
+	params.Images = slices.DeleteFunc(params.Images, func(id uint) bool {
+		return !slices.Contains(images, id)
+	})
+	tags, err := dao.FilterExistingTagIDs(params.Tags)
+	if err != nil {
+		return err
+	}
+	params.Tags = slices.DeleteFunc(params.Tags, func(id uint) bool {
+		return !slices.Contains(tags, id)
+	})
+	if params.CoverID != nil && !slices.Contains(params.Images, *params.CoverID) {
+		params.CoverID = nil
+	}
+	for i := range params.Characters {
+		if !slices.Contains(images, params.Characters[i].Image) {
+			params.Characters[i].Image = 0
+		}
+	}
+	return UpdateResource(c, resourceID, params)
+}
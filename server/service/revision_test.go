@@ -0,0 +1,46 @@
+package service
+
+import (
+	"nysoure/server/model"
+	"nysoure/server/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDiffResourceParams(t *testing.T) {
+	releaseDate := time.Date(2002, 8, 29, 0, 0, 0, 0, time.UTC)
+	imageID := uint(3)
+	r := &model.Resource{
+		Title:       "Ever17",
+		ReleaseDate: &releaseDate,
+		Article:     "line 1\nline 2\nline 3",
+		Tags:        []model.Tag{{Model: gorm.Model{ID: 1}}, {Model: gorm.Model{ID: 2}}},
+		Characters:  []model.Character{{Name: "小町つぐみ", Role: "primary", ImageID: &imageID}},
+	}
+	from := resourceToParams(r)
+	assert.Equal(t, "2002-08-29", from.ReleaseDate)
+	assert.Equal(t, []uint{1, 2}, from.Tags)
+	assert.Equal(t, imageID, from.Characters[0].Image)
+
+	to := from
+	to.AlternativeTitles = []string{}
+	to.Tags = []uint{1}
+	to.Article = "line 1\nline two\nline 3\nline 4"
+	diffs := diffResourceParams(&from, &to)
+	if assert.Len(t, diffs, 2) {
+		assert.Equal(t, "tags", diffs[0].Field)
+		assert.Equal(t, "article", diffs[1].Field)
+		assert.Equal(t, []utils.DiffLine{
+			{Op: utils.DiffEqual, Text: "line 1"},
+			{Op: utils.DiffDelete, Text: "line 2"},
+			{Op: utils.DiffInsert, Text: "line two"},
+			{Op: utils.DiffEqual, Text: "line 3"},
+			{Op: utils.DiffInsert, Text: "line 4"},
+		}, diffs[1].Lines)
+	}
+
+	assert.Empty(t, diffResourceParams(&from, &from))
+}
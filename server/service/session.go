@@ -0,0 +1,243 @@
+package service
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	// SessionLifetime is how long a session lasts without being refreshed.
+	SessionLifetime = 30 * 24 * time.Hour
+	// sessionTouchInterval limits how often the last seen time of a session is written.
+	sessionTouchInterval = time.Minute
+	maxUserAgentLength   = 512
+	// refreshReuseGrace is how long the replaced refresh token is still accepted, without
+	// being rotated again, for requests which were sent before the client got the new one.
+	refreshReuseGrace = 30 * time.Second
+)
+
+// newSession signs a user in on a new device and returns the tokens of the session.
+func newSession(user model.User, ip, userAgent string) (model.UserViewWithToken, error) {
+	if err := dao.DeleteExpiredSessions(user.ID); err != nil {
+		log.Error("DeleteExpiredSessions error: ", err)
+	}
+	refreshToken, hash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return model.UserViewWithToken{}, err
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now()
+	session := model.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		Device:           deviceName(userAgent),
+		IP:               ip,
+		UserAgent:        userAgent,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(SessionLifetime),
+	}
+	if err := dao.CreateSession(&session); err != nil {
+		log.Error("CreateSession error: ", err)
+		return model.UserViewWithToken{}, model.NewInternalServerError("Failed to create session")
+	}
+	token, err := utils.GenerateToken(user.ID, session.ID)
+	if err != nil {
+		return model.UserViewWithToken{}, err
+	}
+	view := userWithToken(user, token)
+	view.RefreshToken = refreshToken
+	return view, nil
+}
+
+// RefreshSession returns a new access token and a new refresh token for the session
+// of a refresh token and extends the session. The old refresh token stops working.
+// If it is presented again after refreshReuseGrace, it has been stolen from one of the
+// two holders, and the session is revoked to sign both out.
+func RefreshSession(refreshToken, ip string) (model.UserViewWithToken, error) {
+	if refreshToken == "" {
+		return model.UserViewWithToken{}, model.NewUnAuthorizedError("Refresh token is required")
+	}
+	hash := utils.HashToken(refreshToken)
+	rotate := true
+	session, err := dao.GetSessionByRefreshToken(hash)
+	if model.IsNotFoundError(err) {
+		session, err = dao.GetSessionByPreviousRefreshToken(hash)
+		if model.IsNotFoundError(err) {
+			return model.UserViewWithToken{}, model.NewUnAuthorizedError("Invalid refresh token")
+		}
+		if err != nil {
+			return model.UserViewWithToken{}, err
+		}
+		if session.RotatedAt == nil || time.Since(*session.RotatedAt) > refreshReuseGrace {
+			log.Warnf("Refresh token of session %d was reused, revoking the session", session.ID)
+			if err := dao.DeleteUserSession(session.UserID, session.ID); err != nil && !model.IsNotFoundError(err) {
+				log.Error("DeleteUserSession error: ", err)
+			}
+			return model.UserViewWithToken{}, model.NewUnAuthorizedError("Invalid refresh token")
+		}
+		// Another request of the same client has just refreshed the session
+		rotate = false
+	}
+	if err != nil {
+		return model.UserViewWithToken{}, err
+	}
+	user, err := dao.GetUserByID(session.UserID)
+	if model.IsNotFoundError(err) {
+		return model.UserViewWithToken{}, model.NewUnAuthorizedError("Invalid refresh token")
+	}
+	if err != nil {
+		return model.UserViewWithToken{}, err
+	}
+	newRefreshToken := ""
+	if rotate {
+		var newHash string
+		newRefreshToken, newHash, err = utils.GenerateRefreshToken()
+		if err != nil {
+			return model.UserViewWithToken{}, err
+		}
+		rotated, err := dao.RotateRefreshToken(session.ID, hash, newHash, ip, time.Now().Add(SessionLifetime))
+		if err != nil {
+			log.Error("RotateRefreshToken error: ", err)
+			return model.UserViewWithToken{}, model.NewInternalServerError("Failed to refresh session")
+		}
+		if !rotated {
+			// A concurrent refresh won, its response carries the new refresh token
+			newRefreshToken = ""
+		}
+	} else if err := dao.TouchSession(session.ID, ip, nil); err != nil {
+		log.Error("TouchSession error: ", err)
+	}
+	token, err := utils.GenerateToken(user.ID, session.ID)
+	if err != nil {
+		return model.UserViewWithToken{}, err
+	}
+	view := userWithToken(user, token)
+	view.RefreshToken = newRefreshToken
+	return view, nil
+}
+
+// AuthenticateSession checks that the session of an access token has not been revoked
+// and records that it was used.
+func AuthenticateSession(userID, sessionID uint, ip string) error {
+	session, err := dao.GetSession(sessionID)
+	if model.IsNotFoundError(err) {
+		return model.NewUnAuthorizedError("Session has been revoked")
+	}
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return model.NewUnAuthorizedError("Invalid token")
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval || session.IP != ip {
+		if err := dao.TouchSession(session.ID, ip, nil); err != nil {
+			log.Error("TouchSession error: ", err)
+		}
+	}
+	return nil
+}
+
+func ListSessions(c ctx.Context) ([]model.SessionView, error) {
+	if !c.LoggedIn() {
+		return nil, model.NewUnAuthorizedError("You must be logged in to list your sessions")
+	}
+	sessions, err := dao.ListUserSessions(c.MustUserID())
+	if err != nil {
+		log.Error("ListUserSessions error: ", err)
+		return nil, model.NewInternalServerError("Failed to list sessions")
+	}
+	current, _ := c.SessionID()
+	views := make([]model.SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, s.ToView(current))
+	}
+	return views, nil
+}
+
+// RevokeSession signs one of the devices of the user out.
+func RevokeSession(c ctx.Context, id uint) error {
+	if !c.LoggedIn() {
+		return model.NewUnAuthorizedError("You must be logged in to revoke a session")
+	}
+	return dao.DeleteUserSession(c.MustUserID(), id)
+}
+
+// Logout revokes the session of the request, if there is one.
+func Logout(c ctx.Context) error {
+	sid, ok := c.SessionID()
+	if !c.LoggedIn() || !ok {
+		return nil
+	}
+	err := dao.DeleteUserSession(c.MustUserID(), sid)
+	if model.IsNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
+// LogoutEverywhere revokes all sessions of the user, including the current one.
+func LogoutEverywhere(c ctx.Context) error {
+	if !c.LoggedIn() {
+		return model.NewUnAuthorizedError("You must be logged in to log out")
+	}
+	if err := dao.DeleteUserSessions(c.MustUserID()); err != nil {
+		log.Error("DeleteUserSessions error: ", err)
+		return model.NewInternalServerError("Failed to revoke sessions")
+	}
+	return nil
+}
+
+// deviceName describes the browser and the system of a user agent, such as "Firefox on Windows".
+func deviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	browser := ""
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	case strings.Contains(ua, "python"):
+		browser = "Python"
+	}
+	system := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		system = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		system = "iOS"
+	case strings.Contains(ua, "windows"):
+		system = "Windows"
+	case strings.Contains(ua, "mac os"):
+		system = "macOS"
+	case strings.Contains(ua, "cros"):
+		system = "ChromeOS"
+	case strings.Contains(ua, "linux"):
+		system = "Linux"
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/search"
+	"nysoure/server/searchql"
+	"nysoure/server/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	maxSubscriptionsPerUser   = 50
+	maxSubscriptionNameLength = 50
+)
+
+type SubscriptionParams struct {
+	Kind model.SubscriptionKind `json:"kind"`
+	// Name and Query are used for saved searches.
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// TagID is used for followed tags.
+	TagID uint `json:"tag_id"`
+	// Uploader is the username of a followed uploader.
+	Uploader string `json:"uploader"`
+}
+
+func CreateSubscription(c ctx.Context, params *SubscriptionParams) (*model.SubscriptionView, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil, model.NewUnAuthorizedError("You must be logged in")
+	}
+	count, err := dao.CountUserSubscriptions(uid)
+	if err != nil {
+		log.Error("CountUserSubscriptions error: ", err)
+		return nil, model.NewInternalServerError("Failed to create subscription")
+	}
+	if count >= maxSubscriptionsPerUser {
+		return nil, model.NewRequestError("Too many subscriptions")
+	}
+
+	s := &model.Subscription{UserID: uid, Kind: params.Kind}
+	switch params.Kind {
+	case model.SubscriptionSearch:
+		s.Query = strings.TrimSpace(params.Query)
+		if s.Query == "" {
+			return nil, model.NewRequestError("Search query is empty")
+		}
+		if len([]rune(s.Query)) > maxSearchQueryLength {
+			return nil, model.NewRequestError("Search query is too long")
+		}
+		if _, err := searchql.Parse(s.Query); err != nil {
+			return nil, err
+		}
+		s.Name = strings.TrimSpace(params.Name)
+		if s.Name == "" {
+			s.Name = s.Query
+		}
+		if len([]rune(s.Name)) > maxSubscriptionNameLength {
+			return nil, model.NewRequestError("Name is too long")
+		}
+	case model.SubscriptionTag:
+		tag, err := dao.GetTagByID(params.TagID)
+		if err != nil {
+			return nil, err
+		}
+		// Aliases are followed as the tag they belong to
+		if tag.AliasOf != nil {
+			tag.ID = *tag.AliasOf
+		}
+		s.TargetID = tag.ID
+	case model.SubscriptionUploader:
+		user, err := dao.GetUserByUsername(params.Uploader)
+		if err != nil {
+			return nil, err
+		}
+		if user.ID == uid {
+			return nil, model.NewRequestError("You cannot follow yourself")
+		}
+		s.TargetID = user.ID
+	default:
+		return nil, model.NewRequestError("Invalid subscription kind")
+	}
+
+	exists, err := dao.ExistsSubscription(uid, s.Kind, s.TargetID, s.Query)
+	if err != nil {
+		log.Error("ExistsSubscription error: ", err)
+		return nil, model.NewInternalServerError("Failed to create subscription")
+	}
+	if exists {
+		return nil, model.NewRequestError("You are already subscribed")
+	}
+	if err := dao.CreateSubscription(s); err != nil {
+		log.Error("CreateSubscription error: ", err)
+		return nil, model.NewInternalServerError("Failed to create subscription")
+	}
+	return newSubscriptionView(s)
+}
+
+func ListSubscriptions(c ctx.Context) ([]model.SubscriptionView, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil, model.NewUnAuthorizedError("You must be logged in")
+	}
+	subscriptions, err := dao.ListUserSubscriptions(uid)
+	if err != nil {
+		log.Error("ListUserSubscriptions error: ", err)
+		return nil, model.NewInternalServerError("Failed to list subscriptions")
+	}
+	views := make([]model.SubscriptionView, 0, len(subscriptions))
+	for i := range subscriptions {
+		view, err := newSubscriptionView(&subscriptions[i])
+		if model.IsNotFoundError(err) {
+			// The tag was removed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, *view)
+	}
+	return views, nil
+}
+
+func DeleteSubscription(c ctx.Context, id uint) error {
+	uid, ok := c.UserID()
+	if !ok {
+		return model.NewUnAuthorizedError("You must be logged in")
+	}
+	s, err := dao.GetSubscriptionByID(id)
+	if err != nil {
+		return err
+	}
+	if s.UserID != uid {
+		return model.NewNotFoundError("Subscription not found")
+	}
+	if err := dao.DeleteSubscription(id); err != nil {
+		log.Error("DeleteSubscription error: ", err)
+		return model.NewInternalServerError("Failed to delete subscription")
+	}
+	return nil
+}
+
+func newSubscriptionView(s *model.Subscription) (*model.SubscriptionView, error) {
+	view := s.ToView()
+	switch s.Kind {
+	case model.SubscriptionTag:
+		tag, err := dao.GetTagByID(s.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		view.Tag = tag.ToView()
+	case model.SubscriptionUploader:
+		user, err := dao.GetUserByID(s.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		uv := user.ToView()
+		view.Uploader = &uv
+	}
+	return view, nil
+}
+
+// GetSubscriptionFeed lists the new resources and files matching the subscriptions of the user,
+// newest first. Unless all is set, only those since the user last visited the feed are listed.
+func GetSubscriptionFeed(c ctx.Context, all bool, page int) ([]model.ActivityView, int, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return nil, 0, model.NewUnAuthorizedError("You must be logged in")
+	}
+	if page < 1 {
+		page = 1
+	}
+	user, err := dao.GetUserByID(uid)
+	if err != nil {
+		return nil, 0, err
+	}
+	since := user.FeedVisitedAt
+	if all {
+		since = nil
+	}
+	prefs := contentPreferences(c)
+	activities, total, err := dao.GetSubscriptionFeed(uid, since, (page-1)*pageSize, pageSize, newContentFilter(prefs))
+	if err != nil {
+		log.Error("GetSubscriptionFeed error: ", err)
+		return nil, 0, model.NewInternalServerError("Failed to get feed")
+	}
+	views := make([]model.ActivityView, 0, len(activities))
+	for _, activity := range activities {
+		view, err := newActivityView(activity)
+		if err != nil {
+			return nil, 0, err
+		}
+		views = append(views, *view)
+	}
+	blurActivityViews(views, prefs)
+	totalPages := (total + pageSize - 1) / pageSize
+	return views, totalPages, nil
+}
+
+// VisitSubscriptionFeed marks the feed of the user as read.
+func VisitSubscriptionFeed(c ctx.Context) error {
+	uid, ok := c.UserID()
+	if !ok {
+		return model.NewUnAuthorizedError("You must be logged in")
+	}
+	if err := dao.SetFeedVisitedAt(uid, time.Now()); err != nil {
+		log.Error("SetFeedVisitedAt error: ", err)
+		return model.NewInternalServerError("Failed to update feed")
+	}
+	return nil
+}
+
+// notifySubscribers notifies the users with a subscription matching a resource of a new resource,
+// or of a new file on it if fileID is not zero. The user who created it is not notified.
+func notifySubscribers(userID, resourceID, fileID uint) {
+	users, err := dao.ListResourceFollowers(resourceID)
+	if err != nil {
+		log.Error("ListResourceFollowers error: ", err)
+		return
+	}
+	searches, err := dao.ListSavedSearches()
+	if err != nil {
+		log.Error("ListSavedSearches error: ", err)
+		return
+	}
+	// The same query saved by several users is only run once
+	byQuery := make(map[string][]uint)
+	for _, s := range searches {
+		byQuery[s.Query] = append(byQuery[s.Query], s.UserID)
+	}
+	for query, ids := range byQuery {
+		matched, err := searchMatchesResource(query, resourceID)
+		if err != nil {
+			log.Error("Failed to match saved search: ", err)
+			continue
+		}
+		if matched {
+			users = append(users, ids...)
+		}
+	}
+
+	notifyTo := make([]uint, 0, len(users))
+	for _, id := range utils.RemoveDuplicate(users) {
+		if id != userID {
+			notifyTo = append(notifyTo, id)
+		}
+	}
+	activityType, refID := model.ActivityTypeSubscriptionResource, resourceID
+	if fileID != 0 {
+		activityType, refID = model.ActivityTypeSubscriptionFile, fileID
+	}
+	if err := dao.AddSubscriptionActivities(userID, activityType, refID, notifyTo); err != nil {
+		log.Error("AddSubscriptionActivities error: ", err)
+	}
+}
+
+// searchMatchesResource reports whether a resource matches a search query. Text terms are
+// only matched against the document of the resource, so the result does not depend on
+// how it ranks among the other resources.
+func searchMatchesResource(query string, resourceID uint) (bool, error) {
+	node, err := parseSearchQuery(query)
+	if err != nil || node == nil {
+		return false, err
+	}
+	textHits := make(map[*searchql.Term][]uint)
+	for _, t := range searchql.Terms(node) {
+		if t.Field != searchql.FieldText && t.Field != searchql.FieldTitle {
+			continue
+		}
+		matched, err := search.MatchesTerm(t, resourceID)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			textHits[t] = []uint{resourceID}
+		}
+	}
+	return dao.SearchMatchesResource(node, textHits, resourceID)
+}
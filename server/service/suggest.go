@@ -0,0 +1,141 @@
+package service
+
+import (
+	"cmp"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/search"
+	"nysoure/server/utils"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	maxSuggestQueryLength = 50
+	maxSuggestionsPerKind = 5
+)
+
+// SuggestSearch completes a partially typed search query with titles, tags,
+// characters and uploaders, each ranked by popularity.
+func SuggestSearch(text string) (*model.SearchSuggestions, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, model.NewRequestError("Query is required")
+	}
+	if len([]rune(text)) > maxSuggestQueryLength {
+		return nil, model.NewRequestError("Query is too long")
+	}
+	suggestions := &model.SearchSuggestions{}
+	var err error
+	if suggestions.Titles, err = suggestTitles(text); err != nil {
+		log.Error("Failed to suggest titles: ", err)
+		return nil, model.NewInternalServerError("Failed to get suggestions")
+	}
+	if suggestions.Characters, err = suggestCharacters(text); err != nil {
+		log.Error("Failed to suggest characters: ", err)
+		return nil, model.NewInternalServerError("Failed to get suggestions")
+	}
+	if suggestions.Tags, err = dao.SuggestTags(text, maxSuggestionsPerKind); err != nil {
+		log.Error("Failed to suggest tags: ", err)
+		return nil, model.NewInternalServerError("Failed to get suggestions")
+	}
+	if suggestions.Uploaders, err = dao.SuggestUploaders(text, maxSuggestionsPerKind); err != nil {
+		log.Error("Failed to suggest uploaders: ", err)
+		return nil, model.NewInternalServerError("Failed to get suggestions")
+	}
+	return suggestions, nil
+}
+
+func suggestTitles(text string) ([]model.TitleSuggestion, error) {
+	ids, err := search.SuggestResources(text)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := dao.ListResourceTitles(ids)
+	if err != nil {
+		return nil, err
+	}
+	titles := make([]model.TitleSuggestion, 0, len(resources))
+	for _, r := range resources {
+		// Suggest the title the user is typing, which may be an alternative title
+		title := r.Title
+		for _, t := range append([]string{r.Title}, r.AlternativeTitles...) {
+			if search.ContainsText(t, text) {
+				title = t
+				break
+			}
+		}
+		titles = append(titles, model.TitleSuggestion{
+			ResourceID: r.ID,
+			Title:      title,
+			Views:      r.Views,
+		})
+	}
+	slices.SortFunc(titles, func(a, b model.TitleSuggestion) int {
+		if c := cmp.Compare(b.Views, a.Views); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ResourceID, b.ResourceID)
+	})
+	if len(titles) > maxSuggestionsPerKind {
+		titles = titles[:maxSuggestionsPerKind]
+	}
+	return titles, nil
+}
+
+func suggestCharacters(text string) ([]model.CharacterSuggestion, error) {
+	matches, err := search.SuggestCharacters(text)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ResourceID)
+	}
+	resources, err := dao.ListResourceTitles(utils.RemoveDuplicate(ids))
+	if err != nil {
+		return nil, err
+	}
+	views := make(map[uint]uint, len(resources))
+	for _, r := range resources {
+		views[r.ID] = r.Views
+	}
+
+	// A character is as popular as all resources it appears in
+	type key struct{ name, kind string }
+	byKey := make(map[key]*model.CharacterSuggestion)
+	seen := make(map[key][]uint)
+	for _, m := range matches {
+		k := key{m.Name, model.CharacterSuggestionName}
+		if m.CV {
+			k.kind = model.CharacterSuggestionCV
+		}
+		if slices.Contains(seen[k], m.ResourceID) {
+			continue
+		}
+		seen[k] = append(seen[k], m.ResourceID)
+		if byKey[k] == nil {
+			byKey[k] = &model.CharacterSuggestion{Name: k.name, Kind: k.kind}
+		}
+		byKey[k].Views += views[m.ResourceID]
+	}
+	characters := make([]model.CharacterSuggestion, 0, len(byKey))
+	for _, c := range byKey {
+		characters = append(characters, *c)
+	}
+	slices.SortFunc(characters, func(a, b model.CharacterSuggestion) int {
+		if c := cmp.Compare(b.Views, a.Views); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	if len(characters) > maxSuggestionsPerKind {
+		characters = characters[:maxSuggestionsPerKind]
+	}
+	return characters, nil
+}
@@ -1,9 +1,11 @@
 package service
 
 import (
+	"maps"
 	"nysoure/server/ctx"
 	"nysoure/server/dao"
 	"nysoure/server/model"
+	"nysoure/server/utils"
 	"slices"
 	"strings"
 	"sync"
@@ -16,10 +18,16 @@ const (
 	maxTagLength = 20
 )
 
+// tagCacheTTL bounds how long the tag list of an instance may miss the changes
+// made through other instances.
+const tagCacheTTL = 5 * time.Minute
+
 var (
-	cachedTagList  []model.TagViewWithCount
-	cachedTagTable map[uint]int
-	tagCacheMutex  sync.Mutex
+	cachedTagList []model.TagViewWithCount
+	// cachedTagCounts holds the resource counts of all tags which are not aliases.
+	cachedTagCounts map[uint]int
+	cachedTagAt     time.Time
+	tagCacheMutex   sync.Mutex
 )
 
 func init() {
@@ -84,14 +92,9 @@ func GetTagByName(name string) (*model.TagView, error) {
 }
 
 func SearchTag(name string, mainTag bool) ([]model.TagViewWithCount, error) {
-	if cachedTagList == nil {
-		tagCacheMutex.Lock()
-		defer tagCacheMutex.Unlock()
-		if cachedTagList == nil {
-			if err := updateCachedTagList(); err != nil {
-				return nil, err
-			}
-		}
+	_, counts, err := getCachedTags()
+	if err != nil {
+		return nil, err
 	}
 
 	tags, err := dao.SearchTag(name, mainTag)
@@ -100,15 +103,12 @@ func SearchTag(name string, mainTag bool) ([]model.TagViewWithCount, error) {
 	}
 	var tagViewsWithCount []model.TagViewWithCount
 	for _, t := range tags {
-		count := 0
+		var count int
 		id := t.ID
 		if t.AliasOf != nil {
 			id = *t.AliasOf
 		}
-		i, ok := cachedTagTable[id]
-		if ok {
-			count = cachedTagList[i].ResourceCount
-		}
+		count = counts[id]
 		tagViewsWithCount = append(tagViewsWithCount, *t.ToView().WithCount(count))
 	}
 	slices.SortFunc(tagViewsWithCount, func(a, b model.TagViewWithCount) int {
@@ -121,11 +121,14 @@ func SearchTag(name string, mainTag bool) ([]model.TagViewWithCount, error) {
 }
 
 func DeleteTag(id uint) error {
-	err := updateCachedTagList()
-	if err != nil {
+	if err := dao.DeleteTag(id); err != nil {
+		return err
+	}
+	// The list is refreshed after the deletion so that it no longer has the tag
+	if err := updateCachedTagList(); err != nil {
 		log.Error("Error updating cached tag list:", err)
 	}
-	return dao.DeleteTag(id)
+	return nil
 }
 
 func SetTagInfo(c ctx.Context, id uint, description string, aliasOf *uint, tagType string) (*model.TagView, error) {
@@ -135,6 +138,9 @@ func SetTagInfo(c ctx.Context, id uint, description string, aliasOf *uint, tagTy
 	if aliasOf != nil && *aliasOf == id {
 		return nil, model.NewRequestError("Tag cannot be an alias of itself")
 	}
+	if err := checkTagType(tagType); err != nil {
+		return nil, err
+	}
 	if err := dao.SetTagInfo(id, description, aliasOf, tagType); err != nil {
 		return nil, err
 	}
@@ -155,71 +161,104 @@ func SetTagInfo(c ctx.Context, id uint, description string, aliasOf *uint, tagTy
 	return t.ToView(), nil
 }
 
+// updateCachedTagList recounts the resources of all tags and rebuilds the list of
+// the most used tags of each type.
 func updateCachedTagList() error {
 	tags, err := dao.ListTags()
 	if err != nil {
 		return err
 	}
+	counts, err := dao.CountResourcesOfTags()
+	if err != nil {
+		return err
+	}
+	types, err := dao.ListTagTypes()
+	if err != nil {
+		return err
+	}
+	list := buildTagList(tags, counts, types)
 
+	tagCacheMutex.Lock()
+	defer tagCacheMutex.Unlock()
+	cachedTagList, cachedTagCounts, cachedTagAt = list, counts, time.Now()
+	return nil
+}
+
+// buildTagList lists the 50 most used tags of each type, with the types in the order
+// of the registry followed by unregistered ones.
+func buildTagList(tags []model.Tag, counts map[uint]int, types []model.TagType) []model.TagViewWithCount {
 	// Group tags by type with their resource counts
 	tagsByType := make(map[string][]model.TagViewWithCount)
-
 	for _, tag := range tags {
-		count, err := dao.CountResourcesByTag(tag.ID)
-		if err != nil {
-			return err
-		}
+		count := counts[tag.ID]
 		if count > 0 {
 			tagType := tag.Type
 			if tagType == "" {
 				tagType = "default"
 			}
-			tagWithCount := *tag.ToView().WithCount(int(count))
+			tagWithCount := *tag.ToView().WithCount(count)
 			tagsByType[tagType] = append(tagsByType[tagType], tagWithCount)
 		}
 	}
 
+	typeOrder := make([]string, 0, len(tagsByType))
+	for _, t := range types {
+		if _, ok := tagsByType[t.Key]; ok {
+			typeOrder = append(typeOrder, t.Key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(tagsByType)) {
+		if !slices.Contains(typeOrder, key) {
+			typeOrder = append(typeOrder, key)
+		}
+	}
+
 	// Sort each type by resource count (descending) and keep top 50
-	cachedTagList = make([]model.TagViewWithCount, 0)
-	for _, tagsOfType := range tagsByType {
-		// Sort by resource count in descending order
-		slices.SortFunc(tagsOfType, func(a, b model.TagViewWithCount) int {
+	list := make([]model.TagViewWithCount, 0)
+	for _, tagType := range typeOrder {
+		tagsOfType := tagsByType[tagType]
+		slices.SortStableFunc(tagsOfType, func(a, b model.TagViewWithCount) int {
 			return b.ResourceCount - a.ResourceCount
 		})
-
-		// Keep only top 50 tags for this type
-		limit := 50
-		if len(tagsOfType) < limit {
-			limit = len(tagsOfType)
-		}
-		cachedTagList = append(cachedTagList, tagsOfType[:limit]...)
+		list = append(list, tagsOfType[:min(len(tagsOfType), 50)]...)
 	}
+	return list
+}
 
-	cachedTagTable = make(map[uint]int)
-	for i, tag := range cachedTagList {
-		cachedTagTable[tag.ID] = i
+// getCachedTags returns the cached tag list and counts, refreshing them if they are
+// missing or older than tagCacheTTL.
+func getCachedTags() ([]model.TagViewWithCount, map[uint]int, error) {
+	tagCacheMutex.Lock()
+	list, counts, at := cachedTagList, cachedTagCounts, cachedTagAt
+	tagCacheMutex.Unlock()
+	if list != nil && time.Since(at) < tagCacheTTL {
+		return list, counts, nil
+	}
+	if err := updateCachedTagList(); err != nil {
+		if list != nil {
+			// A stale list is better than none
+			log.Error("Error updating cached tag list:", err)
+			return list, counts, nil
+		}
+		return nil, nil, err
 	}
-
-	return nil
+	tagCacheMutex.Lock()
+	defer tagCacheMutex.Unlock()
+	return cachedTagList, cachedTagCounts, nil
 }
 
 func GetTagList() ([]model.TagViewWithCount, error) {
-	if cachedTagList == nil {
-		tagCacheMutex.Lock()
-		defer tagCacheMutex.Unlock()
-		if cachedTagList == nil {
-			if err := updateCachedTagList(); err != nil {
-				return nil, err
-			}
-		}
-	}
-	return cachedTagList, nil
+	list, _, err := getCachedTags()
+	return list, err
 }
 
 func GetOrCreateTags(c ctx.Context, names []string, tagType string) ([]model.TagView, error) {
 	if c.UserPermission() < model.PermissionUploader {
 		return nil, model.NewUnAuthorizedError("User cannot create tags")
 	}
+	if err := checkTagType(tagType); err != nil {
+		return nil, err
+	}
 	tags := make([]model.TagView, 0, len(names))
 	for _, name := range names {
 		t, err := dao.GetTagByName(name)
@@ -252,6 +291,12 @@ func EditTagAlias(c ctx.Context, tagID uint, aliases []string) (*model.TagView,
 		return nil, model.NewRequestError("Cannot edit aliases of a tag that is an alias of another tag")
 	}
 
+	// Resources tagged with removed aliases are no longer in the group afterwards
+	affected, err := dao.GetResourcesIdWithTag(tagID)
+	if err != nil {
+		return nil, err
+	}
+
 	// trim params
 	for i, alias := range aliases {
 		aliases[i] = strings.TrimSpace(alias)
@@ -295,5 +340,104 @@ func EditTagAlias(c ctx.Context, tagID uint, aliases []string) (*model.TagView,
 		return nil, err
 	}
 
+	// Names of the tags are indexed with the resources
+	current, err := dao.GetResourcesIdWithTag(tagID)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for _, id := range utils.RemoveDuplicate(append(affected, current...)) {
+			reindexResource(id)
+		}
+	}()
+
 	return t.ToView(), updateCachedTagList()
 }
+
+// MergeTags merges a tag into another one. The resources and aliases of the source
+// move to the target, and the source becomes an alias of the target.
+func MergeTags(c ctx.Context, sourceID, targetID uint) (*model.TagView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can merge tags")
+	}
+	source, err := getRootTag(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := getRootTag(targetID)
+	if err != nil {
+		return nil, err
+	}
+	if source.ID == target.ID {
+		return nil, model.NewRequestError("Cannot merge a tag into itself")
+	}
+	// The documents of the resources of both tags list the names of the target, which gains
+	// the source and its aliases
+	affected, err := dao.GetResourcesIdWithTag(source.ID)
+	if err != nil {
+		return nil, err
+	}
+	targetResources, err := dao.GetResourcesIdWithTag(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	affected = utils.RemoveDuplicate(append(affected, targetResources...))
+	if err := dao.MergeTags(source.ID, target.ID); err != nil {
+		if model.IsRequestError(err) {
+			return nil, err
+		}
+		log.Error("MergeTags error: ", err)
+		return nil, model.NewInternalServerError("Failed to merge tags")
+	}
+	afterTagChange(affected)
+	// The resources of the source get the tags implied by the target
+	go applyTagImplications(target.ID)
+	t, err := dao.GetTagByID(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	return t.ToView(), nil
+}
+
+// RenameTag changes the name of a tag. The name must not be used by another tag.
+func RenameTag(c ctx.Context, id uint, name string) (*model.TagView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can rename tags")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, model.NewRequestError("Tag name cannot be empty")
+	}
+	if len([]rune(name)) > maxTagLength {
+		return nil, model.NewRequestError("Tag name too long")
+	}
+	// Names differing only in case would be confused with each other by searches
+	existing, err := dao.GetTagByNameInsensitive(name)
+	if err == nil && existing.ID != id {
+		return nil, model.NewRequestError("Tag name already used by another tag, merge the tags instead")
+	}
+	if err != nil && !model.IsNotFoundError(err) {
+		return nil, err
+	}
+	affected, err := dao.GetResourcesIdWithTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := dao.RenameTag(id, name); err != nil {
+		return nil, err
+	}
+	afterTagChange(affected)
+	return GetTag(id)
+}
+
+// afterTagChange refreshes the tag list and the documents of the resources whose tags changed.
+func afterTagChange(resourceIDs []uint) {
+	if err := updateCachedTagList(); err != nil {
+		log.Error("Error updating cached tag list:", err)
+	}
+	go func() {
+		for _, id := range resourceIDs {
+			reindexResource(id)
+		}
+	}()
+}
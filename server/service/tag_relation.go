@@ -0,0 +1,200 @@
+package service
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"slices"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+// getRootTag returns a tag, or the tag it is an alias of.
+func getRootTag(id uint) (model.Tag, error) {
+	t, err := dao.GetTagByID(id)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	if t.AliasOf != nil {
+		return dao.GetTagByID(*t.AliasOf)
+	}
+	return t, nil
+}
+
+func GetTagRelations(id uint) (*model.TagRelationsView, error) {
+	t, err := getRootTag(id)
+	if err != nil {
+		return nil, err
+	}
+	view := &model.TagRelationsView{Tag: *t.ToView()}
+	if t.ParentID != nil {
+		parent, err := getRootTag(*t.ParentID)
+		if err != nil && !model.IsNotFoundError(err) {
+			return nil, err
+		}
+		if err == nil {
+			view.Parent = parent.ToView()
+		}
+	}
+	children, err := dao.GetTagChildren(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	implies, impliedBy, err := dao.GetImplicationsOfTag(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	view.Children = tagViews(children)
+	view.Implies = tagViews(implies)
+	view.ImpliedBy = tagViews(impliedBy)
+	return view, nil
+}
+
+func tagViews(tags []model.Tag) []model.TagView {
+	views := make([]model.TagView, 0, len(tags))
+	for _, t := range tags {
+		views = append(views, *t.ToView())
+	}
+	return views
+}
+
+// SetTagParent sets the parent of a tag, or removes it if parentID is nil.
+// Resources with the tag get the parent as well.
+func SetTagParent(c ctx.Context, id uint, parentID *uint) (*model.TagRelationsView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can edit the tag hierarchy")
+	}
+	t, err := getRootTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if parentID != nil {
+		parent, err := getRootTag(*parentID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTagEdge(t.ID, parent.ID); err != nil {
+			return nil, err
+		}
+		parentID = &parent.ID
+	}
+	if err := dao.SetTagParent(t.ID, parentID); err != nil {
+		log.Error("SetTagParent error: ", err)
+		return nil, model.NewInternalServerError("Failed to set tag parent")
+	}
+	if parentID != nil {
+		go applyTagImplications(t.ID)
+	}
+	return GetTagRelations(t.ID)
+}
+
+// AddTagImplication adds a rule adding a tag to the resources with another tag,
+// including the resources which already have it.
+func AddTagImplication(c ctx.Context, id uint, impliedID uint) (*model.TagRelationsView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can edit tag implications")
+	}
+	t, err := getRootTag(id)
+	if err != nil {
+		return nil, err
+	}
+	implied, err := getRootTag(impliedID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTagEdge(t.ID, implied.ID); err != nil {
+		return nil, err
+	}
+	if err := dao.AddTagImplication(t.ID, implied.ID); err != nil {
+		log.Error("AddTagImplication error: ", err)
+		return nil, model.NewInternalServerError("Failed to add tag implication")
+	}
+	go applyTagImplications(t.ID)
+	return GetTagRelations(t.ID)
+}
+
+// RemoveTagImplication removes an implication rule. Resources keep the tags it added.
+func RemoveTagImplication(c ctx.Context, id uint, impliedID uint) (*model.TagRelationsView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can edit tag implications")
+	}
+	t, err := getRootTag(id)
+	if err != nil {
+		return nil, err
+	}
+	implied, err := getRootTag(impliedID)
+	if err != nil {
+		return nil, err
+	}
+	if err := dao.RemoveTagImplication(t.ID, implied.ID); err != nil {
+		return nil, err
+	}
+	return GetTagRelations(t.ID)
+}
+
+// checkTagEdge checks that a tag can imply another one, as its parent or by a rule,
+// without creating a cycle.
+func checkTagEdge(from, to uint) error {
+	if from == to {
+		return model.NewRequestError("A tag cannot imply itself")
+	}
+	cycle, err := dao.TagReaches(to, from)
+	if err != nil {
+		log.Error("TagReaches error: ", err)
+		return model.NewInternalServerError("Failed to check the tag graph")
+	}
+	if cycle {
+		return model.NewRequestError("The tag is already implied by the other tag, this would create a cycle")
+	}
+	return nil
+}
+
+// withImpliedTags appends the tags implied by the given tags.
+func withImpliedTags(tagIDs []uint) ([]uint, error) {
+	implied, err := dao.GetImpliedTagIDs(tagIDs)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(tagIDs, implied), nil
+}
+
+// applyTagImplications adds the tags implied by a tag to the resources which have it,
+// or have a tag implying it.
+func applyTagImplications(tagID uint) {
+	ids, err := dao.GetResourcesIdImplyingTag(tagID)
+	if err != nil {
+		log.Error("GetResourcesIdImplyingTag error: ", err)
+		return
+	}
+	for _, id := range ids {
+		r, err := dao.GetResourceByID(id)
+		if model.IsNotFoundError(err) {
+			// Resources in the trash get the tags when they are restored and edited
+			continue
+		}
+		if err != nil {
+			log.Error("GetResourceByID error: ", err)
+			continue
+		}
+		tagIDs := make([]uint, 0, len(r.Tags))
+		for _, t := range r.Tags {
+			tagIDs = append(tagIDs, t.ID)
+		}
+		implied, err := dao.GetImpliedTagIDs(tagIDs)
+		if err != nil {
+			log.Error("GetImpliedTagIDs error: ", err)
+			continue
+		}
+		if len(implied) == 0 {
+			continue
+		}
+		if err := dao.AddResourceTags(id, implied); err != nil {
+			log.Error("AddResourceTags error: ", err)
+			continue
+		}
+		reindexResource(id)
+	}
+	if err := updateCachedTagList(); err != nil {
+		log.Error("Error updating cached tag list:", err)
+	}
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"nysoure/server/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestBuildTagList(t *testing.T) {
+	tags := []model.Tag{
+		{Model: gorm.Model{ID: 1}, Name: "Nukige", Type: "genre"},
+		{Model: gorm.Model{ID: 2}, Name: "Key", Type: "developer"},
+		{Model: gorm.Model{ID: 3}, Name: "Visual Novel", Type: "genre"},
+		{Model: gorm.Model{ID: 4}, Name: "Unused", Type: "genre"},
+		{Model: gorm.Model{ID: 5}, Name: "Other"},
+	}
+	counts := map[uint]int{1: 3, 2: 1, 3: 7, 5: 2}
+	types := []model.TagType{{Key: "genre", Order: 0}, {Key: "developer", Order: 1}}
+
+	list := buildTagList(tags, counts, types)
+	names := make([]string, 0, len(list))
+	for _, tag := range list {
+		names = append(names, tag.Name)
+	}
+	// Registered types first in their order, unused tags are left out
+	assert.Equal(t, []string{"Visual Novel", "Nukige", "Key", "Other"}, names)
+	assert.Equal(t, 7, list[0].ResourceCount)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+var (
+	tagTypeKeyPattern   = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	tagTypeColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+const maxTagTypeNameLength = 32
+
+type TagTypeParams struct {
+	Key         string `json:"key"`
+	DisplayName string `json:"display_name"`
+	Order       int    `json:"order"`
+	Color       string `json:"color"`
+}
+
+func ListTagTypes() ([]model.TagTypeView, error) {
+	types, err := dao.ListTagTypes()
+	if err != nil {
+		log.Error("ListTagTypes error: ", err)
+		return nil, model.NewInternalServerError("Failed to list tag types")
+	}
+	views := make([]model.TagTypeView, 0, len(types))
+	for _, t := range types {
+		views = append(views, t.ToView())
+	}
+	return views, nil
+}
+
+func CreateTagType(c ctx.Context, params *TagTypeParams) (*model.TagTypeView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can manage tag types")
+	}
+	if !tagTypeKeyPattern.MatchString(params.Key) {
+		return nil, model.NewRequestError("Key must be 1 to 32 lowercase letters, digits or underscores")
+	}
+	t := &model.TagType{Key: params.Key}
+	if err := setTagTypeParams(t, params); err != nil {
+		return nil, err
+	}
+	if _, err := dao.GetTagType(t.Key); err == nil {
+		return nil, model.NewRequestError("Tag type already exists")
+	} else if !model.IsNotFoundError(err) {
+		return nil, err
+	}
+	if err := dao.CreateTagType(t); err != nil {
+		log.Error("CreateTagType error: ", err)
+		return nil, model.NewInternalServerError("Failed to create tag type")
+	}
+	view := t.ToView()
+	return &view, nil
+}
+
+func UpdateTagType(c ctx.Context, key string, params *TagTypeParams) (*model.TagTypeView, error) {
+	if c.UserPermission() < model.PermissionAdmin {
+		return nil, model.NewUnAuthorizedError("Only admins can manage tag types")
+	}
+	t, err := dao.GetTagType(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := setTagTypeParams(t, params); err != nil {
+		return nil, err
+	}
+	if err := dao.UpdateTagType(t); err != nil {
+		log.Error("UpdateTagType error: ", err)
+		return nil, model.NewInternalServerError("Failed to update tag type")
+	}
+	if err := updateCachedTagList(); err != nil {
+		log.Error("Error updating cached tag list:", err)
+	}
+	view := t.ToView()
+	return &view, nil
+}
+
+// DeleteTagType deletes a tag type which no tag uses.
+func DeleteTagType(c ctx.Context, key string) error {
+	if c.UserPermission() < model.PermissionAdmin {
+		return model.NewUnAuthorizedError("Only admins can manage tag types")
+	}
+	if _, err := dao.GetTagType(key); err != nil {
+		return err
+	}
+	count, err := dao.CountTagsOfType(key)
+	if err != nil {
+		log.Error("CountTagsOfType error: ", err)
+		return model.NewInternalServerError("Failed to delete tag type")
+	}
+	if count > 0 {
+		return model.NewRequestError("The tag type is still used by tags")
+	}
+	if err := dao.DeleteTagType(key); err != nil {
+		log.Error("DeleteTagType error: ", err)
+		return model.NewInternalServerError("Failed to delete tag type")
+	}
+	return nil
+}
+
+func setTagTypeParams(t *model.TagType, params *TagTypeParams) error {
+	name := strings.TrimSpace(params.DisplayName)
+	if name == "" {
+		return model.NewRequestError("Display name cannot be empty")
+	}
+	if len([]rune(name)) > maxTagTypeNameLength {
+		return model.NewRequestError("Display name too long")
+	}
+	if params.Color != "" && !tagTypeColorPattern.MatchString(params.Color) {
+		return model.NewRequestError("Color must be a hex color such as #ff8800")
+	}
+	t.DisplayName = name
+	t.Order = params.Order
+	t.Color = strings.ToLower(params.Color)
+	return nil
+}
+
+// checkTagType checks that a tag type is registered. An empty type means no type.
+func checkTagType(key string) error {
+	if key == "" {
+		return nil
+	}
+	if _, err := dao.GetTagType(key); err != nil {
+		if model.IsNotFoundError(err) {
+			return model.NewRequestError("Unknown tag type: " + key)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"nysoure/server/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTagTypeParams(t *testing.T) {
+	tt := &model.TagType{Key: "developer"}
+	err := setTagTypeParams(tt, &TagTypeParams{DisplayName: " Developer ", Order: 2, Color: "#FF8800"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Developer", tt.DisplayName)
+	assert.Equal(t, 2, tt.Order)
+	assert.Equal(t, "#ff8800", tt.Color)
+
+	assert.Error(t, setTagTypeParams(tt, &TagTypeParams{DisplayName: " "}))
+	assert.Error(t, setTagTypeParams(tt, &TagTypeParams{DisplayName: "Developer", Color: "orange"}))
+
+	assert.True(t, tagTypeKeyPattern.MatchString("voice_actor"))
+	assert.False(t, tagTypeKeyPattern.MatchString("Voice Actor"))
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"nysoure/server/config"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/storage"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+func init() {
+	// Start a goroutine to purge expired items from the trash every hour
+	go func() {
+		// Wait for 1 minute to ensure the database is ready
+		time.Sleep(time.Minute)
+		for {
+			purgeTrash()
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+func purgeTrash() {
+	before := time.Now().Add(-config.TrashRetention())
+	files, err := dao.ListExpiredTrashedFiles(before)
+	if err != nil {
+		log.Errorf("Failed to list expired files in trash: %v", err)
+	}
+	for _, f := range files {
+		if err := purgeFile(&f); err != nil {
+			log.Errorf("Failed to purge file %s: %v", f.UUID, err)
+		}
+	}
+	ids, err := dao.ListExpiredTrashedResources(before)
+	if err != nil {
+		log.Errorf("Failed to list expired resources in trash: %v", err)
+	}
+	for _, id := range ids {
+		if err := dao.PurgeResource(id); err != nil {
+			log.Errorf("Failed to purge resource %d: %v", id, err)
+		}
+	}
+}
+
+// purgeFile deletes the storage object of a file in the trash, then the file itself.
+func purgeFile(f *model.File) error {
+	if f.StorageID != nil && f.StorageKey != storageKeyUnavailable {
+		iStorage := storage.NewStorage(f.Storage)
+		if iStorage != nil {
+			if err := iStorage.Delete(f.StorageKey); err != nil {
+				return err
+			}
+			if err := dao.AddStorageUsage(*f.StorageID, -f.Size); err != nil {
+				log.Error("failed to update storage usage: ", err)
+			}
+		}
+	}
+	return dao.PurgeFile(f.UUID)
+}
+
+// trashOwnerFilter returns the user whose items are shown in the trash. Admins see all items.
+func trashOwnerFilter(c ctx.Context) (uint, error) {
+	uid, ok := c.UserID()
+	if !ok {
+		return 0, model.NewUnAuthorizedError("You must be logged in")
+	}
+	if c.UserPermission() == model.PermissionAdmin {
+		return 0, nil
+	}
+	return uid, nil
+}
+
+func checkTrashPermission(c ctx.Context, owner uint) error {
+	uid := c.MustUserID()
+	if owner != uid && c.UserPermission() != model.PermissionAdmin {
+		return model.NewUnAuthorizedError("You have not permission to restore this item")
+	}
+	return nil
+}
+
+func ListTrashedResources(c ctx.Context, page int) ([]model.TrashedResourceView, int, error) {
+	owner, err := trashOwnerFilter(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	resources, totalPages, err := dao.ListTrashedResources(owner, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	retention := config.TrashRetention()
+	views := make([]model.TrashedResourceView, len(resources))
+	for i := range resources {
+		views[i] = resources[i].ToTrashedView(retention)
+	}
+	return views, totalPages, nil
+}
+
+func RestoreResource(c ctx.Context, id uint) error {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return err
+	}
+	r, err := dao.GetTrashedResource(id)
+	if err != nil {
+		return err
+	}
+	if err := checkTrashPermission(c, r.UserID); err != nil {
+		return err
+	}
+	if err := dao.RestoreResource(id); err != nil {
+		return err
+	}
+	if err := updateCachedTagList(); err != nil {
+		log.Error("Error updating cached tag list:", err)
+	}
+	reindexResource(id)
+	return nil
+}
+
+func ListTrashedFiles(c ctx.Context, page int) ([]model.TrashedFileView, int, error) {
+	owner, err := trashOwnerFilter(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	files, totalPages, err := dao.ListTrashedFiles(owner, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	retention := config.TrashRetention()
+	views := make([]model.TrashedFileView, len(files))
+	for i := range files {
+		views[i] = files[i].ToTrashedView(retention)
+	}
+	return views, totalPages, nil
+}
+
+func RestoreFile(c ctx.Context, fid string) error {
+	if err := requireScope(c, model.ScopeFileUpload); err != nil {
+		return err
+	}
+	f, err := dao.GetTrashedFile(fid)
+	if err != nil {
+		return err
+	}
+	if err := checkTrashPermission(c, f.UserID); err != nil {
+		return err
+	}
+	if _, err := dao.GetResourceOwnerID(f.ResourceID); err != nil {
+		if model.IsNotFoundError(err) {
+			return model.NewRequestError("The resource of this file is deleted, please restore it first")
+		}
+		return err
+	}
+	if err := dao.RestoreFile(fid); err != nil {
+		return err
+	}
+	reindexResource(f.ResourceID)
+	return nil
+}
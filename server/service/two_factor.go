@@ -0,0 +1,267 @@
+package service
+
+import (
+	"crypto/rand"
+	"nysoure/server/config"
+	"nysoure/server/ctx"
+	"nysoure/server/dao"
+	"nysoure/server/model"
+	"nysoure/server/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// challengeLifetime is how long a user has to give their code after their password.
+	challengeLifetime = 5 * time.Minute
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out characters which are easily confused.
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// twoFactorRequired reports whether the config requires users with a permission
+// to use two-factor authentication.
+func twoFactorRequired(p model.Permission) bool {
+	if p >= model.PermissionAdmin && config.RequireTwoFactorForAdmins() {
+		return true
+	}
+	return p >= model.PermissionUploader && config.RequireTwoFactorForUploaders()
+}
+
+// effectivePermission limits users who must use two-factor authentication, but have
+// not enabled it, to the permissions of verified users until they do.
+func effectivePermission(uid uint, p model.Permission) (model.Permission, error) {
+	if !twoFactorRequired(p) {
+		return p, nil
+	}
+	enabled, err := dao.IsTwoFactorEnabled(uid)
+	if err != nil {
+		return model.PermissionNone, err
+	}
+	if !enabled {
+		return model.PermissionVerified, nil
+	}
+	return p, nil
+}
+
+func userWithToken(user model.User, token string) model.UserViewWithToken {
+	view := user.ToView().WithToken(token)
+	view.TwoFactorEnabled = user.TwoFactorEnabled
+	view.TwoFactorRequired = twoFactorRequired(user.Permission)
+	return view
+}
+
+func newTwoFactorChallenge(user model.User) (*model.TwoFactorChallenge, error) {
+	token, err := utils.GenerateChallengeToken(user.ID, challengeLifetime)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TwoFactorChallenge{
+		ChallengeToken: token,
+		ExpiresAt:      time.Now().Add(challengeLifetime),
+	}, nil
+}
+
+// LoginTwoFactor completes the login of a user with two-factor authentication,
+// with a code of their authenticator app or a recovery code.
+func LoginTwoFactor(challengeToken, code, ip, userAgent string) (model.UserViewWithToken, error) {
+	uid, err := utils.ParseChallengeToken(challengeToken)
+	if err != nil {
+		return model.UserViewWithToken{}, model.NewUnAuthorizedError("Invalid or expired challenge, please log in again")
+	}
+	user, err := dao.GetUserByID(uid)
+	if err != nil {
+		if model.IsNotFoundError(err) {
+			return model.UserViewWithToken{}, model.NewUnAuthorizedError("Invalid or expired challenge, please log in again")
+		}
+		return model.UserViewWithToken{}, err
+	}
+	if isUserLocked(user.Username) {
+		return model.UserViewWithToken{}, model.NewRequestError("User is temporarily locked due to too many failed login attempts")
+	}
+	ok, err := checkTwoFactorCode(&user, code)
+	if err != nil {
+		return model.UserViewWithToken{}, err
+	}
+	if !ok {
+		addLoginAttempt(user.Username)
+		return model.UserViewWithToken{}, model.NewRequestError("Invalid code")
+	}
+	resetLoginAttempts(user.Username)
+	return newSession(user, ip, userAgent)
+}
+
+// checkTwoFactorCode checks a code of the authenticator app of a user, or one of their
+// recovery codes. Accepted codes are consumed by a conditional update, so that a code
+// cannot be used twice, even by concurrent requests.
+func checkTwoFactorCode(user *model.User, code string) (bool, error) {
+	if !user.TwoFactorEnabled {
+		return false, model.NewRequestError("Two-factor authentication is not enabled")
+	}
+	var used bool
+	var err error
+	if step, ok := utils.ValidateTotp(user.TotpSecret, code, time.Now()); ok {
+		used, err = dao.ConsumeTotpStep(user.ID, step)
+	} else {
+		used, err = dao.ConsumeRecoveryCode(user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+	if err != nil {
+		log.Error("Failed to consume two-factor code: ", err)
+		return false, model.NewInternalServerError("Failed to check the code")
+	}
+	return used, nil
+}
+
+// EnrollTwoFactor creates a new secret for the user. It is used once the user
+// verifies a code of their authenticator app.
+func EnrollTwoFactor(c ctx.Context) (*model.TwoFactorEnrollment, error) {
+	if !c.LoggedIn() {
+		return nil, model.NewUnAuthorizedError("You must be logged in to enable two-factor authentication")
+	}
+	user, err := dao.GetUserByID(c.MustUserID())
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, model.NewRequestError("Two-factor authentication is already enabled")
+	}
+	secret, err := utils.GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := dao.SetTotpSecret(user.ID, secret); err != nil {
+		log.Error("SetTotpSecret error: ", err)
+		return nil, model.NewInternalServerError("Failed to enable two-factor authentication")
+	}
+	return &model.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    utils.TotpURI(config.ServerName(), user.Username, secret),
+	}, nil
+}
+
+// VerifyTwoFactor enables two-factor authentication with a first code of the
+// authenticator app, and returns the recovery codes.
+func VerifyTwoFactor(c ctx.Context, code string) ([]string, error) {
+	if !c.LoggedIn() {
+		return nil, model.NewUnAuthorizedError("You must be logged in to enable two-factor authentication")
+	}
+	user, err := dao.GetUserByID(c.MustUserID())
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, model.NewRequestError("Two-factor authentication is already enabled")
+	}
+	if user.TotpSecret == "" {
+		return nil, model.NewRequestError("Two-factor authentication has not been set up")
+	}
+	step, ok := utils.ValidateTotp(user.TotpSecret, code, time.Now())
+	if !ok {
+		return nil, model.NewRequestError("Invalid code")
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := dao.EnableTwoFactor(user.ID, user.TotpSecret, step, hashes)
+	if err != nil {
+		log.Error("EnableTwoFactor error: ", err)
+		return nil, model.NewInternalServerError("Failed to enable two-factor authentication")
+	}
+	if !enabled {
+		return nil, model.NewRequestError("Two-factor authentication was set up again, please verify a new code")
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, with the password of the user
+// and a code. Users required to use it cannot turn it off.
+func DisableTwoFactor(c ctx.Context, password, code string) error {
+	if !c.LoggedIn() {
+		return model.NewUnAuthorizedError("You must be logged in to disable two-factor authentication")
+	}
+	user, err := dao.GetUserByID(c.MustUserID())
+	if err != nil {
+		return err
+	}
+	if twoFactorRequired(user.Permission) {
+		return model.NewRequestError("Two-factor authentication is required for your account")
+	}
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		return model.NewUnAuthorizedError("Invalid password")
+	}
+	ok, err := checkTwoFactorCode(&user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return model.NewRequestError("Invalid code")
+	}
+	if err := dao.DisableTwoFactor(user.ID); err != nil {
+		log.Error("DisableTwoFactor error: ", err)
+		return model.NewInternalServerError("Failed to disable two-factor authentication")
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, with a code of
+// their authenticator app.
+func RegenerateRecoveryCodes(c ctx.Context, code string) ([]string, error) {
+	if !c.LoggedIn() {
+		return nil, model.NewUnAuthorizedError("You must be logged in to get recovery codes")
+	}
+	user, err := dao.GetUserByID(c.MustUserID())
+	if err != nil {
+		return nil, err
+	}
+	ok, err := checkTwoFactorCode(&user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, model.NewRequestError("Invalid code")
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := dao.SetRecoveryCodes(user.ID, hashes); err != nil {
+		log.Error("SetRecoveryCodes error: ", err)
+		return nil, model.NewInternalServerError("Failed to generate recovery codes")
+	}
+	return codes, nil
+}
+
+// generateRecoveryCodes returns new recovery codes, such as "k3m9p-x2a7q", and their hashes.
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		var sb strings.Builder
+		for j, v := range b {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)])
+		}
+		code := sb.String()
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes in any case, with or without the dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) == 10 && !strings.Contains(code, "-") {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
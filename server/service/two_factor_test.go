@@ -0,0 +1,26 @@
+package service
+
+import (
+	"nysoure/server/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	assert.Len(t, hashes, recoveryCodeCount)
+	for i, code := range codes {
+		assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, code)
+		assert.Equal(t, utils.HashToken(code), hashes[i])
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "k3m9p-x2a7q", normalizeRecoveryCode("k3m9p-x2a7q"))
+	assert.Equal(t, "k3m9p-x2a7q", normalizeRecoveryCode(" K3M9P-X2A7Q "))
+	assert.Equal(t, "k3m9p-x2a7q", normalizeRecoveryCode("k3m9p x2a7q"))
+	assert.Equal(t, "k3m9p-x2a7q", normalizeRecoveryCode("K3M9PX2A7Q"))
+}
@@ -77,7 +77,7 @@ func isUserLocked(username string) bool {
 	return true
 }
 
-func CreateUser(username, password, cfToken string) (model.UserViewWithToken, error) {
+func CreateUser(username, password, cfToken, ip, userAgent string) (model.UserViewWithToken, error) {
 	if !config.AllowRegister() {
 		return model.UserViewWithToken{}, model.NewRequestError("User registration is not allowed")
 	}
@@ -105,37 +105,42 @@ func CreateUser(username, password, cfToken string) (model.UserViewWithToken, er
 	if err != nil {
 		return model.UserViewWithToken{}, err
 	}
-	token, err := utils.GenerateToken(user.ID)
-	if err != nil {
-		return model.UserViewWithToken{}, err
-	}
-	return user.ToView().WithToken(token), nil
+	return newSession(user, ip, userAgent)
 }
 
-func Login(username, password string) (model.UserViewWithToken, error) {
+// Login checks the password of a user and signs them in. Users with two-factor
+// authentication get a challenge instead, to be completed by LoginTwoFactor.
+func Login(username, password, ip, userAgent string) (model.UserViewWithToken, *model.TwoFactorChallenge, error) {
 	if isUserLocked(username) {
-		return model.UserViewWithToken{}, model.NewRequestError("User is temporarily locked due to too many failed login attempts")
+		return model.UserViewWithToken{}, nil, model.NewRequestError("User is temporarily locked due to too many failed login attempts")
 	}
 	user, err := dao.GetUserByUsername(username)
 	if err != nil {
 		if model.IsNotFoundError(err) {
-			return model.UserViewWithToken{}, model.NewRequestError("User not found")
+			return model.UserViewWithToken{}, nil, model.NewRequestError("User not found")
 		}
-		return model.UserViewWithToken{}, err
+		return model.UserViewWithToken{}, nil, err
 	}
 	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
 		addLoginAttempt(username)
-		return model.UserViewWithToken{}, model.NewRequestError("Invalid password")
+		return model.UserViewWithToken{}, nil, model.NewRequestError("Invalid password")
 	}
-	resetLoginAttempts(username)
-	token, err := utils.GenerateToken(user.ID)
-	if err != nil {
-		return model.UserViewWithToken{}, err
+	if user.TwoFactorEnabled {
+		// Failed attempts are kept until the code is given, they count the wrong codes too
+		challenge, err := newTwoFactorChallenge(user)
+		if err != nil {
+			return model.UserViewWithToken{}, nil, err
+		}
+		return model.UserViewWithToken{}, challenge, nil
 	}
-	return user.ToView().WithToken(token), nil
+	resetLoginAttempts(username)
+	view, err := newSession(user, ip, userAgent)
+	return view, nil, err
 }
 
-func ChangePassword(ctx ctx.Context, oldPassword, newPassword string) (model.UserViewWithToken, error) {
+// ChangePassword changes the password of the user and signs all of their devices out.
+// The request gets a new session.
+func ChangePassword(ctx ctx.Context, oldPassword, newPassword, ip, userAgent string) (model.UserViewWithToken, error) {
 	if !ctx.LoggedIn() {
 		return model.UserViewWithToken{}, model.NewUnAuthorizedError("You must be logged in to change your password")
 	}
@@ -155,11 +160,11 @@ func ChangePassword(ctx ctx.Context, oldPassword, newPassword string) (model.Use
 	if err := dao.UpdateUser(user); err != nil {
 		return model.UserViewWithToken{}, err
 	}
-	token, err := utils.GenerateToken(user.ID)
-	if err != nil {
-		return model.UserViewWithToken{}, err
+	if err := dao.DeleteUserSessions(user.ID); err != nil {
+		log.Error("DeleteUserSessions error: ", err)
+		return model.UserViewWithToken{}, model.NewInternalServerError("Failed to revoke sessions")
 	}
-	return user.ToView().WithToken(token), nil
+	return newSession(user, ip, userAgent)
 }
 
 func ChangeAvatar(ctx ctx.Context, imageData []byte) (model.UserView, error) {
@@ -401,6 +406,18 @@ func DeleteUser(ctx ctx.Context, targetUserID uint) error {
 		return err
 	}
 
+	// 4. Delete the subscriptions of the user and those following the user
+	if err := dao.DeleteSubscriptionsOfUser(targetUserID); err != nil {
+		return err
+	}
+
+	if err := dao.DeleteUserSessions(targetUserID); err != nil {
+		return err
+	}
+	if err := dao.DeleteUserAPITokens(targetUserID); err != nil {
+		return err
+	}
+
 	// Finally, delete the user
 	return dao.DeleteUser(targetUserID)
 }
@@ -461,11 +478,16 @@ func GetMe(ctx ctx.Context) (model.UserViewWithToken, error) {
 	if err != nil {
 		return model.UserViewWithToken{}, err
 	}
-	token, err := utils.GenerateToken(user.ID)
+	sid, ok := ctx.SessionID()
+	if !ok {
+		// Requests with an API token get no access token
+		return userWithToken(user, ""), nil
+	}
+	token, err := utils.GenerateToken(user.ID, sid)
 	if err != nil {
 		return model.UserViewWithToken{}, err
 	}
-	return user.ToView().WithToken(token), nil
+	return userWithToken(user, token), nil
 }
 
 func validateUsername(username string) error {
@@ -546,6 +568,16 @@ func UnbanUser(ctx ctx.Context, targetUserID uint) (model.UserView, error) {
 	return targetUser.ToView(), nil
 }
 
-func GetUserPermissionAndCreatedAt(uid uint) (model.Permission, time.Time, error) {
-	return dao.GetUserPermissionAndCreatedAt(uid)
+// GetUserAuthInfo returns the permission in effect, the creation time and the content
+// preferences of a user, for the context of a request.
+func GetUserAuthInfo(uid uint) (model.User, error) {
+	user, err := dao.GetUserAuthInfo(uid)
+	if err != nil {
+		return user, err
+	}
+	user.Permission, err = effectivePermission(uid, user.Permission)
+	if err != nil {
+		return user, err
+	}
+	return user, nil
 }
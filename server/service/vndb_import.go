@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nysoure/server/ctx"
+	"nysoure/server/model"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3/log"
+)
+
+const (
+	// maxVndbScreenshots limits how many screenshots are downloaded for one import.
+	maxVndbScreenshots = 10
+	// vndbNsfwThreshold is the average sexual rating at which an image is treated as NSFW.
+	// VNDB rates images from 0 (safe) to 2 (explicit); suggestive images are flagged too.
+	vndbNsfwThreshold = 1.0
+	// minVndbTagRating is the minimum VNDB tag score for a tag to be suggested.
+	minVndbTagRating = 1.5
+)
+
+var (
+	vndbIDRegexp      = regexp.MustCompile(`^v\d+$`)
+	vndbUrlTagRegexp  = regexp.MustCompile(`\[url=([^\]]+)\]([^\[]*)\[/url\]`)
+	vndbSpoilerRegexp = regexp.MustCompile(`(?s)\[spoiler\].*?\[/spoiler\]`)
+)
+
+type vndbImage struct {
+	URL    string  `json:"url"`
+	Sexual float64 `json:"sexual"`
+}
+
+type vndbVisualNovel struct {
+	ID       string   `json:"id"`
+	Rating   float64  `json:"rating"`
+	Released string   `json:"released"`
+	Title    string   `json:"title"`
+	AltTitle string   `json:"alttitle"`
+	Aliases  []string `json:"aliases"`
+	Titles   []struct {
+		Title    string `json:"title"`
+		Official bool   `json:"official"`
+	} `json:"titles"`
+	Description string      `json:"description"`
+	Image       *vndbImage  `json:"image"`
+	Screenshots []vndbImage `json:"screenshots"`
+	Tags        []struct {
+		Name    string  `json:"name"`
+		Rating  float64 `json:"rating"`
+		Spoiler int     `json:"spoiler"`
+	} `json:"tags"`
+	Extlinks []struct {
+		URL   string `json:"url"`
+		Label string `json:"label"`
+		Name  string `json:"name"`
+	} `json:"extlinks"`
+}
+
+// normalizeVndbID accepts "v123", "123" or a VNDB URL and returns "v123".
+func normalizeVndbID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	id = strings.TrimPrefix(id, "https://vndb.org/")
+	id = strings.TrimSuffix(id, "/")
+	if id != "" && id[0] >= '0' && id[0] <= '9' {
+		id = "v" + id
+	}
+	if !vndbIDRegexp.MatchString(id) {
+		return "", model.NewRequestError("Invalid VNDB ID")
+	}
+	return id, nil
+}
+
+const vndbImportFields = "title, alttitle, aliases, titles.title, titles.official, description, " +
+	"image.url, image.sexual, screenshots.url, screenshots.sexual, " +
+	"tags.name, tags.rating, tags.spoiler, extlinks.url, extlinks.label, extlinks.name"
+
+func fetchVndbVisualNovel(vnID string, fields string) (*vndbVisualNovel, error) {
+	body, err := json.Marshal(map[string]any{
+		"filters": []string{"id", "=", vnID},
+		"fields":  fields,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(vndbApiUrl+"/vn", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, model.NewInternalServerError("Failed to fetch data from VNDB")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, model.NewInternalServerError("Failed to fetch data from VNDB")
+	}
+	var vndbResp struct {
+		Results []vndbVisualNovel `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vndbResp); err != nil {
+		return nil, model.NewInternalServerError("Failed to parse VNDB response")
+	}
+	if len(vndbResp.Results) == 0 {
+		return nil, model.NewNotFoundError("Visual novel not found on VNDB")
+	}
+	return &vndbResp.Results[0], nil
+}
+
+// ImportFromVndb builds a resource draft from a VNDB visual novel.
+// Images are downloaded and stored, but the resource itself is not created.
+func ImportFromVndb(c ctx.Context, vnID string) (*ResourceParams, error) {
+	if err := requireScope(c, model.ScopeResourceWrite); err != nil {
+		return nil, err
+	}
+	if c.UserPermission() < model.PermissionUploader {
+		return nil, model.NewUnAuthorizedError("You have not permission to import resources from VNDB")
+	}
+	vnID, err := normalizeVndbID(vnID)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := newTagMatcher()
+	if err != nil {
+		return nil, err
+	}
+	return importFromVndb(vnID, matcher, downloadAndCreateImage)
+}
+
+func importFromVndb(vnID string, matcher *tagMatcher, download imageDownloader) (*ResourceParams, error) {
+	vn, err := fetchVndbVisualNovel(vnID, vndbImportFields)
+	if err != nil {
+		return nil, err
+	}
+
+	title, alternativeTitles := vndbTitles(vn)
+	params := &ResourceParams{
+		Title:             title,
+		AlternativeTitles: alternativeTitles,
+		Links: []model.Link{
+			{URL: "https://vndb.org/" + vnID, Label: "VNDB"},
+		},
+		Tags:        []uint{},
+		Article:     convertVndbDescription(vn.Description),
+		Images:      []uint{},
+		Gallery:     []uint{},
+		GalleryNsfw: []uint{},
+	}
+
+	for _, l := range vn.Extlinks {
+		switch {
+		case l.Name == "website":
+			params.Links = append(params.Links, model.Link{URL: l.URL, Label: "Official"})
+		case strings.HasPrefix(l.URL, "https://store.steampowered.com/app/"):
+			params.Links = append(params.Links, model.Link{URL: l.URL, Label: "Steam"})
+		}
+	}
+
+	releaseDate, err := GetReleaseDateFromVndb(vnID)
+	if err != nil {
+		log.Error("Failed to get release date from VNDB: ", err)
+	} else if _, err := time.Parse("2006-01-02", releaseDate); err == nil {
+		// Partial dates such as "2020-05" or "tba" are not accepted by CreateResource
+		params.ReleaseDate = releaseDate
+	}
+
+	tagNames := make([]string, 0, len(vn.Tags))
+	for _, t := range vn.Tags {
+		if t.Spoiler == 0 && t.Rating >= minVndbTagRating {
+			tagNames = append(tagNames, t.Name)
+		}
+	}
+	for _, t := range matcher.match(tagNames) {
+		params.Tags = append(params.Tags, t.ID)
+	}
+
+	if vn.Image != nil && vn.Image.URL != "" {
+		id, err := download(vn.Image.URL)
+		if err != nil {
+			log.Error("Failed to download VNDB cover: ", err)
+		} else if vn.Image.Sexual >= vndbNsfwThreshold {
+			// The cover is shown to everyone, an explicit one is kept in the gallery instead
+			params.Images = append(params.Images, id)
+			params.Gallery = append(params.Gallery, id)
+			params.GalleryNsfw = append(params.GalleryNsfw, id)
+		} else {
+			params.Images = append(params.Images, id)
+			params.CoverID = &id
+		}
+	}
+	for i, s := range vn.Screenshots {
+		if i >= maxVndbScreenshots {
+			break
+		}
+		id, err := download(s.URL)
+		if err != nil {
+			log.Error("Failed to download VNDB screenshot: ", err)
+			continue
+		}
+		params.Images = append(params.Images, id)
+		params.Gallery = append(params.Gallery, id)
+		if s.Sexual >= vndbNsfwThreshold {
+			params.GalleryNsfw = append(params.GalleryNsfw, id)
+		}
+	}
+
+	params.Characters, err = fetchVndbCharacters(vnID, download)
+	if err != nil {
+		return nil, err
+	}
+	if params.Characters == nil {
+		params.Characters = []CharacterParams{}
+	}
+
+	return params, nil
+}
+
+// vndbTitles returns the main title and the alternative titles of a visual novel.
+// The title in the original script is preferred, like character names.
+func vndbTitles(vn *vndbVisualNovel) (string, []string) {
+	title := vn.Title
+	if vn.AltTitle != "" {
+		title = vn.AltTitle
+	}
+	alternatives := []string{}
+	addTitle := func(t string) {
+		t = strings.TrimSpace(t)
+		if t == "" || t == title || slices.Contains(alternatives, t) {
+			return
+		}
+		alternatives = append(alternatives, t)
+	}
+	addTitle(vn.Title)
+	for _, t := range vn.Titles {
+		if t.Official {
+			addTitle(t.Title)
+		}
+	}
+	for _, a := range vn.Aliases {
+		addTitle(a)
+	}
+	return title, alternatives
+}
+
+// convertVndbDescription converts VNDB formatting codes to markdown and removes spoilers.
+func convertVndbDescription(desc string) string {
+	desc = vndbSpoilerRegexp.ReplaceAllString(desc, "")
+	desc = vndbUrlTagRegexp.ReplaceAllStringFunc(desc, func(s string) string {
+		m := vndbUrlTagRegexp.FindStringSubmatch(s)
+		u := m[1]
+		if strings.HasPrefix(u, "/") {
+			u = "https://vndb.org" + u
+		}
+		return fmt.Sprintf("[%s](%s)", m[2], u)
+	})
+	return strings.TrimSpace(desc)
+}
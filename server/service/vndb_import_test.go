@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nysoure/server/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const vndbStubVN = `{"results":[{
+	"id":"v17",
+	"title":"Ever17 -the out of infinity-",
+	"alttitle":"Ever17 -the out of infinity-",
+	"aliases":["E17","Ever17"],
+	"titles":[{"title":"Ever17 -the out of infinity-","official":true},{"title":"Ever17 Premium Edition","official":true},{"title":"Fan Title","official":false}],
+	"description":"A story set in [url=/v16]LeMU[/url].\n[spoiler]Hidden twist[/spoiler]",
+	"image":{"url":"https://t.vndb.org/cv/1.jpg","sexual":0},
+	"screenshots":[{"url":"https://t.vndb.org/sf/1.jpg","sexual":0},{"url":"https://t.vndb.org/sf/2.jpg","sexual":1.5}],
+	"tags":[{"name":"Amnesia","rating":2.8,"spoiler":0},{"name":"Time Travel","rating":2.5,"spoiler":2},{"name":"Unknown Tag","rating":3,"spoiler":0},{"name":"Sci-fi","rating":1.0,"spoiler":0}],
+	"extlinks":[{"url":"https://example.com/ever17","label":"Official website","name":"website"},{"url":"https://store.steampowered.com/app/123/","label":"Steam","name":"steam"}]
+}]}`
+
+const vndbStubCharacters = `{"results":[{"id":"v17","va":[{
+	"character":{"id":"c1","name":"Tsugumi","original":"小町 つぐみ","image":{"url":"https://t.vndb.org/ch/1.jpg"},"vns":[{"id":"v17","role":"main"}]},
+	"staff":{"id":"s1","name":"Mizutani Yuuko","original":"水谷 優子"}
+}]}]}`
+
+// stubVndbApi serves the given visual novel and characters in place of the VNDB API.
+// It returns a function restoring the API.
+func stubVndbApi(t *testing.T, vn, characters string) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/vn", r.URL.Path)
+		var req struct {
+			Fields string `json:"fields"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case req.Fields == "released":
+			_, _ = w.Write([]byte(`{"results":[{"released":"2002-08-29"}]}`))
+		case strings.Contains(req.Fields, "va.character"):
+			_, _ = w.Write([]byte(characters))
+		default:
+			_, _ = w.Write([]byte(vn))
+		}
+	}))
+	oldUrl := vndbApiUrl
+	vndbApiUrl = server.URL
+	return func() {
+		vndbApiUrl = oldUrl
+		server.Close()
+	}
+}
+
+func TestImportFromVndb(t *testing.T) {
+	defer stubVndbApi(t, vndbStubVN, vndbStubCharacters)()
+
+	downloaded := make([]string, 0)
+	download := func(imageURL string) (uint, error) {
+		downloaded = append(downloaded, imageURL)
+		return uint(len(downloaded)), nil
+	}
+	mainID := uint(1)
+	matcher := newTagMatcherFromTags(
+		[]model.Tag{{Model: gorm.Model{ID: 1}, Name: "失忆"}, {Model: gorm.Model{ID: 2}, Name: "科幻"}},
+		[]model.Tag{{Model: gorm.Model{ID: 3}, Name: "Amnesia", AliasOf: &mainID}},
+	)
+
+	params, err := importFromVndb("v17", matcher, download)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Ever17 -the out of infinity-", params.Title)
+	assert.Equal(t, []string{"Ever17 Premium Edition", "E17", "Ever17"}, params.AlternativeTitles)
+	assert.Equal(t, "2002-08-29", params.ReleaseDate)
+	assert.Equal(t, []model.Link{
+		{URL: "https://vndb.org/v17", Label: "VNDB"},
+		{URL: "https://example.com/ever17", Label: "Official"},
+		{URL: "https://store.steampowered.com/app/123/", Label: "Steam"},
+	}, params.Links)
+	assert.Equal(t, "A story set in [LeMU](https://vndb.org/v16).", params.Article)
+
+	// Spoiler, unknown and low rated tags are dropped
+	assert.Equal(t, []uint{1}, params.Tags)
+
+	if assert.NotNil(t, params.CoverID) {
+		assert.Equal(t, uint(1), *params.CoverID)
+	}
+	assert.Equal(t, []uint{2, 3}, params.Gallery)
+	assert.Equal(t, []uint{3}, params.GalleryNsfw)
+	assert.Equal(t, []uint{1, 2, 3}, params.Images)
+
+	if assert.Len(t, params.Characters, 1) {
+		assert.Equal(t, "小町つぐみ", params.Characters[0].Name)
+		assert.Equal(t, "水谷優子", params.Characters[0].CV)
+		assert.Equal(t, uint(4), params.Characters[0].Image)
+	}
+	assert.Len(t, downloaded, 4)
+}
+
+func TestImportFromVndbExplicitCover(t *testing.T) {
+	vn := strings.Replace(vndbStubVN, `"image":{"url":"https://t.vndb.org/cv/1.jpg","sexual":0}`,
+		`"image":{"url":"https://t.vndb.org/cv/1.jpg","sexual":1.8}`, 1)
+	defer stubVndbApi(t, vn, `{"results":[]}`)()
+
+	downloaded := 0
+	download := func(imageURL string) (uint, error) {
+		downloaded++
+		return uint(downloaded), nil
+	}
+
+	params, err := importFromVndb("v17", newTagMatcherFromTags(nil, nil), download)
+	assert.NoError(t, err)
+
+	// The explicit cover is not shown to everyone, it becomes an NSFW gallery image
+	assert.Nil(t, params.CoverID)
+	assert.Equal(t, []uint{1, 2, 3}, params.Gallery)
+	assert.Equal(t, []uint{1, 3}, params.GalleryNsfw)
+	assert.Equal(t, []uint{1, 2, 3}, params.Images)
+}
+
+func TestNormalizeVndbID(t *testing.T) {
+	for input, expected := range map[string]string{
+		"v17":                   "v17",
+		"17":                    "v17",
+		" https://vndb.org/v17": "v17",
+	} {
+		id, err := normalizeVndbID(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, id)
+	}
+	_, err := normalizeVndbID(`v1", "fields": "x`)
+	assert.Error(t, err)
+	_, err = normalizeVndbID("c17")
+	assert.Error(t, err)
+}
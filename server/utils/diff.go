@@ -0,0 +1,102 @@
+package utils
+
+import "strings"
+
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffInsert DiffOp = "insert"
+	DiffDelete DiffOp = "delete"
+)
+
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells limits the size of the LCS table. Larger texts are shown as fully replaced.
+const maxDiffCells = 4_000_000
+
+// DiffLines compares two texts line by line using the longest common subsequence.
+func DiffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	// Common prefix and suffix are trimmed first, as most edits are small
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]DiffLine, 0, len(x)+len(y))
+	for _, line := range x[:prefix] {
+		result = append(result, DiffLine{DiffEqual, line})
+	}
+	result = append(result, diffMiddle(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	for _, line := range x[len(x)-suffix:] {
+		result = append(result, DiffLine{DiffEqual, line})
+	}
+	return result
+}
+
+func diffMiddle(x, y []string) []DiffLine {
+	result := make([]DiffLine, 0, len(x)+len(y))
+	if len(x)*len(y) > maxDiffCells {
+		for _, line := range x {
+			result = append(result, DiffLine{DiffDelete, line})
+		}
+		for _, line := range y {
+			result = append(result, DiffLine{DiffInsert, line})
+		}
+		return result
+	}
+
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			result = append(result, DiffLine{DiffEqual, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, DiffLine{DiffDelete, x[i]})
+			i++
+		default:
+			result = append(result, DiffLine{DiffInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		result = append(result, DiffLine{DiffDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		result = append(result, DiffLine{DiffInsert, y[j]})
+	}
+	return result
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
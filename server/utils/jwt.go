@@ -2,6 +2,9 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"os"
 	"time"
@@ -29,11 +32,17 @@ func init() {
 	}
 }
 
-func GenerateToken(userID uint) (string, error) {
+// AccessTokenLifetime is how long an access token is valid. Sessions outlive it,
+// clients get a new one with the refresh token of their session.
+const AccessTokenLifetime = 15 * time.Minute
+
+// GenerateToken creates an access token for a session of a user.
+func GenerateToken(userID uint, sessionID uint) (string, error) {
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256,
 		jwt.MapClaims{
 			"id":  userID,
-			"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
+			"sid": sessionID,
+			"exp": time.Now().Add(AccessTokenLifetime).Unix(),
 		})
 	s, err := t.SignedString(key)
 	if err != nil {
@@ -42,23 +51,71 @@ func GenerateToken(userID uint) (string, error) {
 	return s, nil
 }
 
-func ParseToken(token string) (uint, error) {
+// ParseToken returns the user and the session of an access token.
+// Tokens issued before sessions existed have no session and are rejected.
+func ParseToken(token string) (uint, uint, error) {
 	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
 		return key, nil
 	})
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	if claims, ok := t.Claims.(jwt.MapClaims); ok && t.Valid {
-		id := uint(claims["id"].(float64))
-		expF := claims["exp"].(float64)
+		id, ok := claims["id"].(float64)
+		if !ok {
+			return 0, 0, errors.New("invalid token")
+		}
+		sid, ok := claims["sid"].(float64)
+		if !ok {
+			return 0, 0, errors.New("invalid token")
+		}
+		expF, ok := claims["exp"].(float64)
+		if !ok {
+			return 0, 0, errors.New("invalid token")
+		}
 		exp := time.Unix(int64(expF), 0)
 		if time.Now().After(exp) {
-			return 0, errors.New("token expired")
+			return 0, 0, errors.New("token expired")
 		}
-		return id, nil
+		return uint(id), uint(sid), nil
 	}
-	return 0, errors.New("invalid token")
+	return 0, 0, errors.New("invalid token")
+}
+
+// APITokenPrefix starts every API token, telling them apart from access tokens.
+const APITokenPrefix = "nys_"
+
+// GenerateRefreshToken creates a random refresh token and the hash under which it is stored.
+func GenerateRefreshToken() (token string, hash string, err error) {
+	token, err = randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashToken(token), nil
+}
+
+// GenerateAPIToken creates a random API token and the hash under which it is stored.
+func GenerateAPIToken() (token string, hash string, err error) {
+	token, err = randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = APITokenPrefix + token
+	return token, HashToken(token), nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hash under which a refresh token or an API token is stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // GenerateTemporaryToken creates a JWT token that expires in 15 minutes
@@ -95,6 +152,35 @@ func ParseTemporaryToken(token string) (string, error) {
 	return "", errors.New("invalid token")
 }
 
+// GenerateChallengeToken creates a short-lived token proving that a user gave their
+// password, to be exchanged for a session with a second factor.
+func GenerateChallengeToken(userID uint, lifetime time.Duration) (string, error) {
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256,
+		jwt.MapClaims{
+			"challenge": userID,
+			"exp":       time.Now().Add(lifetime).Unix(),
+		})
+	return t.SignedString(key)
+}
+
+// ParseChallengeToken returns the user of a challenge token.
+func ParseChallengeToken(token string) (uint, error) {
+	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, err
+	}
+	if claims, ok := t.Claims.(jwt.MapClaims); ok && t.Valid {
+		id, ok := claims["challenge"].(float64)
+		if !ok {
+			return 0, errors.New("invalid token")
+		}
+		return uint(id), nil
+	}
+	return 0, errors.New("invalid token")
+}
+
 func GenerateDownloadToken(fileKey string) (string, error) {
 	secretKeyStr := os.Getenv("DOWNLOAD_SECRET_KEY")
 	var secretKey []byte
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfc6238Secret is the SHA-1 secret of the test vectors of RFC 6238, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// The RFC lists 8 digit codes, 6 digit codes are their last digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := TotpCode(rfc6238Secret, TotpStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+}
+
+func TestValidateTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step, ok := ValidateTotp(rfc6238Secret, "050471", now)
+	assert.True(t, ok)
+	assert.Equal(t, TotpStep(now), step)
+
+	// The code of the previous period is still accepted
+	_, ok = ValidateTotp(rfc6238Secret, "050471", now.Add(30*time.Second))
+	assert.True(t, ok)
+	_, ok = ValidateTotp(rfc6238Secret, "050471", now.Add(90*time.Second))
+	assert.False(t, ok)
+	_, ok = ValidateTotp(rfc6238Secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTotpURI(t *testing.T) {
+	uri := TotpURI("Nysoure", "alice", "ABC")
+	assert.Equal(t, "otpauth://totp/Nysoure:alice?algorithm=SHA1&digits=6&issuer=Nysoure&period=30&secret=ABC", uri)
+}